@@ -0,0 +1,141 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetExamAnalytics godoc
+// @Summary      Exam item analysis
+// @Description  Returns score statistics over the exam's scored submissions and, per question, how often it was answered, how often it earned full marks, and how often each option was picked. A question with a low percent_correct or a popular wrong option is worth a second look. Teacher or admin only.
+// @Tags         submissions
+// @Produce      json
+// @Param        id   path      int  true  "Exam ID"
+// @Success      200  {object}  ExamAnalytics
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/exams/{id}/analytics [get]
+func GetExamAnalytics(c *gin.Context) {
+	if dbPool == nil {
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+		return
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	examID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid exam id")
+		return
+	}
+
+	exists, err := examExists(ctx, orgID(c), examID)
+	if err != nil {
+		requestLogger(c).Error("Failed to look up exam", "exam_id", examID, "error", err)
+		respondQueryError(c, err, "Failed to fetch analytics")
+		return
+	}
+	if !exists {
+		RespondError(c, http.StatusNotFound, ErrCodeNotFound, "Exam not found")
+		return
+	}
+
+	analytics := ExamAnalytics{ExamID: examID, Questions: []QuestionAnalytics{}}
+	err = dbPool.QueryRow(ctx, `
+		SELECT COUNT(*), COUNT(score), AVG(score)::float8,
+			(percentile_cont(0.5) WITHIN GROUP (ORDER BY score))::float8, stddev_pop(score)::float8
+		FROM submissions WHERE exam_id = $1`, examID).
+		Scan(&analytics.Submissions, &analytics.Scored, &analytics.Mean, &analytics.Median, &analytics.StdDev)
+	if err != nil {
+		requestLogger(c).Error("Failed to compute exam statistics", "exam_id", examID, "error", err)
+		respondQueryError(c, err, "Failed to fetch analytics")
+		return
+	}
+
+	// percent_correct is over graded answers only, so short answers waiting
+	// for a grader don't drag it down.
+	rows, err := dbPool.Query(ctx, `
+		SELECT q.id, q.type, q.points, COUNT(a.id), COUNT(a.points_awarded),
+			(100.0 * COUNT(*) FILTER (WHERE a.points_awarded >= q.points) / NULLIF(COUNT(a.points_awarded), 0))::float8,
+			AVG(a.points_awarded)::float8
+		FROM exam_questions q
+		LEFT JOIN submission_answers a ON a.question_id = q.id
+		WHERE q.exam_id = $1
+		GROUP BY q.id
+		ORDER BY q.position, q.id`, examID)
+	if err != nil {
+		requestLogger(c).Error("Failed to query question analytics", "exam_id", examID, "error", err)
+		respondQueryError(c, err, "Failed to fetch analytics")
+		return
+	}
+	byID := map[int]int{}
+	for rows.Next() {
+		q := QuestionAnalytics{Options: []OptionAnalytics{}}
+		if err := rows.Scan(&q.QuestionID, &q.Type, &q.Points, &q.Attempts, &q.Graded, &q.PercentCorrect, &q.MeanPoints); err != nil {
+			rows.Close()
+			requestLogger(c).Error("Failed to scan question analytics row", "error", err)
+			respondQueryError(c, err, "Error reading analytics")
+			return
+		}
+		byID[q.QuestionID] = len(analytics.Questions)
+		analytics.Questions = append(analytics.Questions, q)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		requestLogger(c).Error("Failed to iterate question analytics rows", "error", err)
+		respondQueryError(c, err, "Error reading analytics")
+		return
+	}
+
+	// A single-choice answer is the option id itself; a multi_select answer
+	// is an array that contains it.
+	rows, err = dbPool.Query(ctx, `
+		SELECT o.question_id, o.id, o.text, o.is_correct, COUNT(a.id)
+		FROM question_options o
+		JOIN exam_questions q ON q.id = o.question_id
+		LEFT JOIN submission_answers a ON a.question_id = o.question_id
+			AND (a.answer = to_jsonb(o.id) OR (jsonb_typeof(a.answer) = 'array' AND a.answer @> jsonb_build_array(o.id)))
+		WHERE q.exam_id = $1
+		GROUP BY o.id
+		ORDER BY o.question_id, o.position, o.id`, examID)
+	if err != nil {
+		requestLogger(c).Error("Failed to query option analytics", "exam_id", examID, "error", err)
+		respondQueryError(c, err, "Failed to fetch analytics")
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var questionID int
+		var o OptionAnalytics
+		if err := rows.Scan(&questionID, &o.OptionID, &o.Text, &o.IsCorrect, &o.Picked); err != nil {
+			requestLogger(c).Error("Failed to scan option analytics row", "error", err)
+			respondQueryError(c, err, "Error reading analytics")
+			return
+		}
+		i, ok := byID[questionID]
+		if !ok {
+			continue
+		}
+		q := &analytics.Questions[i]
+		if q.Attempts > 0 {
+			pct := 100 * float64(o.Picked) / float64(q.Attempts)
+			o.PickedPercent = &pct
+		}
+		q.Options = append(q.Options, o)
+	}
+	if err := rows.Err(); err != nil {
+		requestLogger(c).Error("Failed to iterate option analytics rows", "error", err)
+		respondQueryError(c, err, "Error reading analytics")
+		return
+	}
+
+	c.JSON(http.StatusOK, analytics)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// answerSchemaFiles holds one JSON Schema per question type, named after the
+// type, describing the shape of an answer to it.
+//
+//go:embed answer_schemas/*.json
+var answerSchemaFiles embed.FS
+
+// answerSchemas is filled by loadAnswerSchemas at startup.
+var answerSchemas map[string]*jsonschema.Schema
+
+// loadAnswerSchemas compiles the embedded answer schemas and checks that
+// every question type has one, so a new type can't ship without it.
+func loadAnswerSchemas() error {
+	entries, err := answerSchemaFiles.ReadDir("answer_schemas")
+	if err != nil {
+		return err
+	}
+
+	compiler := jsonschema.NewCompiler()
+	schemas := map[string]*jsonschema.Schema{}
+	for _, e := range entries {
+		file := path.Join("answer_schemas", e.Name())
+		body, err := answerSchemaFiles.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		url := "embed:///" + file
+		if err := compiler.AddResource(url, doc); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		schema, err := compiler.Compile(url)
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		schemas[strings.TrimSuffix(e.Name(), ".json")] = schema
+	}
+
+	for _, t := range []string{QuestionTypeMultipleChoice, QuestionTypeTrueFalse, QuestionTypeShortAnswer, QuestionTypeMultiSelect} {
+		if schemas[t] == nil {
+			return fmt.Errorf("no answer schema for question type %s", t)
+		}
+	}
+	answerSchemas = schemas
+	return nil
+}
+
+// answerShapeErrors validates answer against the schema for questionType,
+// reporting each violation against field plus the location inside the answer,
+// e.g. answers[2].answer[1] for the second id of a multi_select answer.
+func answerShapeErrors(questionType string, answer json.RawMessage, field string) []FieldError {
+	schema, ok := answerSchemas[questionType]
+	if !ok {
+		return []FieldError{{Field: field, Message: "no answer is accepted for question type " + questionType}}
+	}
+	value, err := jsonschema.UnmarshalJSON(bytes.NewReader(answer))
+	if err != nil {
+		return []FieldError{{Field: field, Message: "must be valid JSON"}}
+	}
+
+	err = schema.Validate(value)
+	if err == nil {
+		return nil
+	}
+	verr, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return []FieldError{{Field: field, Message: err.Error()}}
+	}
+
+	var problems []FieldError
+	for _, unit := range verr.BasicOutput().Errors {
+		if unit.Error == nil {
+			continue
+		}
+		problems = append(problems, FieldError{
+			Field:   field + instanceIndexes(unit.InstanceLocation),
+			Message: unit.Error.String(),
+		})
+	}
+	if len(problems) == 0 {
+		problems = append(problems, FieldError{Field: field, Message: "does not match the answer schema for " + questionType})
+	}
+	return problems
+}
+
+// instanceIndexes turns a JSON pointer into the indexing the rest of the
+// error details use: "/1" becomes "[1]", "/text" becomes ".text".
+func instanceIndexes(pointer string) string {
+	var b strings.Builder
+	for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		if token == "" {
+			continue
+		}
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		if strings.Trim(token, "0123456789") == "" {
+			b.WriteString("[" + token + "]")
+		} else {
+			b.WriteString("." + token)
+		}
+	}
+	return b.String()
+}
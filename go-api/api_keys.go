@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	apiKeyHeader = "X-API-Key"
+	// apiKeyPrefix marks the API's keys so scanners and people recognize
+	// one; apiKeyShownChars of the key are kept to tell keys apart.
+	apiKeyPrefix     = "qq_"
+	apiKeyShownChars = 8
+
+	// apiKeyTouchInterval bounds how often a busy key's last_used_at is
+	// written: at most once per interval, not once per request.
+	apiKeyTouchInterval = time.Minute
+
+	apiKeyColumns = "id, label, prefix, scopes, created_by, created_at, last_used_at, revoked_at"
+)
+
+func scanAPIKey(row pgx.Row, k *APIKey) error {
+	return row.Scan(&k.ID, &k.Label, &k.Prefix, &k.Scopes, &k.CreatedBy, &k.CreatedAt, &k.LastUsedAt, &k.RevokedAt)
+}
+
+// authenticateAPIKey resolves an X-API-Key to the claims it acts with: its
+// owner's id, current role and organization, plus the key's own id and
+// scopes. A key with any scope the API no longer knows is refused rather
+// than half honoured. On failure it writes the error response and returns
+// false.
+func authenticateAPIKey(c *gin.Context, key string) (*Claims, bool) {
+	if dbPool == nil {
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+		return nil, false
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	claims := &Claims{}
+	var lastUsed *time.Time
+	err := dbPool.QueryRow(ctx, `
+		SELECT k.id, k.scopes, k.last_used_at, u.id, u.role, u.org_id
+		FROM api_keys k JOIN up_users u ON u.id = k.created_by AND u.org_id = k.org_id
+		WHERE k.key_hash = $1 AND k.revoked_at IS NULL AND u.deleted_at IS NULL AND u.blocked IS NOT TRUE`,
+		hashOpaqueToken(key)).Scan(&claims.APIKeyID, &claims.Scopes, &lastUsed, &claims.UserID, &claims.Role, &claims.OrgID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		RespondError(c, http.StatusUnauthorized, ErrCodeUnauthorized, "Invalid API key")
+		return nil, false
+	}
+	if err != nil {
+		requestLogger(c).Error("Failed to look up API key", "error", err)
+		respondQueryError(c, err, "Failed to authenticate")
+		return nil, false
+	}
+	// Non-nil even if empty: a key is never unlimited.
+	if claims.Scopes == nil {
+		claims.Scopes = []string{}
+	}
+	for _, scope := range claims.Scopes {
+		if !slices.Contains(knownScopes, scope) {
+			requestLogger(c).Warn("API key has an unknown scope", "api_key_id", claims.APIKeyID, "scope", scope)
+			RespondError(c, http.StatusUnauthorized, ErrCodeUnauthorized, "API key has an unknown scope; mint a new one")
+			return nil, false
+		}
+	}
+
+	if !readOnly.Load() && (lastUsed == nil || time.Since(*lastUsed) > apiKeyTouchInterval) {
+		touchAPIKey(c, claims.APIKeyID)
+	}
+	return claims, true
+}
+
+// touchAPIKey records that a key was just used. It only feeds auditing, so a
+// failure is logged and the request goes on.
+func touchAPIKey(c *gin.Context, id int) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(c.Request.Context()), queryTimeout)
+	defer cancel()
+	if _, err := dbPool.Exec(ctx, "UPDATE api_keys SET last_used_at = now() WHERE id = $1", id); err != nil {
+		requestLogger(c).Warn("Failed to record API key use", "api_key_id", id, "error", err)
+	}
+}
+
+// RejectAPIKeys keeps machine clients away from routes that only make sense
+// for a person, such as minting more keys or changing a password.
+func RejectAPIKeys() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if claims, ok := currentClaims(c); ok && claims.APIKeyID != 0 {
+			RespondError(c, http.StatusForbidden, ErrCodeForbidden, "Not available to API keys")
+			return
+		}
+		c.Next()
+	}
+}
+
+// GetAPIKeys godoc
+// @Summary      List API keys
+// @Description  Every key of the caller's organization, revoked ones included, without the key itself. Admin only.
+// @Tags         api-keys
+// @Produce      json
+// @Success      200  {array}   APIKey
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/api-keys [get]
+func GetAPIKeys(c *gin.Context) {
+	if dbPool == nil {
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+		return
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	rows, err := dbPool.Query(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE org_id = $1 ORDER BY id", orgID(c))
+	if err != nil {
+		requestLogger(c).Error("Failed to query API keys", "error", err)
+		respondQueryError(c, err, "Failed to fetch API keys")
+		return
+	}
+	defer rows.Close()
+
+	keys := []APIKey{}
+	for rows.Next() {
+		var k APIKey
+		if err := scanAPIKey(rows, &k); err != nil {
+			requestLogger(c).Error("Failed to scan API key row", "error", err)
+			respondQueryError(c, err, "Error reading API keys")
+			return
+		}
+		keys = append(keys, k)
+	}
+	if err := rows.Err(); err != nil {
+		requestLogger(c).Error("Failed to iterate API key rows", "error", err)
+		respondQueryError(c, err, "Error reading API keys")
+		return
+	}
+
+	c.JSON(http.StatusOK, keys)
+}
+
+// CreateAPIKey godoc
+// @Summary      Mint an API key
+// @Description  Machine clients send the key in X-API-Key instead of a bearer token. It acts as the admin who minted it, limited to its scopes, and stops working when that admin is deleted or blocked. The key is only ever returned here. Admin only, and not with an API key.
+// @Tags         api-keys
+// @Accept       json
+// @Produce      json
+// @Param        key  body      CreateAPIKeyRequest  true  "Label and scopes"
+// @Success      201  {object}  APIKey
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/api-keys [post]
+func CreateAPIKey(c *gin.Context) {
+	if dbPool == nil {
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+		return
+	}
+
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindingError(c, err)
+		return
+	}
+
+	token, _, err := newOpaqueToken()
+	if err != nil {
+		requestLogger(c).Error("Failed to generate API key", "error", err)
+		RespondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to create API key")
+		return
+	}
+	key := apiKeyPrefix + token
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	var k APIKey
+	err = scanAPIKey(dbPool.QueryRow(ctx, `
+		INSERT INTO api_keys (org_id, label, prefix, key_hash, scopes, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING `+apiKeyColumns,
+		orgID(c), req.Label, key[:len(apiKeyPrefix)+apiKeyShownChars], hashOpaqueToken(key),
+		slices.Compact(slices.Sorted(slices.Values(req.Scopes))), actorID(c)), &k)
+	if err != nil {
+		requestLogger(c).Error("Failed to insert API key", "error", err)
+		respondQueryError(c, err, "Failed to create API key")
+		return
+	}
+
+	recordAudit(c, actorID(c), auditAPIKeyCreate, auditTarget{"api_key", k.ID}, gin.H{"label": k.Label, "scopes": k.Scopes})
+	k.Key = key
+	c.JSON(http.StatusCreated, k)
+}
+
+// RevokeAPIKey godoc
+// @Summary      Revoke an API key
+// @Description  The key stops working at once and stays listed as revoked. Admin only, and not with an API key.
+// @Tags         api-keys
+// @Param        id   path  int  true  "API key ID"
+// @Success      204
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/api-keys/{id} [delete]
+func RevokeAPIKey(c *gin.Context) {
+	if dbPool == nil {
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+		return
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid API key id")
+		return
+	}
+
+	// Revoking twice is a no-op, not a 404: the key is gone either way.
+	var label string
+	err = dbPool.QueryRow(ctx, `
+		UPDATE api_keys SET revoked_at = COALESCE(revoked_at, now())
+		WHERE id = $1 AND org_id = $2 RETURNING label`, id, orgID(c)).Scan(&label)
+	if errors.Is(err, pgx.ErrNoRows) {
+		RespondError(c, http.StatusNotFound, ErrCodeNotFound, "API key not found")
+		return
+	}
+	if err != nil {
+		requestLogger(c).Error("Failed to revoke API key", "api_key_id", id, "error", err)
+		respondQueryError(c, err, "Failed to revoke API key")
+		return
+	}
+
+	recordAudit(c, actorID(c), auditAPIKeyRevoke, auditTarget{"api_key", id}, gin.H{"label": label})
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	defaultAttachmentMaxBytes = 10 << 20
+	defaultAttachmentTypes    = "image/png,image/jpeg,image/gif,image/webp,application/pdf"
+
+	// multipartOverhead is the allowance on top of the file cap for part
+	// headers and boundaries.
+	multipartOverhead = 64 << 10
+	maxFilenameLength = 255
+)
+
+var errFileTooLarge = errors.New("file exceeds the size limit")
+
+// attachmentExtensions names stored objects by their detected type rather than
+// the client's filename, so a file is never served as something it isn't.
+var attachmentExtensions = map[string]string{
+	"image/png":       ".png",
+	"image/jpeg":      ".jpg",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+}
+
+// cappedReader fails with errFileTooLarge once more than limit bytes have
+// been read, so an oversized upload aborts the store mid-stream.
+type cappedReader struct {
+	r     io.Reader
+	limit int64
+	n     int64
+}
+
+func (cr *cappedReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	if cr.n > cr.limit {
+		return n, errFileTooLarge
+	}
+	return n, err
+}
+
+// UploadAttachment godoc
+// @Summary      Upload an exam attachment
+// @Description  Streams the file in the multipart field "file" to the configured storage and records it. The content type is detected from the file's bytes and must be on the allowlist. Teacher or admin only.
+// @Tags         exams
+// @Accept       multipart/form-data
+// @Produce      json
+// @Param        id           path      int   true   "Exam ID"
+// @Param        question_id  query     int   false  "Question the file belongs to"
+// @Param        file         formData  file  true   "File to upload"
+// @Success      201  {object}  Attachment
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      413  {object}  ErrorResponse
+// @Failure      415  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/exams/{id}/attachments [post]
+func UploadAttachment(store blobStore, maxBytes int64, allowedTypes []string) gin.HandlerFunc {
+	allowed := make(map[string]bool, len(allowedTypes))
+	for _, t := range allowedTypes {
+		allowed[strings.ToLower(t)] = true
+	}
+
+	return func(c *gin.Context) {
+		if dbPool == nil {
+			RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+			return
+		}
+
+		examID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid exam id")
+			return
+		}
+
+		var questionID *int
+		if v := c.Query("question_id"); v != "" {
+			id, err := strconv.Atoi(v)
+			if err != nil {
+				RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid question id")
+				return
+			}
+			questionID = &id
+		}
+
+		ctx, cancel := queryContext(c)
+		err = checkAttachmentTarget(ctx, orgID(c), examID, questionID)
+		cancel()
+		if respondClientError(c, err) {
+			return
+		}
+		if err != nil {
+			requestLogger(c).Error("Failed to look up exam", "exam_id", examID, "error", err)
+			respondQueryError(c, err, "Failed to upload attachment")
+			return
+		}
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes+multipartOverhead)
+		reader, err := c.Request.MultipartReader()
+		if err != nil {
+			RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Request must be multipart/form-data")
+			return
+		}
+
+		// Read parts in order without buffering them; anything before the
+		// file part is skipped.
+		var part io.ReadCloser
+		var filename string
+		for {
+			p, err := reader.NextPart()
+			if err != nil {
+				RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "A file is required in the file field")
+				return
+			}
+			if p.FormName() == "file" && p.FileName() != "" {
+				part, filename = p, p.FileName()
+				break
+			}
+			p.Close()
+		}
+		defer part.Close()
+
+		// Trust the bytes, not the client's Content-Type header.
+		buffered := bufio.NewReaderSize(part, 512)
+		head, err := buffered.Peek(512)
+		if err != nil && !errors.Is(err, io.EOF) {
+			RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Failed to read upload")
+			return
+		}
+		contentType, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+		if !allowed[contentType] {
+			RespondError(c, http.StatusUnsupportedMediaType, ErrCodeInvalidRequest,
+				fmt.Sprintf("Files of type %s are not allowed", contentType))
+			return
+		}
+
+		filename = sanitizeFilename(filename)
+		key := fmt.Sprintf("exams/%d/%s%s", examID, uuid.NewString(), attachmentExtensions[contentType])
+		body := &cappedReader{r: buffered, limit: maxBytes}
+
+		// Bounded by the route's Timeout rather than the query timeout.
+		url, err := store.Put(c.Request.Context(), key, contentType, body)
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.Is(err, errFileTooLarge) || errors.As(err, &tooLarge) {
+				RespondError(c, http.StatusRequestEntityTooLarge, ErrCodePayloadTooLarge,
+					fmt.Sprintf("File exceeds %d bytes", maxBytes))
+				return
+			}
+			requestLogger(c).Error("Failed to store attachment", "exam_id", examID, "key", key, "error", err)
+			RespondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to store attachment")
+			return
+		}
+
+		attachment := Attachment{
+			ExamID:      examID,
+			QuestionID:  questionID,
+			Filename:    filename,
+			ContentType: contentType,
+			Size:        body.n,
+			URL:         url,
+		}
+		if claims, ok := currentClaims(c); ok {
+			attachment.UploadedBy = &claims.UserID
+		}
+
+		ctx, cancel = queryContext(c)
+		defer cancel()
+		err = dbPool.QueryRow(ctx, `
+			INSERT INTO attachments (exam_id, question_id, filename, content_type, size_bytes, storage_key, url, uploaded_by)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id, created_at`,
+			examID, questionID, filename, contentType, attachment.Size, key, url, attachment.UploadedBy).
+			Scan(&attachment.ID, &attachment.CreatedAt)
+		if err != nil {
+			requestLogger(c).Error("Failed to record attachment", "exam_id", examID, "key", key, "error", err)
+			if delErr := store.Delete(context.WithoutCancel(ctx), key); delErr != nil {
+				requestLogger(c).Warn("Failed to remove orphaned attachment", "key", key, "error", delErr)
+			}
+			respondQueryError(c, err, "Failed to record attachment")
+			return
+		}
+
+		c.JSON(http.StatusCreated, attachment)
+	}
+}
+
+// checkAttachmentTarget confirms the exam exists in org and, when given, that
+// the question belongs to it.
+func checkAttachmentTarget(ctx context.Context, org, examID int, questionID *int) error {
+	if questionID == nil {
+		exists, err := examExists(ctx, org, examID)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return newClientError(http.StatusNotFound, ErrCodeNotFound, "Exam not found")
+		}
+		return nil
+	}
+
+	var found int
+	err := dbPool.QueryRow(ctx,
+		"SELECT 1 FROM exam_questions WHERE id = $1 AND exam_id = $2 AND org_id = $3", *questionID, examID, org).Scan(&found)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return newClientError(http.StatusNotFound, ErrCodeNotFound, "Question not found")
+	}
+	return err
+}
+
+// sanitizeFilename keeps only the base name of a client-supplied filename and
+// bounds its length; it is stored for display only, never used as a path.
+func sanitizeFilename(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, `\`, "/"))
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, name)
+	if name == "." || name == "/" || name == "" {
+		name = "file"
+	}
+	if len(name) > maxFilenameLength {
+		ext := filepath.Ext(name)
+		if len(ext) > 16 {
+			ext = ""
+		}
+		name = strings.ToValidUTF8(name[:maxFilenameLength-len(ext)], "") + ext
+	}
+	return name
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Audited actions. Names are "<area>.<verb>" so they group when sorted.
+const (
+	auditUserCreate      = "user.create"
+	auditUserBatchCreate = "user.batch_create"
+	auditUserImport      = "user.import"
+	auditUserUpdate      = "user.update"
+	auditUserDelete      = "user.delete"
+	auditUserBulkDelete  = "user.bulk_delete"
+	auditUserRestore     = "user.restore"
+
+	auditLogin          = "auth.login"
+	auditLoginFailed    = "auth.login_failed"
+	auditPasswordChange = "auth.password_change"
+	auditPasswordReset  = "auth.password_reset"
+
+	auditSubmissionCreate = "submission.create"
+	auditSubmissionGrade  = "submission.grade"
+
+	auditWebhookCreate = "webhook.create"
+	auditWebhookDelete = "webhook.delete"
+
+	auditFeatureFlagCreate = "feature_flag.create"
+	auditFeatureFlagUpdate = "feature_flag.update"
+	auditFeatureFlagDelete = "feature_flag.delete"
+
+	auditAPIKeyCreate = "api_key.create"
+	auditAPIKeyRevoke = "api_key.revoke"
+
+	auditOrgRegistrationUpdate = "org.registration_update"
+	auditOrgJoinCodeRotate     = "org.join_code_rotate"
+	auditOrgJoinCodeRemove     = "org.join_code_remove"
+)
+
+// auditTarget is the object an action was done to. A zero ID means the
+// action has no single target (a bulk import, say).
+type auditTarget struct {
+	Type string
+	ID   int
+}
+
+// Audit records that actor did action to target. meta is stored as JSON;
+// for updates it holds a before/after diff. A nil actor means the action
+// wasn't done by a signed-in user.
+func Audit(ctx context.Context, actor *int, action string, target auditTarget, meta any) error {
+	return writeAudit(ctx, dbPool, actor, action, target, meta)
+}
+
+// writeAudit is Audit on a given querier, so an entry can commit or roll
+// back with the change it describes.
+func writeAudit(ctx context.Context, q querier, actor *int, action string, target auditTarget, meta any) error {
+	var metadata []byte
+	if meta != nil {
+		var err error
+		if metadata, err = json.Marshal(meta); err != nil {
+			return err
+		}
+	}
+	var targetType *string
+	var targetID *int
+	if target.Type != "" {
+		targetType = &target.Type
+	}
+	if target.ID != 0 {
+		targetID = &target.ID
+	}
+
+	// The entry belongs to the actor's organization; one without an actor
+	// belongs to none and is only visible in the database.
+	_, err := q.Exec(ctx, `
+		INSERT INTO audit_logs (actor_user_id, action, target_type, target_id, metadata, org_id)
+		VALUES ($1, $2, $3, $4, $5, (SELECT org_id FROM up_users WHERE id = $1))`,
+		actor, action, targetType, targetID, metadata)
+	return err
+}
+
+// recordAudit calls Audit for a handler. The action has already happened, so
+// a failure is logged rather than turned into an error response.
+func recordAudit(c *gin.Context, actor *int, action string, target auditTarget, meta any) {
+	// Detached from the request so a client hanging up can't drop the entry.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(c.Request.Context()), queryTimeout)
+	defer cancel()
+	if err := Audit(ctx, actor, action, target, meta); err != nil {
+		requestLogger(c).Error("Failed to write audit log", "action", action, "error", err)
+	}
+}
+
+// actorID is the signed-in user's id, or nil on public routes.
+func actorID(c *gin.Context) *int {
+	if claims, ok := currentClaims(c); ok {
+		return &claims.UserID
+	}
+	return nil
+}
+
+// fieldChange is one entry in an update's before/after diff.
+type fieldChange struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+// userChanges diffs the audited fields of a user.
+func userChanges(before, after User) map[string]fieldChange {
+	changes := map[string]fieldChange{}
+	if before.Username != after.Username {
+		changes["username"] = fieldChange{before.Username, after.Username}
+	}
+	if before.Email != after.Email {
+		changes["email"] = fieldChange{before.Email, after.Email}
+	}
+	return changes
+}
+
+// GetAuditLogs godoc
+// @Summary      List audit log entries
+// @Description  Returns audit entries newest first, optionally filtered by actor, action and time range. Admin only.
+// @Tags         audit
+// @Produce      json
+// @Param        actor   query     int     false  "Actor user ID"
+// @Param        action  query     string  false  "Action, e.g. user.update"
+// @Param        from    query     string  false  "Earliest created_at (RFC 3339)"
+// @Param        to      query     string  false  "Latest created_at, exclusive (RFC 3339)"
+// @Param        limit   query     int     false  "Page size (default 20, max 100)"
+// @Param        offset  query     int     false  "Rows to skip"
+// @Success      200     {object}  PaginatedAuditLogs
+// @Failure      400     {object}  ErrorResponse
+// @Failure      401     {object}  ErrorResponse
+// @Failure      403     {object}  ErrorResponse
+// @Failure      500     {object}  ErrorResponse
+// @Failure      503     {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/audit [get]
+func GetAuditLogs(c *gin.Context) {
+	if dbPool == nil {
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+		return
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	limit, offset, err := parsePagination(c)
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, err.Error())
+		return
+	}
+
+	conds := []string{"org_id = $1"}
+	args := []any{orgID(c)}
+	if v := c.Query("actor"); v != "" {
+		actor, err := strconv.Atoi(v)
+		if err != nil {
+			RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "actor must be a user id")
+			return
+		}
+		args = append(args, actor)
+		conds = append(conds, "actor_user_id = $"+strconv.Itoa(len(args)))
+	}
+	if v := c.Query("action"); v != "" {
+		args = append(args, v)
+		conds = append(conds, "action = $"+strconv.Itoa(len(args)))
+	}
+	for _, bound := range []struct{ param, op string }{{"from", ">="}, {"to", "<"}} {
+		v := c.Query(bound.param)
+		if v == "" {
+			continue
+		}
+		t, err := parseTimestamp(v)
+		if err != nil {
+			RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, bound.param+" must be an RFC 3339 timestamp with a zone offset")
+			return
+		}
+		args = append(args, t)
+		conds = append(conds, "created_at "+bound.op+" $"+strconv.Itoa(len(args)))
+	}
+	where := " WHERE " + strings.Join(conds, " AND ")
+
+	pageArgs := append(args, limit, offset)
+	rows, err := dbPool.Query(ctx, `
+		SELECT id, actor_user_id, action, target_type, target_id, metadata, created_at
+		FROM audit_logs`+where+`
+		ORDER BY created_at DESC, id DESC
+		LIMIT $`+strconv.Itoa(len(args)+1)+" OFFSET $"+strconv.Itoa(len(args)+2), pageArgs...)
+	if err != nil {
+		requestLogger(c).Error("Failed to query audit logs", "error", err)
+		respondQueryError(c, err, "Failed to fetch audit logs")
+		return
+	}
+	defer rows.Close()
+
+	entries := []AuditLog{}
+	for rows.Next() {
+		var e AuditLog
+		var metadata []byte
+		if err := rows.Scan(&e.ID, &e.ActorUserID, &e.Action, &e.TargetType, &e.TargetID, &metadata, &e.CreatedAt); err != nil {
+			requestLogger(c).Error("Failed to scan audit log row", "error", err)
+			respondQueryError(c, err, "Error reading audit logs")
+			return
+		}
+		if metadata != nil {
+			e.Metadata = json.RawMessage(metadata)
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		requestLogger(c).Error("Failed to iterate audit log rows", "error", err)
+		respondQueryError(c, err, "Error reading audit logs")
+		return
+	}
+
+	var total int
+	if err := dbPool.QueryRow(ctx, "SELECT COUNT(*) FROM audit_logs"+where, args...).Scan(&total); err != nil {
+		requestLogger(c).Error("Failed to count audit logs", "error", err)
+		respondQueryError(c, err, "Failed to count audit logs")
+		return
+	}
+
+	c.JSON(http.StatusOK, PaginatedAuditLogs{Data: entries, Total: total, Limit: limit, Offset: offset})
+}
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/jackc/pgx/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	claimsKey          = "claims"
+	defaultTokenTTL    = 1 * time.Hour
+	invalidCredentials = "Invalid credentials"
+)
+
+// dummyPasswordHash is compared against when the user doesn't exist so that
+// unknown identifiers take as long to reject as wrong passwords.
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
+
+// Register godoc
+// @Summary      Register an account
+// @Description  Creates the account in the organization named by its slug, or the default one, and, in the background, emails a link to verify the address. A failed email doesn't fail the registration. Unless the organization is open to registration, join_code must be its current join code.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        user  body      RegisterRequest  true  "Account details"
+// @Success      201   {object}  User
+// @Failure      400   {object}  ErrorResponse
+// @Failure      403   {object}  ErrorResponse
+// @Failure      409   {object}  ErrorResponse
+// @Failure      500   {object}  ErrorResponse
+// @Router       /v1/auth/register [post]
+func Register(mailer *accountMailer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req RegisterRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondBindingError(c, err)
+			return
+		}
+
+		org, ok := resolveOrg(c, req.Organization, req.JoinCode)
+		if !ok {
+			return
+		}
+		user, ok := createUser(c, org, req.Username, req.Email, req.Password, false)
+		if !ok {
+			return
+		}
+
+		sendInBackground(requestLogger(c).With("user_id", user.ID), func(ctx context.Context) error {
+			return mailer.sendVerification(ctx, user)
+		})
+		c.JSON(http.StatusCreated, user)
+	}
+}
+
+// Claims is the JWT payload we issue and accept. The "id" claim matches the
+// shape of Strapi's users-permissions tokens signed with the same secret.
+type Claims struct {
+	UserID int    `json:"id"`
+	Role   string `json:"role,omitempty"`
+	OrgID  int    `json:"org_id,omitempty"`
+	// Scopes, when present, limit the token to routes requiring one of them;
+	// without them the token holds its role's scopes. See grantedScopes.
+	Scopes []string `json:"scopes,omitempty"`
+	// APIKeyID is only set for requests made with an API key, which act as
+	// the key's owner; see authenticateAPIKey.
+	APIKeyID int `json:"-"`
+	jwt.RegisteredClaims
+}
+
+// AuthRequired validates the Bearer token, or without one an X-API-Key, on
+// every request and stores the resulting Claims on the context. Any failure
+// aborts with 401.
+func AuthRequired(secret []byte) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		if key := c.GetHeader(apiKeyHeader); key != "" && header == "" {
+			claims, ok := authenticateAPIKey(c, key)
+			if !ok {
+				return
+			}
+			c.Set(claimsKey, claims)
+			c.Next()
+			return
+		}
+
+		tokenString, ok := strings.CutPrefix(header, "Bearer ")
+		// Browsers can't set headers on a WebSocket handshake, so upgrades
+		// may carry the token in the query string instead.
+		if !ok && websocket.IsWebSocketUpgrade(c.Request) {
+			tokenString = c.Query("access_token")
+			ok = true
+		}
+		if !ok || tokenString == "" {
+			RespondError(c, http.StatusUnauthorized, ErrCodeUnauthorized, "Missing bearer token")
+			return
+		}
+
+		claims, err := verifyAccessToken(c.Request.Context(), requestLogger(c), tokenString, secret)
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			RespondError(c, http.StatusUnauthorized, ErrCodeTokenExpired, "Token expired")
+			return
+		}
+		if errors.Is(err, errTokenRevoked) {
+			RespondError(c, http.StatusUnauthorized, ErrCodeUnauthorized, "Token has been revoked")
+			return
+		}
+		if err != nil {
+			RespondError(c, http.StatusUnauthorized, ErrCodeUnauthorized, "Invalid token")
+			return
+		}
+
+		c.Set(claimsKey, claims)
+		c.Next()
+	}
+}
+
+var errTokenRevoked = errors.New("token has been revoked")
+
+// verifyAccessToken parses an access token and checks that it hasn't been
+// revoked by logout, returning errTokenRevoked if it has.
+func verifyAccessToken(ctx context.Context, log *slog.Logger, tokenString string, secret []byte) (*Claims, error) {
+	claims, err := parseToken(tokenString, secret)
+	if err != nil {
+		return nil, err
+	}
+	if claims.ID != "" {
+		ctx, cancel := context.WithTimeout(ctx, cacheOpTimeout)
+		revoked, err := accessDenylist.contains(ctx, claims.ID)
+		cancel()
+		// A Redis outage fails open: the token is still signed and
+		// unexpired, and logout has already revoked its refresh token.
+		if err != nil {
+			log.Debug("Token denylist lookup failed", "error", err)
+		}
+		if revoked {
+			return nil, errTokenRevoked
+		}
+	}
+	return claims, nil
+}
+
+func parseToken(tokenString string, secret []byte) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (any, error) {
+		return secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+	if claims.UserID <= 0 {
+		return nil, errors.New("token has no user id")
+	}
+	return claims, nil
+}
+
+// currentClaims returns the claims stored by AuthRequired, if any.
+func currentClaims(c *gin.Context) (*Claims, bool) {
+	v, ok := c.Get(claimsKey)
+	if !ok {
+		return nil, false
+	}
+	claims, ok := v.(*Claims)
+	return claims, ok
+}
+
+// Login exchanges a username or email plus password for a signed access token
+// and a refresh token that starts a new token family. Unknown users and wrong
+// passwords get the same 401 to avoid enumeration.
+//
+// @Summary      Log in
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        credentials  body      LoginRequest  true  "Username or email, and password"
+// @Success      200          {object}  LoginResponse
+// @Failure      400          {object}  ErrorResponse
+// @Failure      401          {object}  ErrorResponse
+// @Failure      429          {object}  ErrorResponse
+// @Failure      500          {object}  ErrorResponse
+// @Router       /v1/auth/login [post]
+func Login(secret []byte, ttl, refreshTTL time.Duration, lockout loginLockout) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if dbPool == nil {
+			RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+			return
+		}
+
+		ctx, cancel := queryContext(c)
+		defer cancel()
+
+		var req LoginRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondBindingError(c, err)
+			return
+		}
+
+		var (
+			id          int
+			hash        *string
+			role        string
+			org         int
+			lockedUntil *time.Time
+		)
+		err := dbPool.QueryRow(ctx, `
+			SELECT id, password, role, org_id, CASE WHEN locked_until > now() THEN locked_until END
+			FROM up_users
+			WHERE (username = $1 OR email = $1) AND blocked IS NOT TRUE AND deleted_at IS NULL
+			LIMIT 1`, req.Identifier).Scan(&id, &hash, &role, &org, &lockedUntil)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			requestLogger(c).Error("Failed to look up user for login", "error", err)
+			respondQueryError(c, err, "Failed to log in")
+			return
+		}
+
+		if err != nil || hash == nil {
+			verifyPassword(string(dummyPasswordHash), req.Password)
+			RespondError(c, http.StatusUnauthorized, ErrCodeInvalidCredentials, invalidCredentials)
+			return
+		}
+		// Checked before the password, so a locked account can't be probed.
+		// This does reveal that the account exists, which is the price of
+		// telling a locked-out user why.
+		if lockedUntil != nil {
+			respondLocked(c, *lockedUntil)
+			return
+		}
+		if !verifyPassword(*hash, req.Password) {
+			if err := lockout.recordFailure(ctx, id); err != nil {
+				requestLogger(c).Error("Failed to record failed login", "user_id", id, "error", err)
+			}
+			recordAudit(c, &id, auditLoginFailed, auditTarget{"user", id}, gin.H{"ip": c.ClientIP()})
+			RespondError(c, http.StatusUnauthorized, ErrCodeInvalidCredentials, invalidCredentials)
+			return
+		}
+		if err := lockout.recordSuccess(ctx, id); err != nil {
+			requestLogger(c).Error("Failed to reset failed logins", "user_id", id, "error", err)
+		}
+
+		claims := &Claims{UserID: id, Role: role, OrgID: org}
+		token, expiresAt, err := signToken(claims, secret, ttl)
+		if err != nil {
+			requestLogger(c).Error("Failed to sign token", "user_id", id, "error", err)
+			RespondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to log in")
+			return
+		}
+
+		refresh, _, refreshUntil, err := issueRefreshToken(ctx, dbPool, id, uuid.New(), refreshTTL)
+		if err != nil {
+			requestLogger(c).Error("Failed to issue refresh token", "user_id", id, "error", err)
+			respondQueryError(c, err, "Failed to log in")
+			return
+		}
+
+		recordLastLogin(requestLogger(c), id)
+		recordAudit(c, &id, auditLogin, auditTarget{"user", id}, gin.H{"ip": c.ClientIP()})
+		c.JSON(http.StatusOK, LoginResponse{
+			Token:            token,
+			ExpiresAt:        expiresAt,
+			RefreshToken:     refresh,
+			RefreshExpiresAt: refreshUntil,
+		})
+	}
+}
+
+// recordLastLogin stamps the user's last_login_at on its own goroutine, so the
+// token goes out without waiting on the write. It only feeds reports, so a
+// failure is logged and the login stands.
+func recordLastLogin(log *slog.Logger, userID int) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+		defer cancel()
+		if _, err := dbPool.Exec(ctx, "UPDATE up_users SET last_login_at = now() WHERE id = $1", userID); err != nil {
+			log.Warn("Failed to record last login", "user_id", userID, "error", err)
+		}
+	}()
+}
+
+func signToken(claims *Claims, secret []byte, ttl time.Duration) (string, time.Time, error) {
+	now := time.Now().UTC()
+	expiresAt := now.Add(ttl)
+	claims.ID = uuid.NewString()
+	claims.Subject = strconv.Itoa(claims.UserID)
+	claims.IssuedAt = jwt.NewNumericDate(now)
+	claims.ExpiresAt = jwt.NewNumericDate(expiresAt)
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+	return token, expiresAt, err
+}
+
+// Logout godoc
+// @Summary      Log out
+// @Description  Ends the session: the refresh token, if given, is revoked along with every token rotated from the same login, and when Redis is configured the access token used for this call stops working immediately instead of at its expiry.
+// @Tags         auth
+// @Accept       json
+// @Param        request  body  LogoutRequest  false  "Refresh token to revoke"
+// @Success      204
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/auth/logout [post]
+func Logout(c *gin.Context) {
+	if dbPool == nil {
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+		return
+	}
+
+	claims, ok := currentClaims(c)
+	if !ok {
+		RespondError(c, http.StatusUnauthorized, ErrCodeUnauthorized, "Authentication required")
+		return
+	}
+
+	// The body is optional; an empty one just skips refresh revocation.
+	var req LogoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondBindingError(c, err)
+		return
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	if req.RefreshToken != "" {
+		// Deleting the family rather than the one token also ends any copy
+		// that was rotated from it. Someone else's token is silently ignored.
+		_, err := dbPool.Exec(ctx, `
+			DELETE FROM refresh_tokens
+			WHERE family_id = (SELECT family_id FROM refresh_tokens WHERE token_hash = $1 AND user_id = $2)`,
+			hashOpaqueToken(req.RefreshToken), claims.UserID)
+		if err != nil {
+			requestLogger(c).Error("Failed to revoke refresh token", "user_id", claims.UserID, "error", err)
+			respondQueryError(c, err, "Failed to log out")
+			return
+		}
+	}
+
+	if claims.ID != "" && claims.ExpiresAt != nil {
+		if err := accessDenylist.add(ctx, claims.ID, claims.ExpiresAt.Time); err != nil {
+			// The refresh token is gone, so the session ends at the access
+			// token's expiry at the latest.
+			requestLogger(c).Warn("Failed to denylist access token", "user_id", claims.UserID, "error", err)
+		}
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// RequireRole allows the request through only when the authenticated user's
+// role claim is one of roles. It must run after AuthRequired.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := currentClaims(c)
+		if !ok {
+			RespondError(c, http.StatusUnauthorized, ErrCodeUnauthorized, "Authentication required")
+			return
+		}
+		if !slices.Contains(roles, claims.Role) {
+			RespondError(c, http.StatusForbidden, ErrCodeForbidden, "Insufficient permissions")
+			return
+		}
+		c.Next()
+	}
+}
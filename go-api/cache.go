@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	defaultUserCacheTTL = 30 * time.Second
+
+	// cacheOpTimeout keeps a slow Redis from costing more than the query it
+	// is meant to save.
+	cacheOpTimeout = 200 * time.Millisecond
+
+	userCacheGenKey = "cache:users:gen"
+
+	// responseCacheFormat is part of every key so entries stored in an older
+	// layout are never read as the current one.
+	responseCacheFormat = "v2"
+)
+
+var cacheRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "cache_requests_total",
+	Help: "Cache lookups by cache and result (hit, miss or error).",
+}, []string{"cache", "result"})
+
+func init() {
+	prometheus.MustRegister(cacheRequestsTotal)
+}
+
+// responseCache stores serialized JSON responses in Redis. A nil
+// *responseCache is a valid, disabled cache, so callers never need to check
+// whether REDIS_URL was set.
+//
+// Entries keep the response's Link header on the first line, so a hit
+// returns the same pagination links as the miss that stored it.
+//
+// Invalidation bumps a generation counter that is part of every key, which
+// orphans all cached pages at once without scanning for them; the orphans
+// simply expire.
+type responseCache struct {
+	client *redis.Client
+	name   string
+	genKey string
+	ttl    time.Duration
+}
+
+// userCache caches GetUsers responses. It is nil unless REDIS_URL is set.
+var userCache *responseCache
+
+// redisClient is shared by everything backed by Redis; nil without REDIS_URL.
+var redisClient *redis.Client
+
+// InitCache connects to Redis when cfg.RedisURL is set and sets up the
+// Redis-backed features. An unreachable Redis is only logged: lookups fail
+// over to the database until it comes back.
+func InitCache(cfg *Config) error {
+	if cfg.RedisURL == "" {
+		return nil
+	}
+
+	opts, err := redis.ParseURL(cfg.RedisURL)
+	if err != nil {
+		return err
+	}
+	client := redis.NewClient(opts)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		logger.Warn("Redis is not reachable; caching disabled until it is", "error", err)
+	} else {
+		logger.Info("Connected to Redis", "addr", opts.Addr)
+	}
+
+	redisClient = client
+	userCache = &responseCache{client: client, name: "users", genKey: userCacheGenKey, ttl: cfg.UserCacheTTL}
+	accessDenylist = &tokenDenylist{client: client}
+	return nil
+}
+
+func CloseCache() {
+	if redisClient != nil {
+		redisClient.Close()
+	}
+}
+
+// key builds the cache key for a request from the caller's organization and
+// its normalized query string. The returned generation must be used for the
+// matching set.
+func (rc *responseCache) key(ctx context.Context, c *gin.Context) (string, error) {
+	gen, err := rc.client.Get(ctx, rc.genKey).Int64()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return "", err
+	}
+	// Encode sorts by parameter name, so equivalent queries share a key.
+	return "cache:" + rc.name + ":" + responseCacheFormat + ":" + strconv.FormatInt(gen, 10) + ":" + strconv.Itoa(orgID(c)) + ":" +
+		c.Request.URL.Query().Encode(), nil
+}
+
+// serve writes a cached response for c and reports whether it found one. On
+// a miss it returns the key the response should be stored under; an empty key
+// means Redis is unavailable and the response should not be cached.
+func (rc *responseCache) serve(c *gin.Context) (key string, hit bool) {
+	if rc == nil {
+		return "", false
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), cacheOpTimeout)
+	defer cancel()
+
+	key, err := rc.key(ctx, c)
+	if err == nil {
+		var entry []byte
+		entry, err = rc.client.Get(ctx, key).Bytes()
+		if err == nil {
+			cacheRequestsTotal.WithLabelValues(rc.name, "hit").Inc()
+			link, body, _ := bytes.Cut(entry, []byte("\n"))
+			if len(link) > 0 {
+				c.Header("Link", string(link))
+			}
+			c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+			return key, true
+		}
+		if errors.Is(err, redis.Nil) {
+			cacheRequestsTotal.WithLabelValues(rc.name, "miss").Inc()
+			return key, false
+		}
+	}
+
+	// Debug only: while Redis is down this fires on every request, and the
+	// error counter already makes the outage visible.
+	cacheRequestsTotal.WithLabelValues(rc.name, "error").Inc()
+	requestLogger(c).Debug("Cache lookup failed", "cache", rc.name, "error", err)
+	return "", false
+}
+
+// respond writes v as the 200 response and stores it, with any Link header
+// already set, under key. json.Marshal never emits a raw newline, so the
+// first one in an entry always ends the header.
+func (rc *responseCache) respond(c *gin.Context, key string, v any) {
+	if rc == nil || key == "" {
+		c.JSON(http.StatusOK, v)
+		return
+	}
+
+	body, err := json.Marshal(v)
+	if err != nil {
+		c.JSON(http.StatusOK, v)
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), cacheOpTimeout)
+	defer cancel()
+	entry := append([]byte(c.Writer.Header().Get("Link")+"\n"), body...)
+	if err := rc.client.Set(ctx, key, entry, rc.ttl).Err(); err != nil {
+		requestLogger(c).Warn("Cache store failed", "cache", rc.name, "error", err)
+	}
+}
+
+// invalidate drops every cached response. Failures are logged rather than
+// returned: the mutation already committed, and entries expire within ttl.
+func (rc *responseCache) invalidate(c *gin.Context) {
+	if rc == nil {
+		return
+	}
+
+	rc.invalidateCtx(c.Request.Context(), requestLogger(c))
+}
+
+// invalidateCtx is invalidate for callers outside a Gin handler.
+func (rc *responseCache) invalidateCtx(ctx context.Context, log *slog.Logger) {
+	if rc == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cacheOpTimeout)
+	defer cancel()
+	if err := rc.client.Incr(ctx, rc.genKey).Err(); err != nil {
+		log.Warn("Cache invalidation failed", "cache", rc.name, "error", err)
+	}
+}
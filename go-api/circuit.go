@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	defaultCircuitThreshold = 5
+	defaultCircuitCooldown  = 30 * time.Second
+)
+
+// errCircuitOpen fails a database call without trying it while the breaker
+// is open.
+var errCircuitOpen = errors.New("database circuit breaker is open")
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+func (s circuitState) String() string {
+	switch s {
+	case circuitOpen:
+		return "open"
+	case circuitHalfOpen:
+		return "half_open"
+	default:
+		return "closed"
+	}
+}
+
+var dbCircuitState = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "db_circuit_state",
+	Help: "Database circuit breaker state: 0 closed, 1 open, 2 half-open.",
+})
+
+var dbCircuitRejected = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "db_circuit_rejected_total",
+	Help: "Database calls failed fast because the circuit breaker was open.",
+})
+
+func init() {
+	prometheus.MustRegister(dbCircuitState, dbCircuitRejected)
+}
+
+// dbBreaker guards dbPool. It is only set once the pool has connected, so
+// the startup retries are never refused, and stays nil when disabled.
+var dbBreaker atomic.Pointer[circuitBreaker]
+
+// circuitBreaker stops sending work to a database that keeps failing. After
+// threshold consecutive failures it opens and rejects every call for
+// cooldown, then half-opens: one call at a time goes through as a probe, and
+// its outcome closes the breaker or opens it for another cooldown. A probe
+// that never reports is superseded by the next one after cooldown.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    circuitState
+	failures int
+	// since is when the breaker opened, or when the current probe was let
+	// through while half-open.
+	since time.Time
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	dbCircuitState.Set(float64(circuitClosed))
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+// allow returns errCircuitOpen if a call may not go ahead right now.
+func (b *circuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == circuitClosed {
+		return nil
+	}
+	if time.Since(b.since) < b.cooldown {
+		dbCircuitRejected.Inc()
+		return errCircuitOpen
+	}
+	b.setState(circuitHalfOpen)
+	b.since = time.Now()
+	return nil
+}
+
+// record counts the outcome of a call that was allowed.
+func (b *circuitBreaker) record(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case !failed:
+		b.failures = 0
+		if b.state == circuitHalfOpen {
+			logger.Info("Database circuit breaker closed")
+			b.setState(circuitClosed)
+		}
+	case b.state == circuitHalfOpen:
+		b.trip()
+	case b.state == circuitClosed:
+		b.failures++
+		if b.failures >= b.threshold {
+			b.trip()
+		}
+	}
+}
+
+func (b *circuitBreaker) trip() {
+	logger.Warn("Database circuit breaker opened", "consecutive_failures", b.failures, "cooldown", b.cooldown.String())
+	b.setState(circuitOpen)
+	b.since = time.Now()
+	b.failures = 0
+}
+
+func (b *circuitBreaker) setState(s circuitState) {
+	b.state = s
+	dbCircuitState.Set(float64(s))
+}
+
+// State is the breaker's current state, for health reports.
+func (b *circuitBreaker) State() circuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// circuitPrepareConn is the pool's PrepareConn hook: acquiring a connection
+// is where every query, transaction and ping passes, so that is where calls
+// are refused.
+func circuitPrepareConn(context.Context, *pgx.Conn) (bool, error) {
+	if b := dbBreaker.Load(); b != nil {
+		return true, b.allow()
+	}
+	return true, nil
+}
+
+// isDatabaseFailure reports whether err says the database is in trouble, as
+// opposed to a bad query, a missing row or a caller that gave up.
+func isDatabaseFailure(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, errCircuitOpen) || errors.Is(err, pgx.ErrNoRows) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code[:2] {
+		// Connection exception, insufficient resources, operator
+		// intervention, system error, internal error.
+		case "08", "53", "57", "58", "XX":
+			return true
+		}
+		return false
+	}
+	// Anything else is the network or the protocol.
+	return true
+}
+
+// circuitTracer feeds query and acquire outcomes to dbBreaker.
+type circuitTracer struct{}
+
+func (circuitTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, _ pgx.TraceQueryStartData) context.Context {
+	return ctx
+}
+
+func (circuitTracer) TraceQueryEnd(_ context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	if b := dbBreaker.Load(); b != nil {
+		b.record(isDatabaseFailure(data.Err))
+	}
+}
+
+func (circuitTracer) TraceAcquireStart(ctx context.Context, _ *pgxpool.Pool, _ pgxpool.TraceAcquireStartData) context.Context {
+	return ctx
+}
+
+// TraceAcquireEnd only counts failures: handing out an idle connection says
+// nothing about whether the database can answer on it.
+func (circuitTracer) TraceAcquireEnd(_ context.Context, _ *pgxpool.Pool, data pgxpool.TraceAcquireEndData) {
+	if b := dbBreaker.Load(); b != nil && isDatabaseFailure(data.Err) {
+		b.record(true)
+	}
+}
+
+// pingDB pings dbPool, reporting a success to dbBreaker. Pings skip the query
+// tracer, so without this a half-open probe spent on a health check would
+// never close the breaker. Failures are left to the acquire tracer, which
+// sees the ones that matter.
+func pingDB(ctx context.Context) error {
+	err := dbPool.Ping(ctx)
+	if b := dbBreaker.Load(); b != nil && err == nil {
+		b.record(false)
+	}
+	return err
+}
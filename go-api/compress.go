@@ -0,0 +1,188 @@
+package main
+
+import (
+	"compress/gzip"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+const defaultGzipMinSize = 1024
+
+// incompressibleTypes are already compressed; gzipping them again only costs
+// CPU. Entries ending in "/" match the whole family.
+var incompressibleTypes = []string{
+	"image/",
+	"video/",
+	"audio/",
+	"font/woff",
+	"font/woff2",
+	"application/gzip",
+	"application/zip",
+	"application/pdf",
+	"application/octet-stream",
+}
+
+var gzipWriters = sync.Pool{
+	New: func() any {
+		gz, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression)
+		return gz
+	},
+}
+
+// Gzip compresses responses for clients that accept it. The first minSize
+// bytes are held back so small bodies, which gzip would barely shrink, go out
+// as-is; a handler that flushes early (a streaming export) is compressed from
+// that point on.
+func Gzip(minSize int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodHead || c.GetHeader("Upgrade") != "" || !acceptsGzip(c.GetHeader("Accept-Encoding")) {
+			c.Next()
+			return
+		}
+
+		gw := &gzipWriter{ResponseWriter: c.Writer, minSize: minSize}
+		c.Writer = gw
+		// Restore the plain writer even on panic, so Recovery's 500 isn't
+		// written into a half-decided gzip stream.
+		defer func() { c.Writer = gw.ResponseWriter }()
+
+		c.Next()
+		gw.finish()
+	}
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip, honouring
+// an explicit q=0.
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			continue
+		}
+		params = strings.ReplaceAll(params, " ", "")
+		if q, ok := strings.CutPrefix(params, "q="); ok {
+			if v, err := strconv.ParseFloat(q, 64); err == nil && v == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// gzipWriter buffers the start of a response until it knows whether
+// compressing it is worthwhile.
+type gzipWriter struct {
+	gin.ResponseWriter
+	minSize int
+	buf     []byte
+	decided bool
+	gz      *gzip.Writer
+}
+
+func (w *gzipWriter) Write(p []byte) (int, error) {
+	if w.decided {
+		if w.gz != nil {
+			return w.gz.Write(p)
+		}
+		return w.ResponseWriter.Write(p)
+	}
+
+	w.buf = append(w.buf, p...)
+	if len(w.buf) >= w.minSize {
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func (w *gzipWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Written counts buffered bytes, so middleware checking whether a response
+// has started (Timeout, Recovery) doesn't write a second one.
+func (w *gzipWriter) Written() bool {
+	return len(w.buf) > 0 || w.ResponseWriter.Written()
+}
+
+func (w *gzipWriter) Flush() {
+	if !w.decided {
+		w.decide(true)
+	}
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// decide picks compressed or plain output and writes out whatever has been
+// buffered so far.
+func (w *gzipWriter) decide(large bool) error {
+	w.decided = true
+	if large && w.compressible() {
+		h := w.Header()
+		h.Set("Content-Encoding", "gzip")
+		h.Add("Vary", "Accept-Encoding")
+		h.Del("Content-Length")
+
+		w.gz = gzipWriters.Get().(*gzip.Writer)
+		w.gz.Reset(w.ResponseWriter)
+		_, err := w.gz.Write(w.buf)
+		w.buf = nil
+		return err
+	}
+
+	var err error
+	if len(w.buf) > 0 {
+		_, err = w.ResponseWriter.Write(w.buf)
+	}
+	w.buf = nil
+	return err
+}
+
+// compressible reports whether the response can still be compressed: its
+// headers haven't gone out, the status carries a body, and the content type
+// isn't compressed already.
+func (w *gzipWriter) compressible() bool {
+	if w.ResponseWriter.Written() {
+		return false
+	}
+	switch status := w.Status(); {
+	case status < http.StatusOK, status == http.StatusNoContent,
+		status == http.StatusPartialContent, status == http.StatusNotModified:
+		return false
+	}
+	h := w.Header()
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+	contentType, _, _ := mime.ParseMediaType(h.Get("Content-Type"))
+	for _, t := range incompressibleTypes {
+		if contentType == t || (strings.HasSuffix(t, "/") && strings.HasPrefix(contentType, t)) {
+			return false
+		}
+	}
+	return true
+}
+
+// finish sends a body that never reached minSize uncompressed, or closes the
+// gzip stream.
+func (w *gzipWriter) finish() {
+	if !w.decided {
+		w.decide(false)
+		return
+	}
+	if w.gz != nil {
+		w.gz.Close()
+		w.gz.Reset(nil)
+		gzipWriters.Put(w.gz)
+		w.gz = nil
+	}
+}
@@ -0,0 +1,456 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Config holds every setting the API reads from the environment. It is
+// loaded once at startup by LoadConfig and passed down explicitly.
+type Config struct {
+	Port            string
+	GRPCPort        string
+	LogLevel        string
+	DebugEndpoints  bool
+	DefaultLanguage string
+
+	AccessLogSkipPaths []string
+
+	OTLPEndpoint string
+	ServiceName  string
+
+	GzipEnabled bool
+	GzipMinSize int
+
+	// ReadOnlyMode starts the API rejecting writes; see readOnly.
+	ReadOnlyMode bool
+
+	// MaintenanceMode starts the API answering 503 to everyone without
+	// MaintenanceBypassToken; see maintenance.
+	MaintenanceMode        bool
+	MaintenanceBypassToken string
+	MaintenanceRetryAfter  time.Duration
+
+	// MaxRequestBytes caps request bodies, except on the upload routes,
+	// which have their own limits.
+	MaxRequestBytes int
+
+	TLSCertFile      string
+	TLSKeyFile       string
+	HTTPRedirectPort string
+
+	DatabaseURL       string
+	DBQueryTimeout    time.Duration
+	DBMaxConns        int
+	DBMinConns        int
+	DBMaxConnLifetime time.Duration
+	DBMaxConnIdleTime time.Duration
+	// DBQueryExecMode is how queries with arguments are sent; see
+	// queryExecModes. DBStatementCacheSize bounds the per-connection cache
+	// the two caching modes keep.
+	DBQueryExecMode      string
+	DBStatementCacheSize int
+	DBConnectRetries     int
+	DBHealthInterval     time.Duration
+	// DBCircuitThreshold consecutive database failures open the circuit
+	// breaker for DBCircuitCooldown; 0 disables it.
+	DBCircuitThreshold int
+	DBCircuitCooldown  time.Duration
+	RunMigrations      bool
+
+	RedisURL     string
+	UserCacheTTL time.Duration
+
+	FeatureFlagRefresh time.Duration
+
+	JWTSecret       string
+	JWTExpiresIn    time.Duration
+	RefreshTokenTTL time.Duration
+	BcryptCost      int
+
+	LoginMaxAttempts     int
+	LoginLockoutDuration time.Duration
+
+	PublicURL            string
+	SMTPHost             string
+	SMTPPort             int
+	SMTPUser             string
+	SMTPPass             string
+	SMTPFrom             string
+	EmailVerificationTTL time.Duration
+	PasswordResetURL     string
+	PasswordResetTTL     time.Duration
+
+	UserImportMaxBytes int
+	UserBatchMaxSize   int
+
+	IdempotencyKeyTTL time.Duration
+
+	AttachmentStorage      string
+	AttachmentDir          string
+	AttachmentBaseURL      string
+	AttachmentMaxBytes     int
+	AttachmentAllowedTypes []string
+	S3Endpoint             string
+	S3Region               string
+	S3Bucket               string
+	S3AccessKey            string
+	S3SecretKey            string
+	S3UseSSL               bool
+	S3PublicURL            string
+
+	CORSAllowedOrigins []string
+	TrustedProxies     []string
+	RateLimitRPS       float64
+	RateLimitBurst     int
+	RateLimitUserRPS   float64
+	RateLimitUserBurst int
+}
+
+// LoadConfig reads the environment, applies defaults and validates the
+// result. All problems are reported together rather than one at a time.
+func LoadConfig() (*Config, error) {
+	env := &envReader{}
+
+	cfg := &Config{
+		Port:           env.string("API_PORT", "8080"),
+		GRPCPort:       env.string("GRPC_PORT", ""),
+		LogLevel:       env.string("LOG_LEVEL", "info"),
+		DebugEndpoints: env.bool("DEBUG_ENDPOINTS", false),
+		// Checked against the message bundles by loadMessageBundles.
+		DefaultLanguage: env.string("DEFAULT_LANGUAGE", "en"),
+
+		AccessLogSkipPaths: parseList(env.string("ACCESS_LOG_SKIP_PATHS", "/health,/livez,/readyz,/metrics")),
+
+		OTLPEndpoint: env.string("OTEL_EXPORTER_OTLP_ENDPOINT", ""),
+		ServiceName:  env.string("OTEL_SERVICE_NAME", defaultServiceName),
+
+		GzipEnabled: env.bool("GZIP_ENABLED", true),
+		GzipMinSize: env.int("GZIP_MIN_SIZE", defaultGzipMinSize),
+
+		ReadOnlyMode: env.bool("READ_ONLY_MODE", false),
+
+		MaintenanceMode:        env.bool("MAINTENANCE_MODE", false),
+		MaintenanceBypassToken: env.string("MAINTENANCE_BYPASS_TOKEN", ""),
+		MaintenanceRetryAfter:  env.duration("MAINTENANCE_RETRY_AFTER", defaultMaintenanceRetryAfter*time.Second),
+
+		MaxRequestBytes: env.int("MAX_REQUEST_BYTES", defaultMaxRequestBytes),
+
+		TLSCertFile:      env.string("TLS_CERT_FILE", ""),
+		TLSKeyFile:       env.string("TLS_KEY_FILE", ""),
+		HTTPRedirectPort: env.string("HTTP_REDIRECT_PORT", ""),
+
+		DatabaseURL:          env.string("DATABASE_URL", ""),
+		DBQueryTimeout:       env.duration("DB_QUERY_TIMEOUT", defaultQueryTimeout),
+		DBMaxConns:           env.int("DB_MAX_CONNS", 10),
+		DBMinConns:           env.int("DB_MIN_CONNS", 0),
+		DBMaxConnLifetime:    env.duration("DB_MAX_CONN_LIFETIME", 1*time.Hour),
+		DBMaxConnIdleTime:    env.duration("DB_MAX_CONN_IDLE_TIME", 30*time.Minute),
+		DBQueryExecMode:      env.string("DB_QUERY_EXEC_MODE", "cache_statement"),
+		DBStatementCacheSize: env.int("DB_STATEMENT_CACHE_SIZE", defaultStatementCacheSize),
+		DBConnectRetries:     env.int("DB_CONNECT_RETRIES", 5),
+		DBHealthInterval:     env.duration("DB_HEALTH_CHECK_INTERVAL", defaultHealthCheckInterval),
+		DBCircuitThreshold:   env.int("DB_CIRCUIT_FAILURE_THRESHOLD", defaultCircuitThreshold),
+		DBCircuitCooldown:    env.duration("DB_CIRCUIT_COOLDOWN", defaultCircuitCooldown),
+		RunMigrations:        env.bool("RUN_MIGRATIONS", false),
+
+		RedisURL:     env.string("REDIS_URL", ""),
+		UserCacheTTL: env.duration("USER_CACHE_TTL", defaultUserCacheTTL),
+
+		FeatureFlagRefresh: env.duration("FEATURE_FLAG_REFRESH_INTERVAL", defaultFeatureFlagRefresh),
+
+		JWTSecret:       env.string("JWT_SECRET", ""),
+		JWTExpiresIn:    env.duration("JWT_EXPIRES_IN", defaultTokenTTL),
+		RefreshTokenTTL: env.duration("REFRESH_TOKEN_TTL", defaultRefreshTokenTTL),
+		BcryptCost:      env.int("BCRYPT_COST", bcrypt.DefaultCost),
+
+		LoginMaxAttempts:     env.int("LOGIN_MAX_ATTEMPTS", defaultLoginMaxAttempts),
+		LoginLockoutDuration: env.duration("LOGIN_LOCKOUT_DURATION", defaultLoginLockoutDuration),
+
+		PublicURL:            env.string("PUBLIC_URL", ""),
+		SMTPHost:             env.string("SMTP_HOST", ""),
+		SMTPPort:             env.int("SMTP_PORT", defaultSMTPPort),
+		SMTPUser:             env.string("SMTP_USER", ""),
+		SMTPPass:             env.string("SMTP_PASS", ""),
+		SMTPFrom:             env.string("SMTP_FROM", defaultEmailFrom),
+		EmailVerificationTTL: env.duration("EMAIL_VERIFICATION_TTL", defaultEmailVerificationTTL),
+		PasswordResetURL:     env.string("PASSWORD_RESET_URL", ""),
+		PasswordResetTTL:     env.duration("PASSWORD_RESET_TTL", defaultPasswordResetTTL),
+
+		UserImportMaxBytes: env.int("USER_IMPORT_MAX_BYTES", defaultUserImportMaxBytes),
+		UserBatchMaxSize:   env.int("USER_BATCH_MAX_SIZE", defaultUserBatchMaxSize),
+
+		IdempotencyKeyTTL: env.duration("IDEMPOTENCY_KEY_TTL", defaultIdempotencyKeyTTL),
+
+		AttachmentStorage:      strings.ToLower(env.string("ATTACHMENT_STORAGE", storageBackendLocal)),
+		AttachmentDir:          env.string("ATTACHMENT_DIR", "uploads"),
+		AttachmentBaseURL:      env.string("ATTACHMENT_BASE_URL", "/uploads"),
+		AttachmentMaxBytes:     env.int("ATTACHMENT_MAX_BYTES", defaultAttachmentMaxBytes),
+		AttachmentAllowedTypes: parseList(env.string("ATTACHMENT_ALLOWED_TYPES", defaultAttachmentTypes)),
+		S3Endpoint:             env.string("S3_ENDPOINT", ""),
+		S3Region:               env.string("S3_REGION", ""),
+		S3Bucket:               env.string("S3_BUCKET", ""),
+		S3AccessKey:            env.string("S3_ACCESS_KEY", ""),
+		S3SecretKey:            env.string("S3_SECRET_KEY", ""),
+		S3UseSSL:               env.bool("S3_USE_SSL", true),
+		S3PublicURL:            env.string("S3_PUBLIC_URL", ""),
+
+		CORSAllowedOrigins: parseOrigins(env.string("CORS_ALLOWED_ORIGINS", "")),
+		TrustedProxies:     parseList(env.string("TRUSTED_PROXIES", "")),
+		RateLimitRPS:       env.float("RATE_LIMIT_RPS", 10),
+		RateLimitBurst:     env.int("RATE_LIMIT_BURST", 20),
+		RateLimitUserRPS:   env.float("RATE_LIMIT_USER_RPS", 10),
+		RateLimitUserBurst: env.int("RATE_LIMIT_USER_BURST", 20),
+	}
+
+	if cfg.DatabaseURL == "" {
+		host := os.Getenv("DATABASE_HOST")
+		name := os.Getenv("DATABASE_NAME")
+		if host == "" || name == "" {
+			env.fail("DATABASE_URL or DATABASE_HOST and DATABASE_NAME must be set")
+		} else {
+			cfg.DatabaseURL = (&url.URL{
+				Scheme: "postgres",
+				User:   url.UserPassword(os.Getenv("DATABASE_USERNAME"), os.Getenv("DATABASE_PASSWORD")),
+				Host:   net.JoinHostPort(host, env.string("DATABASE_PORT", "5432")),
+				Path:   "/" + name,
+			}).String()
+		}
+	}
+
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		env.fail("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	} else if cfg.TLSEnabled() {
+		// Load the pair now so a bad path or mismatched key fails at startup
+		// rather than when the listener starts.
+		if _, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile); err != nil {
+			env.fail("TLS_CERT_FILE/TLS_KEY_FILE: %v", err)
+		}
+	}
+	if cfg.HTTPRedirectPort != "" && !cfg.TLSEnabled() {
+		env.fail("HTTP_REDIRECT_PORT requires TLS_CERT_FILE and TLS_KEY_FILE")
+	}
+	if cfg.GRPCPort != "" && cfg.GRPCPort == cfg.Port {
+		env.fail("GRPC_PORT must differ from API_PORT")
+	}
+	if cfg.MaintenanceRetryAfter <= 0 {
+		env.fail("MAINTENANCE_RETRY_AFTER must be positive")
+	}
+	if cfg.MaxRequestBytes < 1 {
+		env.fail("MAX_REQUEST_BYTES must be positive (got %d)", cfg.MaxRequestBytes)
+	}
+	if cfg.GzipMinSize < 0 {
+		env.fail("GZIP_MIN_SIZE must not be negative")
+	}
+	if cfg.DBMaxConns < 1 || cfg.DBMinConns < 0 || cfg.DBMaxConns < cfg.DBMinConns {
+		env.fail("DB_MAX_CONNS must be >= 1 and >= DB_MIN_CONNS (got max=%d min=%d)", cfg.DBMaxConns, cfg.DBMinConns)
+	}
+	if _, ok := queryExecModes[cfg.DBQueryExecMode]; !ok {
+		env.fail("DB_QUERY_EXEC_MODE must be one of cache_statement, cache_describe, describe_exec, exec or simple_protocol")
+	}
+	if cfg.DBStatementCacheSize < 1 {
+		env.fail("DB_STATEMENT_CACHE_SIZE must be positive")
+	}
+	if cfg.DBConnectRetries < 1 {
+		cfg.DBConnectRetries = 1
+	}
+	if cfg.DBHealthInterval <= 0 {
+		env.fail("DB_HEALTH_CHECK_INTERVAL must be positive")
+	}
+	if cfg.DBCircuitThreshold < 0 {
+		env.fail("DB_CIRCUIT_FAILURE_THRESHOLD must be >= 0 (got %d)", cfg.DBCircuitThreshold)
+	}
+	if cfg.DBCircuitCooldown <= 0 {
+		env.fail("DB_CIRCUIT_COOLDOWN must be positive")
+	}
+	if cfg.UserCacheTTL <= 0 {
+		env.fail("USER_CACHE_TTL must be positive")
+	}
+	if cfg.FeatureFlagRefresh <= 0 {
+		env.fail("FEATURE_FLAG_REFRESH_INTERVAL must be positive")
+	}
+	if cfg.JWTSecret == "" {
+		env.fail("JWT_SECRET must be set")
+	}
+	if cfg.RefreshTokenTTL <= cfg.JWTExpiresIn {
+		env.fail("REFRESH_TOKEN_TTL must be longer than JWT_EXPIRES_IN")
+	}
+	if cfg.BcryptCost < bcrypt.MinCost || cfg.BcryptCost > bcrypt.MaxCost {
+		env.fail("BCRYPT_COST must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+	if cfg.OTLPEndpoint != "" {
+		if u, err := url.Parse(cfg.OTLPEndpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			env.fail("OTEL_EXPORTER_OTLP_ENDPOINT must be an http(s) URL, got %q", cfg.OTLPEndpoint)
+		}
+	}
+	if cfg.PublicURL == "" {
+		scheme := "http"
+		if cfg.TLSEnabled() {
+			scheme = "https"
+		}
+		cfg.PublicURL = scheme + "://localhost:" + cfg.Port
+	} else if u, err := url.Parse(cfg.PublicURL); err != nil || u.Scheme == "" || u.Host == "" {
+		env.fail("PUBLIC_URL must be an absolute URL, got %q", cfg.PublicURL)
+	}
+	if cfg.PasswordResetURL == "" {
+		cfg.PasswordResetURL = strings.TrimRight(cfg.PublicURL, "/") + "/reset-password"
+	} else if u, err := url.Parse(cfg.PasswordResetURL); err != nil || u.Scheme == "" || u.Host == "" {
+		env.fail("PASSWORD_RESET_URL must be an absolute URL, got %q", cfg.PasswordResetURL)
+	}
+	if cfg.SMTPPort < 1 || cfg.SMTPPort > 65535 {
+		env.fail("SMTP_PORT must be between 1 and 65535")
+	}
+	if (cfg.SMTPUser == "") != (cfg.SMTPPass == "") {
+		env.fail("SMTP_USER and SMTP_PASS must be set together")
+	}
+	if _, err := mail.ParseAddress(cfg.SMTPFrom); err != nil {
+		env.fail("SMTP_FROM must be an email address: %v", err)
+	}
+	if cfg.RateLimitRPS < 0 || cfg.RateLimitUserRPS < 0 {
+		env.fail("RATE_LIMIT_RPS and RATE_LIMIT_USER_RPS must not be negative")
+	}
+	if (cfg.RateLimitRPS > 0 && cfg.RateLimitBurst < 1) || (cfg.RateLimitUserRPS > 0 && cfg.RateLimitUserBurst < 1) {
+		env.fail("RATE_LIMIT_BURST and RATE_LIMIT_USER_BURST must be positive when their limit is on")
+	}
+	if cfg.LoginMaxAttempts < 0 {
+		env.fail("LOGIN_MAX_ATTEMPTS must not be negative")
+	}
+	if cfg.UserImportMaxBytes < 1 {
+		env.fail("USER_IMPORT_MAX_BYTES must be positive")
+	}
+	if cfg.UserBatchMaxSize < 1 {
+		env.fail("USER_BATCH_MAX_SIZE must be positive")
+	}
+	if cfg.IdempotencyKeyTTL <= 0 {
+		env.fail("IDEMPOTENCY_KEY_TTL must be positive")
+	}
+	switch cfg.AttachmentStorage {
+	case storageBackendLocal:
+	case storageBackendS3:
+		if cfg.S3Endpoint == "" || cfg.S3Bucket == "" {
+			env.fail("S3_ENDPOINT and S3_BUCKET must be set when ATTACHMENT_STORAGE=s3")
+		}
+	default:
+		env.fail("ATTACHMENT_STORAGE must be %s or %s", storageBackendLocal, storageBackendS3)
+	}
+	if cfg.AttachmentMaxBytes < 1 {
+		env.fail("ATTACHMENT_MAX_BYTES must be positive")
+	}
+	if gin.Mode() == gin.ReleaseMode {
+		for _, o := range cfg.CORSAllowedOrigins {
+			if o == "*" {
+				env.fail("CORS_ALLOWED_ORIGINS must list explicit origins in release mode")
+			}
+		}
+	}
+
+	if err := errors.Join(env.errs...); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// TLSEnabled reports whether the server should terminate TLS itself.
+func (cfg *Config) TLSEnabled() bool {
+	return cfg.TLSCertFile != "" && cfg.TLSKeyFile != ""
+}
+
+// envReader reads typed values from the environment, collecting parse
+// errors instead of failing on the first one.
+type envReader struct {
+	errs []error
+}
+
+func (r *envReader) fail(format string, args ...any) {
+	r.errs = append(r.errs, fmt.Errorf(format, args...))
+}
+
+func (r *envReader) string(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func (r *envReader) int(key string, fallback int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		r.fail("%s must be an integer, got %q", key, v)
+		return fallback
+	}
+	return n
+}
+
+func (r *envReader) float(key string, fallback float64) float64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		r.fail("%s must be a number, got %q", key, v)
+		return fallback
+	}
+	return f
+}
+
+func (r *envReader) duration(key string, fallback time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		r.fail("%s must be a positive duration such as 30s or 1h, got %q", key, v)
+		return fallback
+	}
+	return d
+}
+
+func (r *envReader) bool(key string, fallback bool) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		r.fail("%s must be true or false, got %q", key, v)
+		return fallback
+	}
+	return b
+}
+
+// parseOrigins splits CORS_ALLOWED_ORIGINS, normalising trailing slashes.
+func parseOrigins(value string) []string {
+	var origins []string
+	for _, o := range parseList(value) {
+		origins = append(origins, strings.TrimRight(o, "/"))
+	}
+	return origins
+}
+
+// parseList splits a comma-separated environment value, dropping blanks.
+func parseList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
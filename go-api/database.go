@@ -2,51 +2,137 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
-	"os"
+	"math"
+	"net/http"
+	"strconv"
 	"time"
 
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/multitracer"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-var dbPool *pgxpool.Pool
-
-func ConnectDB() {
-	dbURL := os.Getenv("DATABASE_URL")
-	if dbURL == "" {
-		host := os.Getenv("DATABASE_HOST")
-		user := os.Getenv("DATABASE_USERNAME")
-		pass := os.Getenv("DATABASE_PASSWORD")
-		dbName := os.Getenv("DATABASE_NAME")
-		port := os.Getenv("DATABASE_PORT")
-		if port == "" {
-			port = "5432"
-		}
+const (
+	defaultQueryTimeout   = 5 * time.Second
+	initialConnectBackoff = 1 * time.Second
+	maxConnectBackoff     = 30 * time.Second
+)
 
-		dbURL = fmt.Sprintf("postgres://%s:%s@%s:%s/%s", user, pass, host, port, dbName)
-	}
+// querier is the subset of methods shared by *pgxpool.Pool and pgx.Tx, for
+// helpers that run either standalone or inside a transaction.
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+var (
+	dbPool       *pgxpool.Pool
+	queryTimeout = defaultQueryTimeout
+)
+
+// ConnectDB opens the shared pool described by cfg, retrying with exponential
+// backoff because Postgres is often still starting when the container comes up.
+func ConnectDB(cfg *Config) error {
+	queryTimeout = cfg.DBQueryTimeout
 
-	config, err := pgxpool.ParseConfig(dbURL)
+	config, err := pgxpool.ParseConfig(cfg.DatabaseURL)
 	if err != nil {
-		log.Fatalf("Unable to parse database URL: %v", err)
+		return fmt.Errorf("parse database URL: %w", err)
+	}
+
+	config.MaxConns = int32(cfg.DBMaxConns)
+	config.MinConns = int32(cfg.DBMinConns)
+	config.MaxConnLifetime = cfg.DBMaxConnLifetime
+	config.MaxConnIdleTime = cfg.DBMaxConnIdleTime
+	// These replace any default_query_exec_mode or statement_cache_capacity
+	// in DATABASE_URL, so the mode the debug endpoint reports is the one in
+	// use.
+	config.ConnConfig.DefaultQueryExecMode = queryExecModes[cfg.DBQueryExecMode]
+	config.ConnConfig.StatementCacheCapacity = cfg.DBStatementCacheSize
+	config.ConnConfig.DescriptionCacheCapacity = cfg.DBStatementCacheSize
+	statementCache.mode = cfg.DBQueryExecMode
+	statementCache.capacity = cfg.DBStatementCacheSize
+	// Sessions run in UTC so now() and date arithmetic in SQL agree with the
+	// API, and timestamps scan as UTC so JSON carries a Z offset whatever the
+	// host's zone.
+	config.ConnConfig.RuntimeParams["timezone"] = "UTC"
+	config.AfterConnect = scanTimestampsAsUTC
+	tracers := []pgx.QueryTracer{&statementCache}
+	if cfg.OTLPEndpoint != "" {
+		tracers = append(tracers, queryTracer{})
+	}
+	if cfg.DBCircuitThreshold > 0 {
+		config.PrepareConn = circuitPrepareConn
+		tracers = append(tracers, circuitTracer{})
+	}
+	if len(tracers) == 1 {
+		config.ConnConfig.Tracer = tracers[0]
+	} else {
+		config.ConnConfig.Tracer = multitracer.New(tracers...)
+	}
+	logger.Info("Database pool configured",
+		"query_exec_mode", cfg.DBQueryExecMode,
+		"statement_cache_size", cfg.DBStatementCacheSize,
+		"max_conns", config.MaxConns,
+		"min_conns", config.MinConns,
+		"max_conn_lifetime", config.MaxConnLifetime.String(),
+		"max_conn_idle_time", config.MaxConnIdleTime.String())
+
+	delay := initialConnectBackoff
+	var pool *pgxpool.Pool
+	for attempt := 1; ; attempt++ {
+		pool, err = connectPool(config)
+		if err == nil {
+			break
+		}
+		if attempt >= cfg.DBConnectRetries {
+			return fmt.Errorf("connect to database after %d attempts: %w", attempt, err)
+		}
+		logger.Warn("Database connection attempt failed",
+			"attempt", attempt, "max_attempts", cfg.DBConnectRetries, "retry_in", delay.String(), "error", err)
+		time.Sleep(delay)
+		delay = min(delay*2, maxConnectBackoff)
 	}
 
-	config.MaxConns = 10
-	config.MaxConnLifetime = 1 * time.Hour
+	dbPool = pool
+	if cfg.DBCircuitThreshold > 0 {
+		dbBreaker.Store(newCircuitBreaker(cfg.DBCircuitThreshold, cfg.DBCircuitCooldown))
+	}
+	logger.Info("Successfully connected to the PostgreSQL database")
+	return nil
+}
+
+// scanTimestampsAsUTC makes timestamptz values scan into time.Time in UTC
+// rather than time.Local.
+func scanTimestampsAsUTC(_ context.Context, conn *pgx.Conn) error {
+	conn.TypeMap().RegisterType(&pgtype.Type{
+		Name:  "timestamptz",
+		OID:   pgtype.TimestamptzOID,
+		Codec: &pgtype.TimestamptzCodec{ScanLocation: time.UTC},
+	})
+	return nil
+}
 
+// connectPool opens a pool and verifies it with a ping, closing it again if
+// the database is not reachable.
+func connectPool(config *pgxpool.Config) (*pgxpool.Pool, error) {
 	pool, err := pgxpool.NewWithConfig(context.Background(), config)
 	if err != nil {
-		log.Fatalf("Unable to connect to database: %v", err)
+		return nil, err
 	}
 
 	// Test connection
 	if err := pool.Ping(context.Background()); err != nil {
-		log.Fatalf("Unable to ping database: %v", err)
+		pool.Close()
+		return nil, err
 	}
-
-	dbPool = pool
-	log.Println("Successfully connected to the PostgreSQL database")
+	return pool, nil
 }
 
 func CloseDB() {
@@ -54,3 +140,101 @@ func CloseDB() {
 		dbPool.Close()
 	}
 }
+
+// WithTx runs fn inside a transaction on the shared pool, committing if it
+// returns nil. Any error, or a panic, rolls the transaction back; panics are
+// re-raised after the rollback so Recovery still reports them.
+func WithTx(ctx context.Context, fn func(pgx.Tx) error) (err error) {
+	tx, err := dbPool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback(context.WithoutCancel(ctx))
+			panic(p)
+		}
+		if err != nil {
+			// The rollback error is secondary; fn's error is the one to report.
+			tx.Rollback(context.WithoutCancel(ctx))
+		}
+	}()
+
+	if err = fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// WithDryRunTx is WithTx when dryRun is false. When it is true fn runs the
+// same way, but the transaction is always rolled back, so a caller can report
+// exactly what fn would have done without keeping any of it.
+func WithDryRunTx(ctx context.Context, dryRun bool, fn func(pgx.Tx) error) error {
+	if !dryRun {
+		return WithTx(ctx, fn)
+	}
+
+	tx, err := dbPool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback(context.WithoutCancel(ctx))
+			panic(p)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		tx.Rollback(context.WithoutCancel(ctx))
+		return err
+	}
+	return tx.Rollback(ctx)
+}
+
+// dryRunRequested reports whether the request asked for a preview with
+// ?dryRun=true.
+func dryRunRequested(c *gin.Context) bool {
+	return c.Query("dryRun") == "true"
+}
+
+// queryContext derives a context for a single request's database work. It is
+// canceled when the client goes away or queryTimeout elapses, whichever is
+// first. Routes wrapped in Timeout use that route's duration instead.
+func queryContext(c *gin.Context) (context.Context, context.CancelFunc) {
+	timeout := queryTimeout
+	if d, ok := c.Get(routeTimeoutKey); ok {
+		timeout = d.(time.Duration)
+	}
+	return context.WithTimeout(c.Request.Context(), timeout)
+}
+
+// statusClientClosedRequest is nginx's status for a request the client
+// abandoned before the response.
+const statusClientClosedRequest = 499
+
+func isQueryTimeout(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded)
+}
+
+// respondQueryError writes the error response for a failed query, reporting
+// deadline overruns as 504 and an open circuit breaker as 503 rather than a
+// generic 500.
+func respondQueryError(c *gin.Context, err error, msg string) {
+	// The client hung up, which canceled the query. Nobody will read a
+	// response; the status only feeds the access log and metrics.
+	if errors.Is(err, context.Canceled) && c.Request.Context().Err() != nil {
+		c.AbortWithStatus(statusClientClosedRequest)
+		return
+	}
+	if errors.Is(err, errCircuitOpen) {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(dbBreaker.Load().cooldown.Seconds()))))
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeDBCircuitOpen, "Database is unavailable; try again shortly")
+		return
+	}
+	if isQueryTimeout(err) {
+		RespondError(c, http.StatusGatewayTimeout, ErrCodeDBTimeout, "Database query timed out")
+		return
+	}
+	RespondError(c, http.StatusInternalServerError, ErrCodeDBError, msg)
+}
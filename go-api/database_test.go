@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// insertOrg adds an organization with slug inside tx.
+func insertOrg(ctx context.Context, tx pgx.Tx, slug string) error {
+	_, err := tx.Exec(ctx, "INSERT INTO organizations (name, slug) VALUES ($1, $1)", slug)
+	return err
+}
+
+// orgExists reports whether an organization with slug is visible outside
+// any transaction.
+func orgExists(t *testing.T, ctx context.Context, slug string) bool {
+	t.Helper()
+	var exists bool
+	if err := dbPool.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM organizations WHERE slug = $1)", slug).Scan(&exists); err != nil {
+		t.Fatalf("look up organization: %v", err)
+	}
+	return exists
+}
+
+func TestWithTxCommits(t *testing.T) {
+	ctx := testDB(t)
+	slug := uniqueName("tx-")
+
+	if err := WithTx(ctx, func(tx pgx.Tx) error { return insertOrg(ctx, tx, slug) }); err != nil {
+		t.Fatalf("WithTx: %v", err)
+	}
+	if !orgExists(t, ctx, slug) {
+		t.Error("write was not committed")
+	}
+}
+
+func TestWithTxRollsBackOnError(t *testing.T) {
+	ctx := testDB(t)
+	slug := uniqueName("tx-")
+	errFn := errors.New("fn failed")
+
+	err := WithTx(ctx, func(tx pgx.Tx) error {
+		if err := insertOrg(ctx, tx, slug); err != nil {
+			return err
+		}
+		return errFn
+	})
+	if !errors.Is(err, errFn) {
+		t.Fatalf("WithTx = %v, want fn's error", err)
+	}
+	if orgExists(t, ctx, slug) {
+		t.Error("write survived the error")
+	}
+}
+
+func TestWithTxRollsBackOnPanic(t *testing.T) {
+	ctx := testDB(t)
+	slug := uniqueName("tx-")
+
+	func() {
+		defer func() {
+			if p := recover(); p != "fn panicked" {
+				t.Errorf("recovered %v, want fn's panic re-raised", p)
+			}
+		}()
+		WithTx(ctx, func(tx pgx.Tx) error {
+			if err := insertOrg(ctx, tx, slug); err != nil {
+				t.Fatalf("insert: %v", err)
+			}
+			panic("fn panicked")
+		})
+	}()
+	if orgExists(t, ctx, slug) {
+		t.Error("write survived the panic")
+	}
+}
+
+func TestWithDryRunTxRollsBack(t *testing.T) {
+	ctx := testDB(t)
+	slug := uniqueName("dry-")
+
+	err := WithDryRunTx(ctx, true, func(tx pgx.Tx) error {
+		if err := insertOrg(ctx, tx, slug); err != nil {
+			return err
+		}
+		// fn sees its own writes, as it would in a real run.
+		var exists bool
+		if err := tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM organizations WHERE slug = $1)", slug).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			t.Error("write not visible inside the dry run")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithDryRunTx = %v, want nil", err)
+	}
+	if orgExists(t, ctx, slug) {
+		t.Error("dry run kept its write")
+	}
+}
+
+func TestWithDryRunTxReturnsFnError(t *testing.T) {
+	ctx := testDB(t)
+	slug := uniqueName("dry-")
+	errFn := errors.New("fn failed")
+
+	err := WithDryRunTx(ctx, true, func(tx pgx.Tx) error {
+		if err := insertOrg(ctx, tx, slug); err != nil {
+			return err
+		}
+		return errFn
+	})
+	if !errors.Is(err, errFn) {
+		t.Fatalf("WithDryRunTx = %v, want fn's error", err)
+	}
+	if orgExists(t, ctx, slug) {
+		t.Error("write survived the error")
+	}
+}
+
+func TestWithDryRunTxRollsBackOnPanic(t *testing.T) {
+	ctx := testDB(t)
+	slug := uniqueName("dry-")
+
+	func() {
+		defer func() {
+			if p := recover(); p != "fn panicked" {
+				t.Errorf("recovered %v, want fn's panic re-raised", p)
+			}
+		}()
+		WithDryRunTx(ctx, true, func(tx pgx.Tx) error {
+			if err := insertOrg(ctx, tx, slug); err != nil {
+				t.Fatalf("insert: %v", err)
+			}
+			panic("fn panicked")
+		})
+	}()
+	if orgExists(t, ctx, slug) {
+		t.Error("write survived the panic")
+	}
+}
+
+func TestWithDryRunTxCommitsWhenNotDry(t *testing.T) {
+	ctx := testDB(t)
+	slug := uniqueName("dry-")
+
+	if err := WithDryRunTx(ctx, false, func(tx pgx.Tx) error { return insertOrg(ctx, tx, slug) }); err != nil {
+		t.Fatalf("WithDryRunTx: %v", err)
+	}
+	if !orgExists(t, ctx, slug) {
+		t.Error("write was not committed")
+	}
+}
+
+func TestTimestampsRoundTripInUTC(t *testing.T) {
+	ctx := testDB(t)
+	user := testUser(t, ctx, testOrg(t, ctx), RoleStudent, "")
+	loggedIn := time.Date(2026, 3, 1, 16, 30, 0, 0, time.FixedZone("ICT", 7*60*60))
+	want := time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC)
+
+	// Write from a session in another zone: the bound instant and a bare
+	// literal, which Postgres reads in the session's zone, must both land on
+	// the same instant.
+	err := WithTx(ctx, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, "SET LOCAL TIME ZONE 'Asia/Ho_Chi_Minh'"); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx,
+			"UPDATE up_users SET last_login_at = $1, created_at = '2026-03-01 16:30:00' WHERE id = $2",
+			loggedIn, user.ID)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("write in Asia/Ho_Chi_Minh: %v", err)
+	}
+
+	var lastLogin, created time.Time
+	if err := dbPool.QueryRow(ctx, "SELECT last_login_at, created_at FROM up_users WHERE id = $1", user.ID).
+		Scan(&lastLogin, &created); err != nil {
+		t.Fatalf("read back: %v", err)
+	}
+	for _, got := range []struct {
+		column string
+		value  time.Time
+	}{{"last_login_at", lastLogin}, {"created_at", created}} {
+		if !got.value.Equal(want) {
+			t.Errorf("%s = %v, want %v", got.column, got.value, want)
+		}
+		if got.value.Location() != time.UTC {
+			t.Errorf("%s scanned in %v, want UTC", got.column, got.value.Location())
+		}
+		if b, _ := json.Marshal(got.value); string(b) != `"2026-03-01T09:30:00Z"` {
+			t.Errorf("%s encodes as %s, want a Z offset", got.column, b)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PoolStats is a snapshot of pgxpool.Stat for GET /debug/pool.
+type PoolStats struct {
+	MaxConns                int32   `json:"max_conns"`
+	TotalConns              int32   `json:"total_conns"`
+	IdleConns               int32   `json:"idle_conns"`
+	AcquiredConns           int32   `json:"acquired_conns"`
+	ConstructingConns       int32   `json:"constructing_conns"`
+	NewConnsCount           int64   `json:"new_conns_count"`
+	AcquireCount            int64   `json:"acquire_count"`
+	CanceledAcquireCount    int64   `json:"canceled_acquire_count"`
+	EmptyAcquireCount       int64   `json:"empty_acquire_count"`
+	AcquireDurationMs       float64 `json:"acquire_duration_ms"`
+	MaxLifetimeDestroyCount int64   `json:"max_lifetime_destroy_count"`
+	MaxIdleDestroyCount     int64   `json:"max_idle_destroy_count"`
+
+	StatementCache StatementCacheStats `json:"statement_cache"`
+}
+
+// StatementCacheStats reports the statement cache since startup. lookups
+// counts queries sent with arguments, prepares those that had to be prepared
+// first, so hit_ratio is the share that reused a cached statement.
+type StatementCacheStats struct {
+	Mode     string   `json:"mode" example:"cache_statement"`
+	Capacity int      `json:"capacity" example:"512"`
+	Lookups  int64    `json:"lookups"`
+	Prepares int64    `json:"prepares"`
+	HitRatio *float64 `json:"hit_ratio,omitempty"`
+}
+
+// DebugPool godoc
+// @Summary      Database pool statistics
+// @Description  Only registered when DEBUG_ENDPOINTS=true; otherwise 404. Admin only. empty_acquire_count counts acquires that had to wait for a connection, the first sign of pool exhaustion. statement_cache shows how often queries reused a prepared statement.
+// @Tags         debug
+// @Produce      json
+// @Success      200  {object}  PoolStats
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/debug/pool [get]
+func DebugPool(c *gin.Context) {
+	if dbPool == nil {
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+		return
+	}
+
+	stat := dbPool.Stat()
+	c.JSON(http.StatusOK, PoolStats{
+		MaxConns:                stat.MaxConns(),
+		TotalConns:              stat.TotalConns(),
+		IdleConns:               stat.IdleConns(),
+		AcquiredConns:           stat.AcquiredConns(),
+		ConstructingConns:       stat.ConstructingConns(),
+		NewConnsCount:           stat.NewConnsCount(),
+		AcquireCount:            stat.AcquireCount(),
+		CanceledAcquireCount:    stat.CanceledAcquireCount(),
+		EmptyAcquireCount:       stat.EmptyAcquireCount(),
+		AcquireDurationMs:       float64(stat.AcquireDuration().Microseconds()) / 1000,
+		MaxLifetimeDestroyCount: stat.MaxLifetimeDestroyCount(),
+		MaxIdleDestroyCount:     stat.MaxIdleDestroyCount(),
+		StatementCache:          statementCache.stats(),
+	})
+}
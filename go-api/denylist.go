@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// tokenDenylist records revoked access tokens by jti until they would have
+// expired anyway. Like responseCache, a nil *tokenDenylist is valid and
+// empty: without Redis, logout can only revoke the refresh token.
+type tokenDenylist struct {
+	client *redis.Client
+}
+
+// accessDenylist is checked by AuthRequired. It is nil unless REDIS_URL is set.
+var accessDenylist *tokenDenylist
+
+func denylistKey(jti string) string {
+	return "denylist:jti:" + jti
+}
+
+// add revokes jti until expiresAt. Already-expired tokens need no entry.
+func (d *tokenDenylist) add(ctx context.Context, jti string, expiresAt time.Time) error {
+	if d == nil {
+		return nil
+	}
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+	return d.client.Set(ctx, denylistKey(jti), 1, ttl).Err()
+}
+
+// contains reports whether jti has been revoked.
+func (d *tokenDenylist) contains(ctx context.Context, jti string) (bool, error) {
+	if d == nil {
+		return false, nil
+	}
+	err := d.client.Get(ctx, denylistKey(jti)).Err()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	return err == nil, err
+}
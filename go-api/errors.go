@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Stable, machine-readable error codes. Clients branch on these, so existing
+// values must never change meaning.
+const (
+	ErrCodeInvalidRequest       = "INVALID_REQUEST"
+	ErrCodeValidationFailed     = "VALIDATION_FAILED"
+	ErrCodeNotFound             = "NOT_FOUND"
+	ErrCodeFeatureDisabled      = "FEATURE_DISABLED"
+	ErrCodeConflict             = "CONFLICT"
+	ErrCodeEmailTaken           = "EMAIL_TAKEN"
+	ErrCodeUsernameTaken        = "USERNAME_TAKEN"
+	ErrCodeAlreadySubmitted     = "ALREADY_SUBMITTED"
+	ErrCodeAttemptsExhausted    = "ATTEMPTS_EXHAUSTED"
+	ErrCodeIdempotencyKeyReused = "IDEMPOTENCY_KEY_REUSED"
+	ErrCodeTimeExpired          = "TIME_EXPIRED"
+	ErrCodePayloadTooLarge      = "PAYLOAD_TOO_LARGE"
+	ErrCodeUnauthorized         = "UNAUTHORIZED"
+	ErrCodeTokenExpired         = "TOKEN_EXPIRED"
+	ErrCodeInvalidToken         = "INVALID_TOKEN"
+	ErrCodeInvalidCredentials   = "INVALID_CREDENTIALS"
+	ErrCodeAccountLocked        = "ACCOUNT_LOCKED"
+	ErrCodeForbidden            = "FORBIDDEN"
+	ErrCodeInsufficientScope    = "INSUFFICIENT_SCOPE"
+	ErrCodeEmailUnverified      = "EMAIL_UNVERIFIED"
+	ErrCodeRateLimited          = "RATE_LIMITED"
+	ErrCodeDBUnavailable        = "DB_UNAVAILABLE"
+	ErrCodeDBTimeout            = "DB_TIMEOUT"
+	ErrCodeDBCircuitOpen        = "DB_CIRCUIT_OPEN"
+	ErrCodeReadOnly             = "READ_ONLY"
+	ErrCodeMaintenance          = "MAINTENANCE"
+	ErrCodeTimeout              = "TIMEOUT"
+	ErrCodeDBError              = "DB_ERROR"
+	ErrCodeInternal             = "INTERNAL_ERROR"
+)
+
+type APIError struct {
+	Code    string `json:"code" example:"NOT_FOUND"`
+	Message string `json:"message" example:"User not found"`
+	Details any    `json:"details,omitempty" swaggertype:"object"`
+}
+
+// ErrorResponse is the body returned for every non-2xx response.
+type ErrorResponse struct {
+	Error APIError `json:"error"`
+}
+
+// RespondError aborts the request with the standard error envelope.
+func RespondError(c *gin.Context, status int, code, msg string) {
+	RespondErrorDetails(c, status, code, msg, nil)
+}
+
+// RespondErrorDetails is RespondError with extra structured details, such as
+// the per-field errors of a failed validation. The message is translated into
+// the language the client asked for; the code never is.
+func RespondErrorDetails(c *gin.Context, status int, code, msg string, details any) {
+	lang := requestLanguage(c)
+	msg, details = localize(lang, code, msg, details)
+	c.Header("Content-Language", lang.String())
+	c.Writer.Header().Add("Vary", "Accept-Language")
+	c.AbortWithStatusJSON(status, ErrorResponse{Error: APIError{Code: code, Message: msg, Details: details}})
+}
+
+// clientError carries a client-facing failure out of code that can't write
+// the response itself, typically a transaction callback that must roll back.
+type clientError struct {
+	status  int
+	code    string
+	msg     string
+	details any
+}
+
+func (e *clientError) Error() string { return e.msg }
+
+func newClientError(status int, code, msg string) *clientError {
+	return &clientError{status: status, code: code, msg: msg}
+}
+
+// respondClientError writes err if it is a *clientError and reports whether
+// it did.
+func respondClientError(c *gin.Context, err error) bool {
+	var ce *clientError
+	if !errors.As(err, &ce) {
+		return false
+	}
+	RespondErrorDetails(c, ce.status, ce.code, ce.msg, ce.details)
+	return true
+}
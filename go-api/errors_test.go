@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// serve runs handler for one GET request and returns the recorded response.
+func serve(t *testing.T, handler gin.HandlerFunc) *httptest.ResponseRecorder {
+	t.Helper()
+	r := gin.New()
+	r.GET("/", handler, func(c *gin.Context) {
+		t.Error("handler after the error response ran")
+	})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	return w
+}
+
+// decodeError parses w's body as the error envelope, failing on any other
+// top-level shape.
+func decodeError(t *testing.T, w *httptest.ResponseRecorder) map[string]json.RawMessage {
+	t.Helper()
+	if ct := w.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	var body map[string]map[string]json.RawMessage
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("body %s: %v", w.Body, err)
+	}
+	if len(body) != 1 || body["error"] == nil {
+		t.Fatalf("body %s is not {\"error\": {...}}", w.Body)
+	}
+	return body["error"]
+}
+
+func TestRespondError(t *testing.T) {
+	w := serve(t, func(c *gin.Context) {
+		RespondError(c, http.StatusNotFound, ErrCodeNotFound, "User not found")
+	})
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+	e := decodeError(t, w)
+	if string(e["code"]) != `"NOT_FOUND"` {
+		t.Errorf("code = %s", e["code"])
+	}
+	if string(e["message"]) != `"User not found"` {
+		t.Errorf("message = %s", e["message"])
+	}
+	if _, ok := e["details"]; ok {
+		t.Errorf("details = %s, want it omitted", e["details"])
+	}
+}
+
+func TestRespondErrorDetails(t *testing.T) {
+	fields := []FieldError{{Field: "email", Message: "must be a valid email address"}}
+	w := serve(t, func(c *gin.Context) {
+		RespondErrorDetails(c, http.StatusBadRequest, ErrCodeValidationFailed, "Validation failed", fields)
+	})
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	e := decodeError(t, w)
+	if string(e["code"]) != `"VALIDATION_FAILED"` {
+		t.Errorf("code = %s", e["code"])
+	}
+	if string(e["message"]) != `"Validation failed"` {
+		t.Errorf("message = %s", e["message"])
+	}
+	var details []FieldError
+	if err := json.Unmarshal(e["details"], &details); err != nil {
+		t.Fatalf("details %s: %v", e["details"], err)
+	}
+	if len(details) != 1 || details[0] != fields[0] {
+		t.Errorf("details = %+v, want %+v", details, fields)
+	}
+}
+
+func TestRespondClientError(t *testing.T) {
+	w := serve(t, func(c *gin.Context) {
+		ce := newClientError(http.StatusConflict, ErrCodeEmailTaken, "Email is already taken")
+		ce.details = gin.H{"email": "a@example.com"}
+		if !respondClientError(c, ce) {
+			t.Error("respondClientError didn't handle a *clientError")
+		}
+	})
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusConflict)
+	}
+	e := decodeError(t, w)
+	if string(e["code"]) != `"EMAIL_TAKEN"` {
+		t.Errorf("code = %s", e["code"])
+	}
+	if string(e["details"]) != `{"email":"a@example.com"}` {
+		t.Errorf("details = %s", e["details"])
+	}
+}
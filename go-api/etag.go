@@ -0,0 +1,113 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ETag tags successful GET responses with a hash of their body and answers a
+// matching If-None-Match with 304, so a polling client only downloads a
+// resource again once it has changed. The handler still runs on every
+// request; what's saved is the transfer.
+//
+// The tag is weak because Gzip, further out, may re-encode the same body.
+func ETag() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet {
+			c.Next()
+			return
+		}
+
+		ew := &etagWriter{ResponseWriter: c.Writer}
+		c.Writer = ew
+		defer func() { c.Writer = ew.ResponseWriter }()
+
+		c.Next()
+		ew.finish(c.GetHeader("If-None-Match"))
+	}
+}
+
+// etagWriter holds the body back until the handler is done, so it can be
+// hashed before anything is sent. A handler that flushes is streaming, and
+// is passed through untagged.
+type etagWriter struct {
+	gin.ResponseWriter
+	buf       []byte
+	streaming bool
+}
+
+func (w *etagWriter) Write(p []byte) (int, error) {
+	if w.streaming {
+		return w.ResponseWriter.Write(p)
+	}
+	w.buf = append(w.buf, p...)
+	return len(p), nil
+}
+
+func (w *etagWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Written counts buffered bytes, like gzipWriter, so Timeout and Recovery
+// don't write a second response.
+func (w *etagWriter) Written() bool {
+	return len(w.buf) > 0 || w.ResponseWriter.Written()
+}
+
+func (w *etagWriter) Flush() {
+	w.release()
+	w.ResponseWriter.Flush()
+}
+
+// release sends whatever is buffered and passes later writes straight on.
+func (w *etagWriter) release() {
+	if w.streaming {
+		return
+	}
+	w.streaming = true
+	if len(w.buf) > 0 {
+		w.ResponseWriter.Write(w.buf)
+	}
+	w.buf = nil
+}
+
+// finish tags a 200 and either sends it or, when the client already has it,
+// replaces it with an empty 304.
+func (w *etagWriter) finish(ifNoneMatch string) {
+	if w.streaming || w.Status() != http.StatusOK || w.ResponseWriter.Written() {
+		w.release()
+		return
+	}
+
+	sum := sha256.Sum256(w.buf)
+	tag := `W/"` + hex.EncodeToString(sum[:16]) + `"`
+	h := w.Header()
+	h.Set("ETag", tag)
+	if !etagMatches(ifNoneMatch, tag) {
+		w.release()
+		return
+	}
+
+	h.Del("Content-Type")
+	h.Del("Content-Length")
+	w.buf = nil
+	w.ResponseWriter.WriteHeader(http.StatusNotModified)
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+// etagMatches applies If-None-Match's weak comparison: W/ prefixes are
+// ignored and "*" matches any current representation.
+func etagMatches(header, tag string) bool {
+	tag = strings.TrimPrefix(tag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == tag {
+			return true
+		}
+	}
+	return false
+}
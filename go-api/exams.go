@@ -0,0 +1,322 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+const examColumns = "id, title, description, duration_minutes, allow_retakes, max_attempts, shuffle_questions, shuffle_options, created_by, created_at"
+
+func scanExam(row pgx.Row, exam *Exam) error {
+	return row.Scan(&exam.ID, &exam.Title, &exam.Description, &exam.DurationMinutes, &exam.AllowRetakes, &exam.MaxAttempts, &exam.ShuffleQuestions, &exam.ShuffleOptions, &exam.CreatedBy, &exam.CreatedAt)
+}
+
+// GetExams godoc
+// @Summary      List exams
+// @Tags         exams
+// @Produce      json
+// @Param        limit   query  int  false  "Page size (default 20, max 100)"
+// @Param        offset  query  int  false  "Rows to skip"
+// @Param        If-None-Match  header  string  false  "ETag from an earlier response"
+// @Success      200  {array}   Exam
+// @Header       200  {string}  ETag  "Weak hash of the body"
+// @Success      304
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/exams [get]
+func GetExams(c *gin.Context) {
+	if dbPool == nil {
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+		return
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	limit, offset, err := parsePagination(c)
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, err.Error())
+		return
+	}
+
+	rows, err := dbPool.Query(ctx,
+		"SELECT "+examColumns+" FROM exams WHERE org_id = $1 ORDER BY id LIMIT $2 OFFSET $3", orgID(c), limit, offset)
+	if err != nil {
+		requestLogger(c).Error("Failed to query exams", "error", err)
+		respondQueryError(c, err, "Failed to fetch exams")
+		return
+	}
+	defer rows.Close()
+
+	exams := []Exam{}
+	for rows.Next() {
+		var exam Exam
+		if err := scanExam(rows, &exam); err != nil {
+			requestLogger(c).Error("Failed to scan exam row", "error", err)
+			continue
+		}
+		exams = append(exams, exam)
+	}
+
+	if err := rows.Err(); err != nil {
+		requestLogger(c).Error("Failed to iterate exam rows", "error", err)
+		respondQueryError(c, err, "Error reading exams")
+		return
+	}
+
+	c.JSON(http.StatusOK, exams)
+}
+
+// GetExamByID godoc
+// @Summary      Get an exam
+// @Description  When the exam limits attempts, remaining_attempts says how many more times the caller may submit it.
+// @Tags         exams
+// @Produce      json
+// @Param        id   path      int  true  "Exam ID"
+// @Param        If-None-Match  header  string  false  "ETag from an earlier response"
+// @Success      200  {object}  Exam
+// @Header       200  {string}  ETag  "Weak hash of the body"
+// @Success      304
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/exams/{id} [get]
+func GetExamByID(c *gin.Context) {
+	if dbPool == nil {
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+		return
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid exam id")
+		return
+	}
+
+	var exam Exam
+	err = scanExam(dbPool.QueryRow(ctx, "SELECT "+examColumns+" FROM exams WHERE id = $1 AND org_id = $2", id, orgID(c)), &exam)
+	if errors.Is(err, pgx.ErrNoRows) {
+		RespondError(c, http.StatusNotFound, ErrCodeNotFound, "Exam not found")
+		return
+	}
+	if err != nil {
+		requestLogger(c).Error("Failed to query exam", "exam_id", id, "error", err)
+		respondQueryError(c, err, "Failed to fetch exam")
+		return
+	}
+
+	if claims, ok := currentClaims(c); ok {
+		if limit := attemptsAllowed(exam.AllowRetakes, exam.MaxAttempts); limit > 0 {
+			used, err := countAttempts(ctx, dbPool, id, claims.UserID)
+			if err != nil {
+				requestLogger(c).Error("Failed to count attempts", "exam_id", id, "error", err)
+				respondQueryError(c, err, "Failed to fetch exam")
+				return
+			}
+			remaining := max(limit-used, 0)
+			exam.RemainingAttempts = &remaining
+		}
+	}
+
+	c.JSON(http.StatusOK, exam)
+}
+
+// CreateExam godoc
+// @Summary      Create an exam
+// @Description  Teacher or admin only. The caller is recorded as created_by.
+// @Tags         exams
+// @Accept       json
+// @Produce      json
+// @Param        exam  body      CreateExamRequest  true  "Exam to create"
+// @Success      201   {object}  Exam
+// @Failure      400   {object}  ErrorResponse
+// @Failure      401   {object}  ErrorResponse
+// @Failure      403   {object}  ErrorResponse
+// @Failure      500   {object}  ErrorResponse
+// @Failure      503   {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/exams [post]
+func CreateExam(c *gin.Context) {
+	if dbPool == nil {
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+		return
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	var req CreateExamRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindingError(c, err)
+		return
+	}
+
+	var createdBy *int
+	if claims, ok := currentClaims(c); ok {
+		createdBy = &claims.UserID
+	}
+
+	var exam Exam
+	err := scanExam(dbPool.QueryRow(ctx,
+		`INSERT INTO exams (title, description, duration_minutes, allow_retakes, max_attempts,
+			shuffle_questions, shuffle_options, created_by, org_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING `+examColumns,
+		req.Title, req.Description, req.DurationMinutes, req.AllowRetakes, req.MaxAttempts,
+		req.ShuffleQuestions, req.ShuffleOptions, createdBy, orgID(c)), &exam)
+	if err != nil {
+		requestLogger(c).Error("Failed to insert exam", "error", err)
+		respondQueryError(c, err, "Failed to create exam")
+		return
+	}
+
+	c.JSON(http.StatusCreated, exam)
+}
+
+// UpdateExam godoc
+// @Summary      Update an exam
+// @Description  Only the fields present in the body are changed. Teacher or admin only.
+// @Tags         exams
+// @Accept       json
+// @Produce      json
+// @Param        id    path      int                true  "Exam ID"
+// @Param        exam  body      UpdateExamRequest  true  "Fields to change"
+// @Success      200   {object}  Exam
+// @Failure      400   {object}  ErrorResponse
+// @Failure      401   {object}  ErrorResponse
+// @Failure      403   {object}  ErrorResponse
+// @Failure      404   {object}  ErrorResponse
+// @Failure      500   {object}  ErrorResponse
+// @Failure      503   {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/exams/{id} [patch]
+func UpdateExam(c *gin.Context) {
+	if dbPool == nil {
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+		return
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid exam id")
+		return
+	}
+
+	var req UpdateExamRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindingError(c, err)
+		return
+	}
+
+	var sets []string
+	var args []any
+	if req.Title != nil {
+		args = append(args, *req.Title)
+		sets = append(sets, "title = $"+strconv.Itoa(len(args)))
+	}
+	if req.Description != nil {
+		args = append(args, *req.Description)
+		sets = append(sets, "description = $"+strconv.Itoa(len(args)))
+	}
+	if req.DurationMinutes != nil {
+		args = append(args, *req.DurationMinutes)
+		sets = append(sets, "duration_minutes = $"+strconv.Itoa(len(args)))
+	}
+	if req.AllowRetakes != nil {
+		args = append(args, *req.AllowRetakes)
+		sets = append(sets, "allow_retakes = $"+strconv.Itoa(len(args)))
+	}
+	if req.MaxAttempts != nil {
+		args = append(args, *req.MaxAttempts)
+		sets = append(sets, "max_attempts = $"+strconv.Itoa(len(args)))
+	}
+	if req.ShuffleQuestions != nil {
+		args = append(args, *req.ShuffleQuestions)
+		sets = append(sets, "shuffle_questions = $"+strconv.Itoa(len(args)))
+	}
+	if req.ShuffleOptions != nil {
+		args = append(args, *req.ShuffleOptions)
+		sets = append(sets, "shuffle_options = $"+strconv.Itoa(len(args)))
+	}
+	if len(sets) == 0 {
+		RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "No updatable fields provided")
+		return
+	}
+
+	args = append(args, id, orgID(c))
+	query := "UPDATE exams SET " + strings.Join(sets, ", ") + ", updated_at = now() WHERE id = $" +
+		strconv.Itoa(len(args)-1) + " AND org_id = $" + strconv.Itoa(len(args)) + " RETURNING " + examColumns
+
+	var exam Exam
+	err = scanExam(dbPool.QueryRow(ctx, query, args...), &exam)
+	if errors.Is(err, pgx.ErrNoRows) {
+		RespondError(c, http.StatusNotFound, ErrCodeNotFound, "Exam not found")
+		return
+	}
+	if err != nil {
+		requestLogger(c).Error("Failed to update exam", "exam_id", id, "error", err)
+		respondQueryError(c, err, "Failed to update exam")
+		return
+	}
+
+	c.JSON(http.StatusOK, exam)
+}
+
+// DeleteExam godoc
+// @Summary      Delete an exam
+// @Description  Teacher or admin only.
+// @Tags         exams
+// @Param        id   path  int  true  "Exam ID"
+// @Success      204
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/exams/{id} [delete]
+func DeleteExam(c *gin.Context) {
+	if dbPool == nil {
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+		return
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid exam id")
+		return
+	}
+
+	tag, err := dbPool.Exec(ctx, "DELETE FROM exams WHERE id = $1 AND org_id = $2", id, orgID(c))
+	if err != nil {
+		requestLogger(c).Error("Failed to delete exam", "exam_id", id, "error", err)
+		respondQueryError(c, err, "Failed to delete exam")
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		RespondError(c, http.StatusNotFound, ErrCodeNotFound, "Exam not found")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
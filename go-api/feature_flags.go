@@ -0,0 +1,399 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Feature flags handlers consult. A flag with no row takes its default here.
+const (
+	flagGraphQL = "graphql"
+)
+
+var featureDefaults = map[string]bool{
+	flagGraphQL: true,
+}
+
+const (
+	defaultFeatureFlagRefresh = 10 * time.Second
+	featureFlagColumns        = "key, description, enabled, user_ids, org_ids, updated_by, created_at, updated_at"
+)
+
+var featureFlagKey = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]*$`)
+
+// featureFlags is the last snapshot of the feature_flags table, reloaded by
+// StartFeatureFlagRefresher and after every change made through this
+// instance. Until the first load succeeds every flag takes its default.
+var featureFlags atomic.Pointer[map[string]FeatureFlag]
+
+// IsEnabled reports whether flag is on for the user userID in organization
+// org: on when the flag is enabled for everyone or either is targeted.
+func IsEnabled(flag string, userID, org int) bool {
+	if flags := featureFlags.Load(); flags != nil {
+		if f, ok := (*flags)[flag]; ok {
+			return f.Enabled || slices.Contains(f.UserIDs, userID) || slices.Contains(f.OrgIDs, org)
+		}
+	}
+	return featureDefaults[flag]
+}
+
+// featureEnabled is IsEnabled for the signed-in user of c.
+func featureEnabled(c *gin.Context, flag string) bool {
+	if claims, ok := currentClaims(c); ok {
+		return IsEnabled(flag, claims.UserID, claims.OrgID)
+	}
+	return IsEnabled(flag, 0, 0)
+}
+
+// RequireFeature answers 404 when flag is off for the caller, as if the route
+// didn't exist. It goes after AuthRequired so targeting can see who's asking.
+func RequireFeature(flag string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !featureEnabled(c, flag) {
+			RespondError(c, http.StatusNotFound, ErrCodeFeatureDisabled, "This feature is not available")
+			return
+		}
+		c.Next()
+	}
+}
+
+func scanFeatureFlag(row pgx.Row, f *FeatureFlag) error {
+	return row.Scan(&f.Key, &f.Description, &f.Enabled, &f.UserIDs, &f.OrgIDs, &f.UpdatedBy, &f.CreatedAt, &f.UpdatedAt)
+}
+
+func queryFeatureFlags(ctx context.Context) ([]FeatureFlag, error) {
+	rows, err := dbPool.Query(ctx, "SELECT "+featureFlagColumns+" FROM feature_flags ORDER BY key")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	flags := []FeatureFlag{}
+	for rows.Next() {
+		var f FeatureFlag
+		if err := scanFeatureFlag(rows, &f); err != nil {
+			return nil, err
+		}
+		flags = append(flags, f)
+	}
+	return flags, rows.Err()
+}
+
+// loadFeatureFlags replaces the snapshot with the table's current contents.
+func loadFeatureFlags(ctx context.Context) error {
+	if dbPool == nil {
+		return errors.New("database connection not established")
+	}
+	flags, err := queryFeatureFlags(ctx)
+	if err != nil {
+		return err
+	}
+	snapshot := make(map[string]FeatureFlag, len(flags))
+	for _, f := range flags {
+		snapshot[f.Key] = f
+	}
+	featureFlags.Store(&snapshot)
+	return nil
+}
+
+// StartFeatureFlagRefresher loads the flags, then reloads them every interval
+// until ctx is done, so a change made on another instance shows up here
+// within interval. A failed reload keeps the previous snapshot.
+func StartFeatureFlagRefresher(ctx context.Context, interval time.Duration) {
+	refresh := func() {
+		loadCtx, cancel := context.WithTimeout(ctx, queryTimeout)
+		defer cancel()
+		if err := loadFeatureFlags(loadCtx); err != nil && ctx.Err() == nil {
+			logger.Warn("Failed to load feature flags", "error", err)
+		}
+	}
+	refresh()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				refresh()
+			}
+		}
+	}()
+}
+
+// reloadFeatureFlags picks up a change made through this instance straight
+// away instead of at the next refresh.
+func reloadFeatureFlags(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(c.Request.Context()), queryTimeout)
+	defer cancel()
+	if err := loadFeatureFlags(ctx); err != nil {
+		requestLogger(c).Warn("Failed to reload feature flags", "error", err)
+	}
+}
+
+// featureFlagChanges diffs the audited fields of a flag.
+func featureFlagChanges(before, after FeatureFlag) map[string]fieldChange {
+	changes := map[string]fieldChange{}
+	if before.Description != after.Description {
+		changes["description"] = fieldChange{before.Description, after.Description}
+	}
+	if before.Enabled != after.Enabled {
+		changes["enabled"] = fieldChange{before.Enabled, after.Enabled}
+	}
+	if !slices.Equal(before.UserIDs, after.UserIDs) {
+		changes["user_ids"] = fieldChange{before.UserIDs, after.UserIDs}
+	}
+	if !slices.Equal(before.OrgIDs, after.OrgIDs) {
+		changes["org_ids"] = fieldChange{before.OrgIDs, after.OrgIDs}
+	}
+	return changes
+}
+
+// targets normalizes a target list for storage: sorted, without duplicates
+// and never NULL.
+func targets(ids []int) []int {
+	if ids == nil {
+		return []int{}
+	}
+	return slices.Compact(slices.Sorted(slices.Values(ids)))
+}
+
+// GetFeatureFlags godoc
+// @Summary      List feature flags
+// @Description  Flags with no row here take their built-in default. Admin only.
+// @Tags         admin
+// @Produce      json
+// @Success      200  {array}   FeatureFlag
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/admin/feature-flags [get]
+func GetFeatureFlags(c *gin.Context) {
+	if dbPool == nil {
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+		return
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	flags, err := queryFeatureFlags(ctx)
+	if err != nil {
+		requestLogger(c).Error("Failed to query feature flags", "error", err)
+		respondQueryError(c, err, "Failed to fetch feature flags")
+		return
+	}
+	c.JSON(http.StatusOK, flags)
+}
+
+// GetFeatureFlag godoc
+// @Summary      Get a feature flag
+// @Description  Admin only.
+// @Tags         admin
+// @Produce      json
+// @Param        key  path      string  true  "Flag key"
+// @Success      200  {object}  FeatureFlag
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/admin/feature-flags/{key} [get]
+func GetFeatureFlag(c *gin.Context) {
+	if dbPool == nil {
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+		return
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	var f FeatureFlag
+	err := scanFeatureFlag(dbPool.QueryRow(ctx,
+		"SELECT "+featureFlagColumns+" FROM feature_flags WHERE key = $1", c.Param("key")), &f)
+	if errors.Is(err, pgx.ErrNoRows) {
+		RespondError(c, http.StatusNotFound, ErrCodeNotFound, "Feature flag not found")
+		return
+	}
+	if err != nil {
+		requestLogger(c).Error("Failed to query feature flag", "key", c.Param("key"), "error", err)
+		respondQueryError(c, err, "Failed to fetch feature flag")
+		return
+	}
+	c.JSON(http.StatusOK, f)
+}
+
+// CreateFeatureFlag godoc
+// @Summary      Create a feature flag
+// @Description  The flag is on for everyone when enabled, otherwise only for the users in user_ids and the organizations in org_ids. Other instances pick it up within FEATURE_FLAG_REFRESH_INTERVAL. Admin only.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        flag  body      CreateFeatureFlagRequest  true  "Flag to create"
+// @Success      201   {object}  FeatureFlag
+// @Failure      400   {object}  ErrorResponse
+// @Failure      401   {object}  ErrorResponse
+// @Failure      403   {object}  ErrorResponse
+// @Failure      409   {object}  ErrorResponse
+// @Failure      500   {object}  ErrorResponse
+// @Failure      503   {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/admin/feature-flags [post]
+func CreateFeatureFlag(c *gin.Context) {
+	if dbPool == nil {
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+		return
+	}
+
+	var req CreateFeatureFlagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindingError(c, err)
+		return
+	}
+	if !featureFlagKey.MatchString(req.Key) {
+		RespondErrorDetails(c, http.StatusBadRequest, ErrCodeValidationFailed, "Validation failed",
+			[]FieldError{{Field: "key", Message: "must be lowercase letters, digits, '.', '_' or '-'"}})
+		return
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	var f FeatureFlag
+	err := scanFeatureFlag(dbPool.QueryRow(ctx, `
+		INSERT INTO feature_flags (key, description, enabled, user_ids, org_ids, updated_by)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING `+featureFlagColumns,
+		req.Key, req.Description, *req.Enabled, targets(req.UserIDs), targets(req.OrgIDs), actorID(c)), &f)
+	if _, ok := uniqueViolation(err); ok {
+		RespondError(c, http.StatusConflict, ErrCodeConflict, "A feature flag with this key already exists")
+		return
+	}
+	if err != nil {
+		requestLogger(c).Error("Failed to insert feature flag", "key", req.Key, "error", err)
+		respondQueryError(c, err, "Failed to create feature flag")
+		return
+	}
+
+	recordAudit(c, actorID(c), auditFeatureFlagCreate, auditTarget{Type: "feature_flag"},
+		gin.H{"key": f.Key, "enabled": f.Enabled, "user_ids": f.UserIDs, "org_ids": f.OrgIDs})
+	reloadFeatureFlags(c)
+	c.JSON(http.StatusCreated, f)
+}
+
+// UpdateFeatureFlag godoc
+// @Summary      Update a feature flag
+// @Description  Replaces the flag's description, switch and targets. Other instances pick the change up within FEATURE_FLAG_REFRESH_INTERVAL. Admin only.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        key   path      string              true  "Flag key"
+// @Param        flag  body      FeatureFlagRequest  true  "New flag settings"
+// @Success      200   {object}  FeatureFlag
+// @Failure      400   {object}  ErrorResponse
+// @Failure      401   {object}  ErrorResponse
+// @Failure      403   {object}  ErrorResponse
+// @Failure      404   {object}  ErrorResponse
+// @Failure      500   {object}  ErrorResponse
+// @Failure      503   {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/admin/feature-flags/{key} [put]
+func UpdateFeatureFlag(c *gin.Context) {
+	if dbPool == nil {
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+		return
+	}
+
+	var req FeatureFlagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindingError(c, err)
+		return
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	key := c.Param("key")
+	var before, after FeatureFlag
+	err := WithTx(ctx, func(tx pgx.Tx) error {
+		err := scanFeatureFlag(tx.QueryRow(ctx,
+			"SELECT "+featureFlagColumns+" FROM feature_flags WHERE key = $1 FOR UPDATE", key), &before)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return newClientError(http.StatusNotFound, ErrCodeNotFound, "Feature flag not found")
+		}
+		if err != nil {
+			return err
+		}
+		return scanFeatureFlag(tx.QueryRow(ctx, `
+			UPDATE feature_flags
+			SET description = $2, enabled = $3, user_ids = $4, org_ids = $5, updated_by = $6, updated_at = now()
+			WHERE key = $1 RETURNING `+featureFlagColumns,
+			key, req.Description, *req.Enabled, targets(req.UserIDs), targets(req.OrgIDs), actorID(c)), &after)
+	})
+	if respondClientError(c, err) {
+		return
+	}
+	if err != nil {
+		requestLogger(c).Error("Failed to update feature flag", "key", key, "error", err)
+		respondQueryError(c, err, "Failed to update feature flag")
+		return
+	}
+
+	if changes := featureFlagChanges(before, after); len(changes) > 0 {
+		recordAudit(c, actorID(c), auditFeatureFlagUpdate, auditTarget{Type: "feature_flag"},
+			gin.H{"key": key, "changes": changes})
+	}
+	reloadFeatureFlags(c)
+	c.JSON(http.StatusOK, after)
+}
+
+// DeleteFeatureFlag godoc
+// @Summary      Delete a feature flag
+// @Description  The flag goes back to its built-in default. Admin only.
+// @Tags         admin
+// @Param        key  path  string  true  "Flag key"
+// @Success      204
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/admin/feature-flags/{key} [delete]
+func DeleteFeatureFlag(c *gin.Context) {
+	if dbPool == nil {
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+		return
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	key := c.Param("key")
+	tag, err := dbPool.Exec(ctx, "DELETE FROM feature_flags WHERE key = $1", key)
+	if err != nil {
+		requestLogger(c).Error("Failed to delete feature flag", "key", key, "error", err)
+		respondQueryError(c, err, "Failed to delete feature flag")
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		RespondError(c, http.StatusNotFound, ErrCodeNotFound, "Feature flag not found")
+		return
+	}
+
+	recordAudit(c, actorID(c), auditFeatureFlagDelete, auditTarget{Type: "feature_flag"}, gin.H{"key": key})
+	reloadFeatureFlags(c)
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// BatchGrade godoc
+// @Summary      Grade short answers in bulk
+// @Description  Stores points and optional feedback for short-answer responses in one transaction, then rescores every submission touched and returns the new totals. Points are rounded to two decimals and may not exceed the question's points. Any invalid entry fails the whole batch. Teacher or admin only.
+// @Tags         submissions
+// @Accept       json
+// @Produce      json
+// @Param        grades  body      BatchGradeRequest  true  "Grades to record"
+// @Success      200     {object}  BatchGradeResponse
+// @Failure      400     {object}  ErrorResponse
+// @Failure      401     {object}  ErrorResponse
+// @Failure      403     {object}  ErrorResponse
+// @Failure      500     {object}  ErrorResponse
+// @Failure      503     {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/submissions/batch-grade [patch]
+func BatchGrade(c *gin.Context) {
+	if dbPool == nil {
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+		return
+	}
+
+	claims, ok := currentClaims(c)
+	if !ok {
+		RespondError(c, http.StatusUnauthorized, ErrCodeUnauthorized, "Authentication required")
+		return
+	}
+
+	var req BatchGradeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindingError(c, err)
+		return
+	}
+
+	ids := make([]int, len(req.Grades))
+	for i := range req.Grades {
+		g := &req.Grades[i]
+		*g.Points = math.Round(*g.Points*100) / 100
+		ids[i] = g.SubmissionAnswerID
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	var scores []SubmissionScore
+	org := orgID(c)
+	err := WithTx(ctx, func(tx pgx.Tx) error {
+		type gradable struct {
+			submissionID int
+			questionType string
+			maxPoints    int
+		}
+		// Locking the answers keeps two graders from interleaving on one.
+		rows, err := tx.Query(ctx, `
+			SELECT a.id, a.submission_id, q.type, q.points
+			FROM submission_answers a JOIN exam_questions q ON q.id = a.question_id
+			WHERE a.id = ANY($1) AND q.org_id = $2
+			ORDER BY a.id
+			FOR UPDATE OF a`, ids, org)
+		if err != nil {
+			return err
+		}
+		answers := map[int]gradable{}
+		for rows.Next() {
+			var id int
+			var a gradable
+			if err := rows.Scan(&id, &a.submissionID, &a.questionType, &a.maxPoints); err != nil {
+				rows.Close()
+				return err
+			}
+			answers[id] = a
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		var problems []FieldError
+		seen := map[int]bool{}
+		for i, g := range req.Grades {
+			field := fmt.Sprintf("grades[%d]", i)
+			a, ok := answers[g.SubmissionAnswerID]
+			switch {
+			case !ok:
+				problems = append(problems, FieldError{Field: field + ".submission_answer_id", Message: "does not exist"})
+			case seen[g.SubmissionAnswerID]:
+				problems = append(problems, FieldError{Field: field + ".submission_answer_id", Message: "is graded more than once"})
+			case a.questionType != QuestionTypeShortAnswer:
+				problems = append(problems, FieldError{Field: field + ".submission_answer_id", Message: "is not a short answer; those are scored automatically"})
+			case *g.Points > float64(a.maxPoints):
+				problems = append(problems, FieldError{Field: field + ".points", Message: fmt.Sprintf("must be at most %d", a.maxPoints)})
+			}
+			seen[g.SubmissionAnswerID] = true
+		}
+		if len(problems) > 0 {
+			return &clientError{
+				status:  http.StatusBadRequest,
+				code:    ErrCodeValidationFailed,
+				msg:     "Invalid grades",
+				details: problems,
+			}
+		}
+
+		graded := map[int][]int{}
+		for _, g := range req.Grades {
+			_, err := tx.Exec(ctx, `
+				UPDATE submission_answers
+				SET points_awarded = $1, feedback = $2, graded_by = $3, graded_at = now()
+				WHERE id = $4`, *g.Points, g.Feedback, claims.UserID, g.SubmissionAnswerID)
+			if err != nil {
+				return err
+			}
+			sid := answers[g.SubmissionAnswerID].submissionID
+			graded[sid] = append(graded[sid], g.SubmissionAnswerID)
+		}
+
+		// Rescored in id order so concurrent batches lock submissions in
+		// the same order.
+		submissionIDs := make([]int, 0, len(graded))
+		for sid := range graded {
+			submissionIDs = append(submissionIDs, sid)
+		}
+		slices.Sort(submissionIDs)
+		for _, sid := range submissionIDs {
+			score, err := scoreSubmission(ctx, tx, sid)
+			if err != nil {
+				return err
+			}
+			err = writeAudit(ctx, tx, &claims.UserID, auditSubmissionGrade, auditTarget{"submission", sid},
+				map[string]any{"answer_ids": graded[sid], "score": score.Score, "pending": score.Pending})
+			if err != nil {
+				return err
+			}
+			scores = append(scores, *score)
+		}
+		return nil
+	})
+	if respondClientError(c, err) {
+		return
+	}
+	if err != nil {
+		requestLogger(c).Error("Failed to record grades", "error", err)
+		respondQueryError(c, err, "Failed to record grades")
+		return
+	}
+
+	c.JSON(http.StatusOK, BatchGradeResponse{Submissions: scores})
+}
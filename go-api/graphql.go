@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graph-gophers/graphql-go"
+)
+
+//go:embed schema.graphql
+var graphQLSchema string
+
+// graphQLMaxDepth caps how deeply a query may nest. Every level can cost a
+// REST call per parent, so this is what keeps one query bounded.
+const graphQLMaxDepth = 6
+
+// newGraphQLSchema parses schema.graphql against its resolvers, failing if
+// the two disagree.
+func newGraphQLSchema() (*graphql.Schema, error) {
+	schema, err := graphql.ParseSchema(graphQLSchema, &graphQLResolver{}, graphql.MaxDepth(graphQLMaxDepth))
+	if err != nil {
+		return nil, fmt.Errorf("parse GraphQL schema: %w", err)
+	}
+	return schema, nil
+}
+
+// GraphQL godoc
+// @Summary      Run a GraphQL query
+// @Description  Queries and mutations over users, exams, questions and submissions; the schema is schema.graphql. Each field is answered by the matching v1 route with the caller's token, so roles, organization scoping and validation are the same as over REST. The status is 200 even when fields fail; each error's extensions carry the REST code and status.
+// @Tags         graphql
+// @Accept       json
+// @Produce      json
+// @Param        request  body      GraphQLRequest  true  "Query, operation name and variables"
+// @Success      200      {object}  GraphQLResponse
+// @Failure      400      {object}  ErrorResponse
+// @Failure      401      {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/graphql [post]
+func GraphQL(schema *graphql.Schema, routes http.Handler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req GraphQLRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondBindingError(c, err)
+			return
+		}
+
+		ctx := context.WithValue(c.Request.Context(), restCallerKey{}, &restCaller{
+			routes:        routes,
+			authorization: c.GetHeader("Authorization"),
+			apiKey:        c.GetHeader(apiKeyHeader),
+			language:      c.GetHeader("Accept-Language"),
+			requestID:     c.GetString(requestIDKey),
+			remoteAddr:    net.JoinHostPort(c.ClientIP(), "0"),
+		})
+		c.JSON(http.StatusOK, schema.Exec(ctx, req.Query, req.OperationName, req.Variables))
+	}
+}
+
+type restCallerKey struct{}
+
+// restCaller replays GraphQL fields as in-process requests to the v1 routes,
+// on behalf of the client that sent the GraphQL request.
+type restCaller struct {
+	routes        http.Handler
+	authorization string
+	apiKey        string
+	language      string
+	requestID     string
+	remoteAddr    string
+}
+
+// restCall serves method path through the caller's routes and decodes a 2xx
+// body into out, when out isn't nil. Error responses come back as a
+// *graphQLError.
+func restCall(ctx context.Context, method, path string, body, out any) error {
+	rc, ok := ctx.Value(restCallerKey{}).(*restCaller)
+	if !ok {
+		return fmt.Errorf("no REST caller in context")
+	}
+
+	var r io.Reader = http.NoBody
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, path, r)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", rc.authorization)
+	req.Header.Set(apiKeyHeader, rc.apiKey)
+	req.Header.Set("Accept-Language", rc.language)
+	req.Header.Set(requestIDHeader, rc.requestID)
+	req.RemoteAddr = rc.remoteAddr
+
+	w := httptest.NewRecorder()
+	rc.routes.ServeHTTP(w, req)
+	if w.Code < 200 || w.Code > 299 {
+		var resp ErrorResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Error.Code == "" {
+			return fmt.Errorf("%s %s: status %d", method, path, w.Code)
+		}
+		return &graphQLError{status: w.Code, APIError: resp.Error}
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(w.Body.Bytes(), out)
+}
+
+// graphQLError is a REST error response surfaced on a GraphQL field.
+type graphQLError struct {
+	status int
+	APIError
+}
+
+func (e *graphQLError) Error() string {
+	return e.Message
+}
+
+// Extensions puts the REST code, status and details on the GraphQL error.
+func (e *graphQLError) Extensions() map[string]any {
+	ext := map[string]any{"code": e.Code, "status": e.status}
+	if e.Details != nil {
+		ext["details"] = e.Details
+	}
+	return ext
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/graph-gophers/graphql-go"
+)
+
+// graphQLResolver is the root of schema.graphql. Fields fetch through
+// restCall; the types below only adapt the REST models to GraphQL, whose Int
+// is 32-bit.
+type graphQLResolver struct{}
+
+type pageArgs struct {
+	Limit  *int32
+	Offset *int32
+}
+
+func (a pageArgs) values() url.Values {
+	v := url.Values{}
+	if a.Limit != nil {
+		v.Set("limit", strconv.Itoa(int(*a.Limit)))
+	}
+	if a.Offset != nil {
+		v.Set("offset", strconv.Itoa(int(*a.Offset)))
+	}
+	return v
+}
+
+type idArgs struct {
+	ID int32
+}
+
+func (r *graphQLResolver) Me(ctx context.Context) (*userResolver, error) {
+	var u User
+	if err := restCall(ctx, http.MethodGet, "/v1/me", nil, &u); err != nil {
+		return nil, err
+	}
+	return &userResolver{u}, nil
+}
+
+func (r *graphQLResolver) Users(ctx context.Context, args struct {
+	Limit  *int32
+	Offset *int32
+	Search *string
+}) ([]*userResolver, error) {
+	q := pageArgs{args.Limit, args.Offset}.values()
+	if args.Search != nil {
+		q.Set("search", *args.Search)
+	}
+	var users []User
+	if err := restCall(ctx, http.MethodGet, "/v1/users?"+q.Encode(), nil, &users); err != nil {
+		return nil, err
+	}
+	out := make([]*userResolver, len(users))
+	for i, u := range users {
+		out[i] = &userResolver{u}
+	}
+	return out, nil
+}
+
+func (r *graphQLResolver) User(ctx context.Context, args idArgs) (*userResolver, error) {
+	var u User
+	if err := restCall(ctx, http.MethodGet, "/v1/users/"+strconv.Itoa(int(args.ID)), nil, &u); err != nil {
+		return nil, err
+	}
+	return &userResolver{u}, nil
+}
+
+func (r *graphQLResolver) Exams(ctx context.Context, args pageArgs) ([]*examResolver, error) {
+	var exams []Exam
+	if err := restCall(ctx, http.MethodGet, "/v1/exams?"+args.values().Encode(), nil, &exams); err != nil {
+		return nil, err
+	}
+	out := make([]*examResolver, len(exams))
+	for i, e := range exams {
+		out[i] = &examResolver{e}
+	}
+	return out, nil
+}
+
+func (r *graphQLResolver) Exam(ctx context.Context, args idArgs) (*examResolver, error) {
+	var e Exam
+	if err := restCall(ctx, http.MethodGet, "/v1/exams/"+strconv.Itoa(int(args.ID)), nil, &e); err != nil {
+		return nil, err
+	}
+	return &examResolver{e}, nil
+}
+
+func (r *graphQLResolver) Submission(ctx context.Context, args idArgs) (*submissionScoreResolver, error) {
+	return fetchSubmissionScore(ctx, int(args.ID))
+}
+
+func fetchSubmissionScore(ctx context.Context, id int) (*submissionScoreResolver, error) {
+	var s SubmissionScore
+	if err := restCall(ctx, http.MethodGet, "/v1/submissions/"+strconv.Itoa(id)+"/score", nil, &s); err != nil {
+		return nil, err
+	}
+	return &submissionScoreResolver{s}, nil
+}
+
+type createUserInput struct {
+	Username string  `json:"username"`
+	Email    string  `json:"email"`
+	Password *string `json:"password,omitempty"`
+}
+
+type updateUserInput struct {
+	Username *string `json:"username,omitempty"`
+	Email    *string `json:"email,omitempty"`
+}
+
+type examInput struct {
+	Title            *string `json:"title,omitempty"`
+	Description      *string `json:"description,omitempty"`
+	DurationMinutes  *int32  `json:"duration_minutes,omitempty"`
+	AllowRetakes     *bool   `json:"allow_retakes,omitempty"`
+	MaxAttempts      *int32  `json:"max_attempts,omitempty"`
+	ShuffleQuestions *bool   `json:"shuffle_questions,omitempty"`
+	ShuffleOptions   *bool   `json:"shuffle_options,omitempty"`
+}
+
+type questionInput struct {
+	Prompt          *string   `json:"prompt,omitempty"`
+	Type            *string   `json:"type,omitempty"`
+	ScoringStrategy *string   `json:"scoring_strategy,omitempty"`
+	Points          *int32    `json:"points,omitempty"`
+	Position        *int32    `json:"position,omitempty"`
+	Tags            *[]string `json:"tags,omitempty"`
+}
+
+func (r *graphQLResolver) CreateUser(ctx context.Context, args struct{ Input createUserInput }) (*userResolver, error) {
+	var u User
+	if err := restCall(ctx, http.MethodPost, "/v1/users", args.Input, &u); err != nil {
+		return nil, err
+	}
+	return &userResolver{u}, nil
+}
+
+func (r *graphQLResolver) UpdateUser(ctx context.Context, args struct {
+	ID    int32
+	Input updateUserInput
+}) (*userResolver, error) {
+	var u User
+	if err := restCall(ctx, http.MethodPatch, "/v1/users/"+strconv.Itoa(int(args.ID)), args.Input, &u); err != nil {
+		return nil, err
+	}
+	return &userResolver{u}, nil
+}
+
+func (r *graphQLResolver) DeleteUser(ctx context.Context, args idArgs) (bool, error) {
+	err := restCall(ctx, http.MethodDelete, "/v1/users/"+strconv.Itoa(int(args.ID)), nil, nil)
+	return err == nil, err
+}
+
+func (r *graphQLResolver) CreateExam(ctx context.Context, args struct{ Input examInput }) (*examResolver, error) {
+	var e Exam
+	if err := restCall(ctx, http.MethodPost, "/v1/exams", args.Input, &e); err != nil {
+		return nil, err
+	}
+	return &examResolver{e}, nil
+}
+
+func (r *graphQLResolver) UpdateExam(ctx context.Context, args struct {
+	ID    int32
+	Input examInput
+}) (*examResolver, error) {
+	var e Exam
+	if err := restCall(ctx, http.MethodPatch, "/v1/exams/"+strconv.Itoa(int(args.ID)), args.Input, &e); err != nil {
+		return nil, err
+	}
+	return &examResolver{e}, nil
+}
+
+func (r *graphQLResolver) DeleteExam(ctx context.Context, args idArgs) (bool, error) {
+	err := restCall(ctx, http.MethodDelete, "/v1/exams/"+strconv.Itoa(int(args.ID)), nil, nil)
+	return err == nil, err
+}
+
+func questionPath(examID int32, id ...int32) string {
+	path := "/v1/exams/" + strconv.Itoa(int(examID)) + "/questions"
+	for _, id := range id {
+		path += "/" + strconv.Itoa(int(id))
+	}
+	return path
+}
+
+func (r *graphQLResolver) CreateQuestion(ctx context.Context, args struct {
+	ExamID int32
+	Input  questionInput
+}) (*questionResolver, error) {
+	var q Question
+	if err := restCall(ctx, http.MethodPost, questionPath(args.ExamID), args.Input, &q); err != nil {
+		return nil, err
+	}
+	return &questionResolver{q}, nil
+}
+
+func (r *graphQLResolver) UpdateQuestion(ctx context.Context, args struct {
+	ExamID int32
+	ID     int32
+	Input  questionInput
+}) (*questionResolver, error) {
+	var q Question
+	if err := restCall(ctx, http.MethodPatch, questionPath(args.ExamID, args.ID), args.Input, &q); err != nil {
+		return nil, err
+	}
+	return &questionResolver{q}, nil
+}
+
+func (r *graphQLResolver) DeleteQuestion(ctx context.Context, args struct {
+	ExamID int32
+	ID     int32
+}) (bool, error) {
+	err := restCall(ctx, http.MethodDelete, questionPath(args.ExamID, args.ID), nil, nil)
+	return err == nil, err
+}
+
+// int32Ptr narrows an optional REST int to a GraphQL Int.
+func int32Ptr(p *int) *int32 {
+	if p == nil {
+		return nil
+	}
+	v := int32(*p)
+	return &v
+}
+
+type userResolver struct{ u User }
+
+func (r *userResolver) ID() int32           { return int32(r.u.ID) }
+func (r *userResolver) Username() string    { return r.u.Username }
+func (r *userResolver) Email() string       { return r.u.Email }
+func (r *userResolver) Role() string        { return r.u.Role }
+func (r *userResolver) EmailVerified() bool { return r.u.EmailVerified }
+
+type examResolver struct{ e Exam }
+
+func (r *examResolver) ID() int32                 { return int32(r.e.ID) }
+func (r *examResolver) Title() string             { return r.e.Title }
+func (r *examResolver) Description() string       { return r.e.Description }
+func (r *examResolver) DurationMinutes() int32    { return int32(r.e.DurationMinutes) }
+func (r *examResolver) AllowRetakes() bool        { return r.e.AllowRetakes }
+func (r *examResolver) MaxAttempts() *int32       { return int32Ptr(r.e.MaxAttempts) }
+func (r *examResolver) ShuffleQuestions() bool    { return r.e.ShuffleQuestions }
+func (r *examResolver) ShuffleOptions() bool      { return r.e.ShuffleOptions }
+func (r *examResolver) CreatedBy() *int32         { return int32Ptr(r.e.CreatedBy) }
+func (r *examResolver) CreatedAt() graphql.Time   { return graphql.Time{Time: r.e.CreatedAt} }
+func (r *examResolver) RemainingAttempts() *int32 { return int32Ptr(r.e.RemainingAttempts) }
+
+func (r *examResolver) Questions(ctx context.Context, args struct {
+	View *string
+	Tag  *[]string
+}) ([]*questionResolver, error) {
+	q := url.Values{}
+	if args.View != nil {
+		q.Set("view", *args.View)
+	}
+	if args.Tag != nil {
+		q["tag"] = *args.Tag
+	}
+	var questions []Question
+	if err := restCall(ctx, http.MethodGet, questionPath(int32(r.e.ID))+"?"+q.Encode(), nil, &questions); err != nil {
+		return nil, err
+	}
+	out := make([]*questionResolver, len(questions))
+	for i, q := range questions {
+		out[i] = &questionResolver{q}
+	}
+	return out, nil
+}
+
+func (r *examResolver) Results(ctx context.Context, args pageArgs) ([]*examResultResolver, error) {
+	var results ExamResults
+	path := "/v1/exams/" + strconv.Itoa(r.e.ID) + "/results?" + args.values().Encode()
+	if err := restCall(ctx, http.MethodGet, path, nil, &results); err != nil {
+		return nil, err
+	}
+	out := make([]*examResultResolver, len(results.Data))
+	for i, res := range results.Data {
+		out[i] = &examResultResolver{res}
+	}
+	return out, nil
+}
+
+type questionResolver struct{ q Question }
+
+func (r *questionResolver) ID() int32               { return int32(r.q.ID) }
+func (r *questionResolver) ExamID() int32           { return int32(r.q.ExamID) }
+func (r *questionResolver) Prompt() string          { return r.q.Prompt }
+func (r *questionResolver) Type() string            { return r.q.Type }
+func (r *questionResolver) ScoringStrategy() string { return r.q.ScoringStrategy }
+func (r *questionResolver) Points() int32           { return int32(r.q.Points) }
+func (r *questionResolver) Position() int32         { return int32(r.q.Position) }
+
+func (r *questionResolver) Tags() []string {
+	if r.q.Tags == nil {
+		return []string{}
+	}
+	return r.q.Tags
+}
+
+func (r *questionResolver) Options() []*optionResolver {
+	out := make([]*optionResolver, len(r.q.Options))
+	for i, o := range r.q.Options {
+		out[i] = &optionResolver{o}
+	}
+	return out
+}
+
+type optionResolver struct{ o QuestionOption }
+
+func (r *optionResolver) ID() int32        { return int32(r.o.ID) }
+func (r *optionResolver) Text() string     { return r.o.Text }
+func (r *optionResolver) IsCorrect() *bool { return r.o.IsCorrect }
+
+type examResultResolver struct{ r ExamResult }
+
+func (r *examResultResolver) SubmissionID() int32       { return int32(r.r.SubmissionID) }
+func (r *examResultResolver) UserID() int32             { return int32(r.r.UserID) }
+func (r *examResultResolver) Username() string          { return r.r.Username }
+func (r *examResultResolver) Score() *float64           { return r.r.Score }
+func (r *examResultResolver) MaxScore() *int32          { return int32Ptr(r.r.MaxScore) }
+func (r *examResultResolver) SubmittedAt() graphql.Time { return graphql.Time{Time: r.r.SubmittedAt} }
+
+func (r *examResultResolver) Submission(ctx context.Context) (*submissionScoreResolver, error) {
+	return fetchSubmissionScore(ctx, r.r.SubmissionID)
+}
+
+type submissionScoreResolver struct{ s SubmissionScore }
+
+func (r *submissionScoreResolver) SubmissionID() int32 { return int32(r.s.SubmissionID) }
+func (r *submissionScoreResolver) Score() float64      { return r.s.Score }
+func (r *submissionScoreResolver) MaxScore() int32     { return int32(r.s.MaxScore) }
+func (r *submissionScoreResolver) Pending() int32      { return int32(r.s.Pending) }
+
+func (r *submissionScoreResolver) Questions() []*questionScoreResolver {
+	out := make([]*questionScoreResolver, len(r.s.Questions))
+	for i, q := range r.s.Questions {
+		out[i] = &questionScoreResolver{q}
+	}
+	return out
+}
+
+type questionScoreResolver struct{ q QuestionScore }
+
+func (r *questionScoreResolver) QuestionID() int32       { return int32(r.q.QuestionID) }
+func (r *questionScoreResolver) Type() string            { return r.q.Type }
+func (r *questionScoreResolver) PointsAwarded() *float64 { return r.q.PointsAwarded }
+func (r *questionScoreResolver) AnswerID() *int32        { return int32Ptr(r.q.AnswerID) }
+func (r *questionScoreResolver) Feedback() *string       { return r.q.Feedback }
+func (r *questionScoreResolver) MaxPoints() int32        { return int32(r.q.MaxPoints) }
+func (r *questionScoreResolver) Status() string          { return r.q.Status }
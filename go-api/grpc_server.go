@@ -0,0 +1,386 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jackc/pgx/v5"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"go-api/userpb"
+)
+
+type grpcClaimsKey struct{}
+
+// NewGRPCServer builds the gRPC server for internal services, serving
+// UserService from proto/user.proto. Every call is authenticated with the
+// same access tokens as the REST API, sent as "authorization" metadata.
+func NewGRPCServer(secret []byte) *grpc.Server {
+	srv := grpc.NewServer(grpc.ChainUnaryInterceptor(grpcAccessLog, grpcRecovery, grpcAuth(secret)))
+	userpb.RegisterUserServiceServer(srv, &userServer{})
+	return srv
+}
+
+// ServeGRPC listens on port and serves srv until it is stopped.
+func ServeGRPC(srv *grpc.Server, port string) {
+	lis, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		fatal("Failed to listen for gRPC", "port", port, "error", err)
+	}
+	logger.Info("Starting gRPC server", "port", port)
+	if err := srv.Serve(lis); err != nil {
+		fatal("Failed to start gRPC server", "error", err)
+	}
+}
+
+// StopGRPC lets in-flight calls finish, then stops srv. Calls still running
+// when ctx expires are cut off.
+func StopGRPC(ctx context.Context, srv *grpc.Server) {
+	done := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		logger.Error("gRPC server forced to shut down")
+		srv.Stop()
+	}
+}
+
+// grpcAccessLog logs each call like AccessLog does a request.
+func grpcAccessLog(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	logger.Info("gRPC call",
+		"method", info.FullMethod,
+		"code", status.Code(err).String(),
+		"duration_ms", time.Since(start).Milliseconds())
+	return resp, err
+}
+
+// grpcRecovery turns a panicking call into an INTERNAL error, like Recovery.
+func grpcRecovery(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			logger.Error("Panic recovered", "method", info.FullMethod, "panic", p, "stack", string(debug.Stack()))
+			err = status.Error(codes.Internal, "Internal server error")
+		}
+	}()
+	return handler(ctx, req)
+}
+
+// grpcAuth is AuthRequired and RequireOrg for gRPC: it validates the bearer
+// token in the call's metadata and puts its claims on the context. Maintenance
+// mode turns every call away, as it does REST requests.
+func grpcAuth(secret []byte) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if maintenance.Load() {
+			return nil, status.Error(codes.Unavailable, "Quick Quiz is down for planned maintenance and will be back shortly")
+		}
+
+		md, _ := metadata.FromIncomingContext(ctx)
+		var header string
+		if v := md.Get("authorization"); len(v) > 0 {
+			header = v[0]
+		}
+		tokenString, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok || tokenString == "" {
+			return nil, status.Error(codes.Unauthenticated, "Missing bearer token")
+		}
+
+		claims, err := verifyAccessToken(ctx, logger, tokenString, secret)
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, status.Error(codes.Unauthenticated, "Token expired")
+		}
+		if errors.Is(err, errTokenRevoked) {
+			return nil, status.Error(codes.Unauthenticated, "Token has been revoked")
+		}
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, "Invalid token")
+		}
+		if claims.OrgID <= 0 {
+			return nil, status.Error(codes.Unauthenticated, "Token has no organization; sign in again")
+		}
+		return handler(context.WithValue(ctx, grpcClaimsKey{}, claims), req)
+	}
+}
+
+// grpcClaims returns the claims grpcAuth stored on ctx.
+func grpcClaims(ctx context.Context) *Claims {
+	claims, _ := ctx.Value(grpcClaimsKey{}).(*Claims)
+	return claims
+}
+
+// requireGRPCScope is RequireScope for gRPC.
+func requireGRPCScope(claims *Claims, scope string) error {
+	if !slices.Contains(grantedScopes(claims), scope) {
+		return status.Error(codes.PermissionDenied, "Requires scope "+scope)
+	}
+	return nil
+}
+
+// grpcWriteAllowed is ReadOnlyGuard for the calls that write.
+func grpcWriteAllowed() error {
+	if readOnly.Load() {
+		return status.Error(codes.Unavailable, "The API is in read-only mode for maintenance; try again later")
+	}
+	return nil
+}
+
+// grpcDBError is respondQueryError for gRPC.
+func grpcDBError(err error, msg string) error {
+	if errors.Is(err, context.Canceled) {
+		return status.Error(codes.Canceled, "Call canceled")
+	}
+	if errors.Is(err, errCircuitOpen) {
+		return status.Error(codes.Unavailable, "Database is unavailable; try again shortly")
+	}
+	if isQueryTimeout(err) {
+		return status.Error(codes.DeadlineExceeded, "Database query timed out")
+	}
+	return status.Error(codes.Internal, msg)
+}
+
+// grpcQueryContext is queryContext for gRPC: the call's own deadline, capped
+// at queryTimeout.
+func grpcQueryContext(ctx context.Context) (context.Context, context.CancelFunc, error) {
+	if dbPool == nil {
+		return nil, nil, status.Error(codes.Unavailable, "Database connection not established")
+	}
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	return ctx, cancel, nil
+}
+
+// userServer implements UserService over the same queries as the REST user
+// routes.
+type userServer struct {
+	userpb.UnimplementedUserServiceServer
+}
+
+func userToProto(u User) *userpb.User {
+	pb := &userpb.User{
+		Id:            int64(u.ID),
+		Username:      u.Username,
+		Email:         u.Email,
+		Role:          u.Role,
+		EmailVerified: u.EmailVerified,
+	}
+	if u.DeletedAt != nil {
+		pb.DeletedAt = timestamppb.New(*u.DeletedAt)
+	}
+	return pb
+}
+
+func (s *userServer) GetUser(ctx context.Context, req *userpb.GetUserRequest) (*userpb.User, error) {
+	claims := grpcClaims(ctx)
+	if claims.Role != RoleAdmin && int64(claims.UserID) != req.GetId() {
+		return nil, status.Error(codes.PermissionDenied, "Insufficient permissions")
+	}
+	if err := requireGRPCScope(claims, ScopeUsersRead); err != nil {
+		return nil, err
+	}
+	ctx, cancel, err := grpcQueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+
+	var user User
+	err = scanUser(dbPool.QueryRow(ctx,
+		"SELECT "+userColumns+" FROM up_users WHERE id = $1 AND org_id = $2 AND deleted_at IS NULL",
+		req.GetId(), claims.OrgID), &user)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, status.Error(codes.NotFound, "User not found")
+	}
+	if err != nil {
+		logger.Error("Failed to query user", "user_id", req.GetId(), "error", err)
+		return nil, grpcDBError(err, "Failed to fetch user")
+	}
+	return userToProto(user), nil
+}
+
+// ListUsers pages in id order like the cursor mode of GET /v1/users; the page
+// token is the last id of the previous page.
+func (s *userServer) ListUsers(ctx context.Context, req *userpb.ListUsersRequest) (*userpb.ListUsersResponse, error) {
+	claims := grpcClaims(ctx)
+	if claims.Role != RoleTeacher && claims.Role != RoleAdmin {
+		return nil, status.Error(codes.PermissionDenied, "Insufficient permissions")
+	}
+	if err := requireGRPCScope(claims, ScopeUsersRead); err != nil {
+		return nil, err
+	}
+
+	limit := int(req.GetPageSize())
+	switch {
+	case limit < 0:
+		return nil, status.Error(codes.InvalidArgument, "page_size must not be negative")
+	case limit == 0:
+		limit = defaultPageLimit
+	case limit > maxPageLimit:
+		limit = maxPageLimit
+	}
+	after := 0
+	if token := req.GetPageToken(); token != "" {
+		var err error
+		after, err = strconv.Atoi(token)
+		if err != nil || after < 0 {
+			return nil, status.Error(codes.InvalidArgument, "Invalid page_token")
+		}
+	}
+
+	ctx, cancel, err := grpcQueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+
+	query := "SELECT " + userColumns + " FROM up_users WHERE org_id = $1 AND deleted_at IS NULL AND id > $2"
+	args := []any{claims.OrgID, after}
+	if search := strings.TrimSpace(req.GetSearch()); search != "" {
+		args = append(args, "%"+escapeLike(search)+"%")
+		query += " AND (username ILIKE $3 OR email ILIKE $3)"
+	}
+	// Fetch one extra row to learn whether another page exists.
+	args = append(args, limit+1)
+	query += " ORDER BY id LIMIT $" + strconv.Itoa(len(args))
+
+	rows, err := dbPool.Query(ctx, query, args...)
+	if err != nil {
+		logger.Error("Failed to query users", "error", err)
+		return nil, grpcDBError(err, "Failed to fetch users")
+	}
+	users, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (User, error) {
+		var u User
+		err := scanUser(row, &u)
+		return u, err
+	})
+	if err != nil {
+		logger.Error("Failed to read user rows", "error", err)
+		return nil, grpcDBError(err, "Error reading users")
+	}
+
+	resp := &userpb.ListUsersResponse{}
+	if len(users) > limit {
+		users = users[:limit]
+		resp.NextPageToken = strconv.Itoa(users[limit-1].ID)
+	}
+	for _, u := range users {
+		resp.Users = append(resp.Users, userToProto(u))
+	}
+	return resp, nil
+}
+
+func (s *userServer) CreateUser(ctx context.Context, req *userpb.CreateUserRequest) (*userpb.User, error) {
+	claims := grpcClaims(ctx)
+	if claims.Role != RoleAdmin {
+		return nil, status.Error(codes.PermissionDenied, "Insufficient permissions")
+	}
+	if err := requireGRPCScope(claims, ScopeUsersWrite); err != nil {
+		return nil, err
+	}
+	if err := grpcWriteAllowed(); err != nil {
+		return nil, err
+	}
+
+	in := CreateUserRequest{Username: req.GetUsername(), Email: req.GetEmail(), Password: req.GetPassword()}
+	if err := binding.Validator.ValidateStruct(&in); err != nil {
+		var verrs validator.ValidationErrors
+		if !errors.As(err, &verrs) {
+			return nil, status.Error(codes.InvalidArgument, "Invalid request")
+		}
+		problems := make([]string, 0, len(verrs))
+		for _, fe := range fieldErrors(verrs) {
+			problems = append(problems, fe.Field+" "+fe.Message)
+		}
+		return nil, status.Error(codes.InvalidArgument, "Validation failed: "+strings.Join(problems, "; "))
+	}
+
+	var passwordHash *string
+	if in.Password != "" {
+		hash, err := hashPassword(in.Password)
+		if err != nil {
+			logger.Error("Failed to hash password", "error", err)
+			return nil, status.Error(codes.Internal, "Failed to create user")
+		}
+		passwordHash = &hash
+	}
+
+	ctx, cancel, err := grpcQueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+
+	user, err := insertUser(ctx, claims.OrgID, in.Username, in.Email, passwordHash, true)
+	var ce *clientError
+	if errors.As(err, &ce) {
+		return nil, status.Error(codes.AlreadyExists, ce.msg)
+	}
+	if err != nil {
+		logger.Error("Failed to insert user", "username", in.Username, "error", err)
+		return nil, grpcDBError(err, "Failed to create user")
+	}
+
+	userCache.invalidateCtx(ctx, logger)
+	grpcAudit(ctx, claims, auditUserCreate, auditTarget{"user", user.ID},
+		gin.H{"username": user.Username, "email": user.Email, "role": user.Role})
+	return userToProto(user), nil
+}
+
+func (s *userServer) DeleteUser(ctx context.Context, req *userpb.DeleteUserRequest) (*userpb.DeleteUserResponse, error) {
+	claims := grpcClaims(ctx)
+	if claims.Role != RoleAdmin {
+		return nil, status.Error(codes.PermissionDenied, "Insufficient permissions")
+	}
+	if err := requireGRPCScope(claims, ScopeUsersWrite); err != nil {
+		return nil, err
+	}
+	if err := grpcWriteAllowed(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel, err := grpcQueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+
+	id := int(req.GetId())
+	username, email, err := softDeleteUser(ctx, claims.OrgID, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, status.Error(codes.NotFound, "User not found")
+	}
+	if err != nil {
+		logger.Error("Failed to delete user", "user_id", id, "error", err)
+		return nil, grpcDBError(err, "Failed to delete user")
+	}
+
+	userCache.invalidateCtx(ctx, logger)
+	grpcAudit(ctx, claims, auditUserDelete, auditTarget{"user", id}, gin.H{"username": username, "email": email})
+	return &userpb.DeleteUserResponse{}, nil
+}
+
+// grpcAudit is recordAudit for gRPC calls.
+func grpcAudit(ctx context.Context, claims *Claims, action string, target auditTarget, meta any) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), queryTimeout)
+	defer cancel()
+	if err := Audit(ctx, &claims.UserID, action, target, meta); err != nil {
+		logger.Error("Failed to write audit log", "action", action, "error", err)
+	}
+}
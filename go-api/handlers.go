@@ -1,43 +1,740 @@
 package main
 
 import (
+	"cmp"
 	"context"
-	"log"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
+const (
+	uniqueViolationCode = "23505"
+	userColumns         = "id, username, email, role, email_verified, deleted_at"
+	adminUserColumns    = userColumns + ", last_login_at"
+
+	// Unique index names from migration 0008, used to tell collisions apart.
+	userEmailConstraint    = "up_users_email_key"
+	userUsernameConstraint = "up_users_username_key"
+)
+
+var userSortColumns = map[string]string{
+	"id":       "id",
+	"username": "username",
+	"email":    "email",
+	"role":     "role",
+}
+
+// GetUsers godoc
+// @Summary      List users
+// @Description  Returns a page of users. Teachers and admins only. Pass paginated=true to get a {data, total, limit, offset} envelope instead of a bare array. Offset pages carry a Link header with the neighbouring pages. Passing cursor (empty for the first page) switches to keyset paging in id order with a {data, next_cursor, limit} envelope; it stays stable under concurrent inserts and deletes, unlike offset.
+// @Tags         users
+// @Produce      json
+// @Param        limit      query  int     false  "Page size (default 20, max 100)"
+// @Param        offset     query  int     false  "Rows to skip"
+// @Param        search     query  string  false  "Case-insensitive match on username or email"
+// @Param        sort       query  string  false  "Sort column"  Enums(id, username, email, role)
+// @Param        order      query  string  false  "Sort direction"  Enums(asc, desc)
+// @Param        paginated  query  bool    false  "Wrap the result in a pagination envelope"
+// @Param        cursor     query  string  false  "Return users after this id (next_cursor from the previous page)"
+// @Param        includeDeleted  query  bool  false  "Include soft-deleted users (admin only)"
+// @Param        inactiveSince   query  string  false  "Only users who haven't logged in since this RFC 3339 time (admin only)"
+// @Param        fields     query  string  false  "Comma-separated subset of id, username, email, role; other fields are left out"
+// @Param        If-None-Match  header  string  false  "ETag from an earlier response"
+// @Success      200  {array}   User
+// @Header       200  {string}  ETag  "Weak hash of the body"
+// @Header       200  {string}  Link  "first, prev, next and last page URLs (RFC 8288); last only with paginated=true"
+// @Success      304
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Failure      504  {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/users [get]
 func GetUsers(c *gin.Context) {
 	if dbPool == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection not established"})
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+		return
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	limit, offset, err := parsePagination(c)
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, err.Error())
+		return
+	}
+
+	orderBy, err := parseSort(c, userSortColumns, "id", "id")
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, err.Error())
+		return
+	}
+
+	withDeleted, ok := includeDeleted(c)
+	if !ok {
+		return
+	}
+
+	inactive, ok := inactiveSince(c)
+	if !ok {
 		return
 	}
 
-	rows, err := dbPool.Query(context.Background(), "SELECT id, username, email FROM up_users")
+	fields, err := userFields(c)
 	if err != nil {
-		log.Printf("Query error: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
+		RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, err.Error())
+		return
+	}
+
+	cacheKey, hit := userCache.serve(c)
+	if hit {
+		return
+	}
+
+	where, args := userFilter(c, withDeleted, inactive)
+	if cursor, ok := c.GetQuery("cursor"); ok {
+		getUsersAfterCursor(c, ctx, cacheKey, cursor, limit, fields, where, args)
+		return
+	}
+
+	pageArgs := append(args, limit, offset)
+	query := "SELECT " + userSelectColumns(fields) + " FROM up_users" + where + orderBy +
+		" LIMIT $" + strconv.Itoa(len(args)+1) + " OFFSET $" + strconv.Itoa(len(args)+2)
+
+	rows, err := dbPool.Query(ctx, query, pageArgs...)
+	if err != nil {
+		requestLogger(c).Error("Failed to query users", "error", err)
+		respondQueryError(c, err, "Failed to fetch users")
 		return
 	}
 	defer rows.Close()
 
-	var users []User
-	for rows.Next() {
+	users := []User{}
+	// rows.Next keeps returning rows already buffered after the context is
+	// canceled, so check it too and stop as soon as the client is gone.
+	for ctx.Err() == nil && rows.Next() {
 		var user User
-		err := rows.Scan(&user.ID, &user.Username, &user.Email)
-		if err != nil {
-			log.Printf("Row scan error: %v", err)
+		if err := scanUserFields(rows, &user, fields); err != nil {
+			requestLogger(c).Error("Failed to scan user row", "error", err)
 			continue
 		}
 		users = append(users, user)
 	}
 
-	if err := rows.Err(); err != nil {
-		log.Printf("Rows iteration error: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading users"})
+	if err := cmp.Or(rows.Err(), ctx.Err()); err != nil {
+		requestLogger(c).Error("Failed to iterate user rows", "error", err)
+		respondQueryError(c, err, "Error reading users")
+		return
+	}
+
+	// The bare array stays the default so existing clients keep working.
+	if c.Query("paginated") != "true" {
+		setPageLinks(c, limit, offset, len(users), -1)
+		userCache.respond(c, cacheKey, sparseUsers(users, fields))
+		return
+	}
+
+	var total int
+	if err := dbPool.QueryRow(ctx, "SELECT COUNT(*) FROM up_users"+where, args...).Scan(&total); err != nil {
+		requestLogger(c).Error("Failed to count users", "error", err)
+		respondQueryError(c, err, "Failed to count users")
+		return
+	}
+
+	setPageLinks(c, limit, offset, len(users), total)
+	page := any(PaginatedUsers{Data: users, Total: total, Limit: limit, Offset: offset})
+	if fields != nil {
+		page = gin.H{"data": sparseUsers(users, fields), "total": total, "limit": limit, "offset": offset}
+	}
+	userCache.respond(c, cacheKey, page)
+}
+
+// getUsersAfterCursor serves the keyset mode of GetUsers: rows with an id
+// above cursor, in id order. Unlike offsets, the cursor is unaffected by rows
+// inserted or deleted behind it, so concurrent writes never shift a page.
+func getUsersAfterCursor(c *gin.Context, ctx context.Context, cacheKey, cursor string, limit int, fields []string, where string, args []any) {
+	if c.Query("offset") != "" {
+		RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "cursor and offset cannot be combined")
+		return
+	}
+	if c.DefaultQuery("sort", "id") != "id" || strings.ToLower(c.DefaultQuery("order", "asc")) != "asc" {
+		RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "cursor pagination only supports sort=id&order=asc")
+		return
+	}
+
+	after := 0
+	if cursor != "" {
+		var err error
+		after, err = strconv.Atoi(cursor)
+		if err != nil || after < 0 {
+			RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "cursor must be a non-negative integer")
+			return
+		}
+	}
+
+	args = append(args, after)
+	where += " AND id > $" + strconv.Itoa(len(args))
+	// Fetch one extra row to learn whether another page exists.
+	args = append(args, limit+1)
+	query := "SELECT " + userSelectColumns(fields) + " FROM up_users" + where + " ORDER BY id LIMIT $" + strconv.Itoa(len(args))
+
+	rows, err := dbPool.Query(ctx, query, args...)
+	if err != nil {
+		requestLogger(c).Error("Failed to query users", "error", err)
+		respondQueryError(c, err, "Failed to fetch users")
+		return
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for ctx.Err() == nil && rows.Next() {
+		var user User
+		if err := scanUserFields(rows, &user, fields); err != nil {
+			requestLogger(c).Error("Failed to scan user row", "error", err)
+			continue
+		}
+		users = append(users, user)
+	}
+
+	if err := cmp.Or(rows.Err(), ctx.Err()); err != nil {
+		requestLogger(c).Error("Failed to iterate user rows", "error", err)
+		respondQueryError(c, err, "Error reading users")
+		return
+	}
+
+	page := CursorUsers{Data: users, Limit: limit}
+	if len(users) > limit {
+		page.Data = users[:limit]
+		if limit > 0 {
+			next := users[limit-1].ID
+			page.NextCursor = &next
+		}
+	}
+	if fields != nil {
+		userCache.respond(c, cacheKey, gin.H{"data": sparseUsers(page.Data, fields), "next_cursor": page.NextCursor, "limit": limit})
+		return
+	}
+	userCache.respond(c, cacheKey, page)
+}
+
+func scanUser(row pgx.Row, user *User) error {
+	return row.Scan(&user.ID, &user.Username, &user.Email, &user.Role, &user.EmailVerified, &user.DeletedAt)
+}
+
+// scanAdminUser scans a row selected with adminUserColumns.
+func scanAdminUser(row pgx.Row, user *User) error {
+	return row.Scan(&user.ID, &user.Username, &user.Email, &user.Role, &user.EmailVerified, &user.DeletedAt, &user.LastLoginAt)
+}
+
+// isAdmin reports whether the caller is signed in as an admin.
+func isAdmin(c *gin.Context) bool {
+	claims, _ := currentClaims(c)
+	return claims != nil && claims.Role == RoleAdmin
+}
+
+// includeDeleted reads ?includeDeleted=, which only admins may set. It
+// responds with 403 and returns ok=false when a non-admin asks for it.
+func includeDeleted(c *gin.Context) (include, ok bool) {
+	if c.Query("includeDeleted") != "true" {
+		return false, true
+	}
+	if !isAdmin(c) {
+		RespondError(c, http.StatusForbidden, ErrCodeForbidden, "Only admins can include deleted users")
+		return false, false
+	}
+	return true, true
+}
+
+// inactiveSince reads ?inactiveSince=, which only admins may set, as an RFC
+// 3339 time. It returns nil when the param is absent, and responds with 400
+// or 403 and returns ok=false when it can't be used.
+func inactiveSince(c *gin.Context) (since *time.Time, ok bool) {
+	v := c.Query("inactiveSince")
+	if v == "" {
+		return nil, true
+	}
+	if !isAdmin(c) {
+		RespondError(c, http.StatusForbidden, ErrCodeForbidden, "Only admins can filter by last login")
+		return nil, false
+	}
+	t, err := parseTimestamp(v)
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "inactiveSince must be an RFC 3339 timestamp with a zone offset")
+		return nil, false
+	}
+	return &t, true
+}
+
+// userFilter builds the WHERE clause shared by the user listing queries from
+// the caller's organization and the request's filter params. Placeholders are
+// numbered from $1. With inactive set, only users who haven't logged in since
+// then are kept; users who never have count as inactive once their account
+// is older than that.
+func userFilter(c *gin.Context, withDeleted bool, inactive *time.Time) (string, []any) {
+	conds := []string{"org_id = $1"}
+	args := []any{orgID(c)}
+
+	if !withDeleted {
+		conds = append(conds, "deleted_at IS NULL")
+	}
+
+	if inactive != nil {
+		args = append(args, *inactive)
+		n := "$" + strconv.Itoa(len(args))
+		conds = append(conds, "(last_login_at < "+n+" OR (last_login_at IS NULL AND created_at < "+n+"))")
+	}
+
+	if search := strings.TrimSpace(c.Query("search")); search != "" {
+		args = append(args, "%"+escapeLike(search)+"%")
+		n := "$" + strconv.Itoa(len(args))
+		conds = append(conds, "(username ILIKE "+n+" OR email ILIKE "+n+")")
+	}
+
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// escapeLike escapes LIKE wildcards so user input is matched literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// GetUserByID godoc
+// @Summary      Get a user
+// @Description  Admins can get anyone and also get last_login_at unless fields is given. Other users can only get themselves.
+// @Tags         users
+// @Produce      json
+// @Param        id              path      int   true   "User ID"
+// @Param        includeDeleted  query     bool  false  "Return the user even if soft-deleted (admin only)"
+// @Param        fields          query     string  false  "Comma-separated subset of id, username, email, role; other fields are left out"
+// @Param        If-None-Match  header  string  false  "ETag from an earlier response"
+// @Success      200  {object}  User
+// @Header       200  {string}  ETag  "Weak hash of the body"
+// @Success      304
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/users/{id} [get]
+func GetUserByID(c *gin.Context) {
+	if dbPool == nil {
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+		return
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid user id")
+		return
+	}
+	if actor := actorID(c); !isAdmin(c) && (actor == nil || *actor != id) {
+		RespondError(c, http.StatusForbidden, ErrCodeForbidden, "Insufficient permissions")
+		return
+	}
+
+	withDeleted, ok := includeDeleted(c)
+	if !ok {
+		return
+	}
+
+	fields, err := userFields(c)
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, err.Error())
+		return
+	}
+
+	columns := userSelectColumns(fields)
+	scan := func(row pgx.Row, user *User) error { return scanUserFields(row, user, fields) }
+	if fields == nil && isAdmin(c) {
+		// Admins also see when the user last logged in.
+		columns, scan = adminUserColumns, scanAdminUser
+	}
+	query := "SELECT " + columns + " FROM up_users WHERE id = $1 AND org_id = $2"
+	if !withDeleted {
+		query += " AND deleted_at IS NULL"
+	}
+
+	var user User
+	err = scan(dbPool.QueryRow(ctx, query, id, orgID(c)), &user)
+	if errors.Is(err, pgx.ErrNoRows) {
+		RespondError(c, http.StatusNotFound, ErrCodeNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		requestLogger(c).Error("Failed to query user", "user_id", id, "error", err)
+		respondQueryError(c, err, "Failed to fetch user")
+		return
+	}
+
+	if fields != nil {
+		c.JSON(http.StatusOK, sparseUser(user, fields))
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// CreateUser godoc
+// @Summary      Create a user
+// @Description  Admin only.
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        user  body      CreateUserRequest  true  "User to create"
+// @Success      201   {object}  User
+// @Failure      400   {object}  ErrorResponse
+// @Failure      401   {object}  ErrorResponse
+// @Failure      403   {object}  ErrorResponse
+// @Failure      409   {object}  ErrorResponse
+// @Failure      413   {object}  ErrorResponse
+// @Failure      500   {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/users [post]
+func CreateUser(c *gin.Context) {
+	var req CreateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindingError(c, err)
+		return
+	}
+
+	if user, ok := createUser(c, orgID(c), req.Username, req.Email, req.Password, true); ok {
+		c.JSON(http.StatusCreated, user)
+	}
+}
+
+// createUser inserts a user into org, hashing password when one is given. On
+// failure it writes the error response and returns false; on success the
+// caller responds. It is shared by admin creation and self-registration, which
+// leaves the email unverified.
+func createUser(c *gin.Context, org int, username, email, password string, emailVerified bool) (User, bool) {
+	if dbPool == nil {
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+		return User{}, false
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	var passwordHash *string
+	if password != "" {
+		hash, err := hashPassword(password)
+		if err != nil {
+			requestLogger(c).Error("Failed to hash password", "error", err)
+			RespondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to create user")
+			return User{}, false
+		}
+		passwordHash = &hash
+	}
+
+	user, err := insertUser(ctx, org, username, email, passwordHash, emailVerified)
+	if respondClientError(c, err) {
+		return User{}, false
+	}
+	if err != nil {
+		requestLogger(c).Error("Failed to insert user", "username", username, "error", err)
+		respondQueryError(c, err, "Failed to create user")
+		return User{}, false
+	}
+
+	userCache.invalidate(c)
+	// Self-registration has no signed-in actor; the new user is their own.
+	actor := actorID(c)
+	if actor == nil {
+		actor = &user.ID
+	}
+	recordAudit(c, actor, auditUserCreate, auditTarget{"user", user.ID},
+		gin.H{"username": user.Username, "email": user.Email, "role": user.Role})
+	return user, true
+}
+
+// insertUser inserts a local user into org. A username or email that is
+// already taken comes back as the *clientError from userConflict.
+func insertUser(ctx context.Context, org int, username, email string, passwordHash *string, emailVerified bool) (User, error) {
+	user := User{Username: username, Email: email, EmailVerified: emailVerified}
+	err := dbPool.QueryRow(ctx,
+		`INSERT INTO up_users (username, email, password, email_verified, org_id, provider, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, 'local', now(), now()) RETURNING id, role`,
+		username, email, passwordHash, emailVerified, org).Scan(&user.ID, &user.Role)
+	if ce := userConflict(err, username, email); ce != nil {
+		return User{}, ce
+	}
+	return user, err
+}
+
+// uniqueViolation reports the constraint behind a unique violation error.
+func uniqueViolation(err error) (constraint string, ok bool) {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode {
+		return pgErr.ConstraintName, true
+	}
+	return "", false
+}
+
+// userConflict maps a unique violation on up_users to a 409 naming the value
+// that collided. It returns nil for any other error.
+func userConflict(err error, username, email string) *clientError {
+	constraint, ok := uniqueViolation(err)
+	if !ok {
+		return nil
+	}
+	switch constraint {
+	case userEmailConstraint:
+		return &clientError{
+			status:  http.StatusConflict,
+			code:    ErrCodeEmailTaken,
+			msg:     fmt.Sprintf("Email %q is already taken", email),
+			details: gin.H{"email": email},
+		}
+	case userUsernameConstraint:
+		return &clientError{
+			status:  http.StatusConflict,
+			code:    ErrCodeUsernameTaken,
+			msg:     fmt.Sprintf("Username %q is already taken", username),
+			details: gin.H{"username": username},
+		}
+	default:
+		return newClientError(http.StatusConflict, ErrCodeConflict, "User conflicts with an existing user")
+	}
+}
+
+// UpdateUser godoc
+// @Summary      Update a user
+// @Description  Only the fields present in the body are changed. A new email must be verified again. Admins can update anyone; other users only themselves.
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        id    path      int                true  "User ID"
+// @Param        user  body      UpdateUserRequest  true  "Fields to change"
+// @Success      200   {object}  User
+// @Failure      400   {object}  ErrorResponse
+// @Failure      401   {object}  ErrorResponse
+// @Failure      403   {object}  ErrorResponse
+// @Failure      404   {object}  ErrorResponse
+// @Failure      409   {object}  ErrorResponse
+// @Failure      500   {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/users/{id} [patch]
+func UpdateUser(c *gin.Context) {
+	if dbPool == nil {
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+		return
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid user id")
+		return
+	}
+	if actor := actorID(c); !isAdmin(c) && (actor == nil || *actor != id) {
+		RespondError(c, http.StatusForbidden, ErrCodeForbidden, "Insufficient permissions")
+		return
+	}
+
+	var req UpdateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindingError(c, err)
+		return
+	}
+
+	// Only columns present in the body are written; values are always bound
+	// as placeholders so the statement shape is the only dynamic part.
+	var sets []string
+	var args []any
+	if req.Username != nil {
+		args = append(args, *req.Username)
+		sets = append(sets, "username = $"+strconv.Itoa(len(args)))
+	}
+	if req.Email != nil {
+		// A new address has to be verified again; SET sees the old email.
+		args = append(args, *req.Email)
+		n := strconv.Itoa(len(args))
+		sets = append(sets, "email = $"+n, "email_verified = email_verified AND email = $"+n)
+	}
+	if len(sets) == 0 {
+		RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "No updatable fields provided")
+		return
+	}
+
+	org := orgID(c)
+	args = append(args, id, org)
+	query := "UPDATE up_users SET " + strings.Join(sets, ", ") + ", updated_at = now() WHERE id = $" +
+		strconv.Itoa(len(args)-1) + " AND org_id = $" + strconv.Itoa(len(args)) + " RETURNING " + userColumns
+
+	var before, user User
+	err = WithTx(ctx, func(tx pgx.Tx) error {
+		// Read the old values under lock so the audit diff matches what the
+		// update replaced.
+		err := scanUser(tx.QueryRow(ctx,
+			"SELECT "+userColumns+" FROM up_users WHERE id = $1 AND org_id = $2 AND deleted_at IS NULL FOR UPDATE", id, org), &before)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return newClientError(http.StatusNotFound, ErrCodeNotFound, "User not found")
+		}
+		if err != nil {
+			return err
+		}
+
+		err = scanUser(tx.QueryRow(ctx, query, args...), &user)
+		if err == nil {
+			return nil
+		}
+		var username, email string
+		if req.Username != nil {
+			username = *req.Username
+		}
+		if req.Email != nil {
+			email = *req.Email
+		}
+		if ce := userConflict(err, username, email); ce != nil {
+			return ce
+		}
+		return err
+	})
+	if respondClientError(c, err) {
+		return
+	}
+	if err != nil {
+		requestLogger(c).Error("Failed to update user", "user_id", id, "error", err)
+		respondQueryError(c, err, "Failed to update user")
+		return
+	}
+
+	if changes := userChanges(before, user); len(changes) > 0 {
+		recordAudit(c, actorID(c), auditUserUpdate, auditTarget{"user", id}, gin.H{"changes": changes})
+	}
+	userCache.invalidate(c)
+	c.JSON(http.StatusOK, user)
+}
+
+// DeleteUser godoc
+// @Summary      Delete a user
+// @Description  Soft-deletes the user by setting deleted_at; the row is kept for audit, and POST /v1/users/{id}/restore undoes it. Admin only.
+// @Tags         users
+// @Param        id   path  int  true  "User ID"
+// @Success      204
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/users/{id} [delete]
+func DeleteUser(c *gin.Context) {
+	if dbPool == nil {
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+		return
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid user id")
+		return
+	}
+
+	username, email, err := softDeleteUser(ctx, orgID(c), id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		RespondError(c, http.StatusNotFound, ErrCodeNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		requestLogger(c).Error("Failed to delete user", "user_id", id, "error", err)
+		respondQueryError(c, err, "Failed to delete user")
+		return
+	}
+
+	userCache.invalidate(c)
+	recordAudit(c, actorID(c), auditUserDelete, auditTarget{"user", id}, gin.H{"username": username, "email": email})
+	c.Status(http.StatusNoContent)
+}
+
+// softDeleteUser marks an active user of org deleted and returns the
+// username and email it had, for the audit entry. pgx.ErrNoRows means there
+// was no such user.
+func softDeleteUser(ctx context.Context, org, id int) (username, email string, err error) {
+	err = dbPool.QueryRow(ctx,
+		`UPDATE up_users SET deleted_at = now(), updated_at = now()
+		WHERE id = $1 AND org_id = $2 AND deleted_at IS NULL RETURNING username, email`,
+		id, org).Scan(&username, &email)
+	return username, email, err
+}
+
+// RestoreUser godoc
+// @Summary      Restore a deleted user
+// @Description  Clears deleted_at on a soft-deleted user and returns it. Admin only.
+// @Tags         users
+// @Produce      json
+// @Param        id   path      int  true  "User ID"
+// @Success      200  {object}  User
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      409  {object}  ErrorResponse  "The user is not deleted"
+// @Failure      500  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/users/{id}/restore [post]
+func RestoreUser(c *gin.Context) {
+	if dbPool == nil {
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+		return
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid user id")
+		return
+	}
+
+	var user User
+	err = scanAdminUser(dbPool.QueryRow(ctx,
+		`UPDATE up_users SET deleted_at = NULL, updated_at = now()
+		WHERE id = $1 AND org_id = $2 AND deleted_at IS NOT NULL RETURNING `+adminUserColumns,
+		id, orgID(c)), &user)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Nothing to restore: tell a missing user from an active one.
+		var exists bool
+		err = dbPool.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM up_users WHERE id = $1 AND org_id = $2)",
+			id, orgID(c)).Scan(&exists)
+		if err == nil && !exists {
+			RespondError(c, http.StatusNotFound, ErrCodeNotFound, "User not found")
+			return
+		}
+		if err == nil {
+			RespondError(c, http.StatusConflict, ErrCodeConflict, "User is not deleted")
+			return
+		}
+	}
+	if err != nil {
+		requestLogger(c).Error("Failed to restore user", "user_id", id, "error", err)
+		respondQueryError(c, err, "Failed to restore user")
 		return
 	}
 
-	c.JSON(http.StatusOK, users)
+	userCache.invalidate(c)
+	recordAudit(c, actorID(c), auditUserRestore, auditTarget{"user", id}, gin.H{"username": user.Username, "email": user.Email})
+	c.JSON(http.StatusOK, user)
 }
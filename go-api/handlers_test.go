@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func TestGetUserByIDIsSelfOrAdmin(t *testing.T) {
+	ctx := testDB(t)
+	org := testOrg(t, ctx)
+	student := testUser(t, ctx, org, RoleStudent, "")
+	other := testUser(t, ctx, org, RoleStudent, "")
+	teacher := testUser(t, ctx, org, RoleTeacher, "")
+	admin := testUser(t, ctx, org, RoleAdmin, "")
+
+	tests := []struct {
+		name   string
+		caller User
+		target User
+		want   int
+	}{
+		{"student reading themselves", student, student, http.StatusOK},
+		{"student reading another user", student, other, http.StatusForbidden},
+		{"teacher reading a student", teacher, student, http.StatusForbidden},
+		{"admin reading a student", admin, student, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveRoute(t, http.MethodGet, "/users/:id", "/users/"+strconv.Itoa(tt.target.ID), GetUserByID, nil,
+				&Claims{UserID: tt.caller.ID, Role: tt.caller.Role, OrgID: org})
+			if w.Code != tt.want {
+				t.Fatalf("got %d %s, want %d", w.Code, w.Body, tt.want)
+			}
+			if tt.want == http.StatusForbidden && errorCode(t, w) != ErrCodeForbidden {
+				t.Errorf("code %s, want %s", errorCode(t, w), ErrCodeForbidden)
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	healthPingTimeout          = 2 * time.Second
+	defaultHealthCheckInterval = 5 * time.Second
+)
+
+// dbHealthy is maintained by the background checker and read by /readyz, so
+// readiness reflects DB restarts without every probe paying for a ping.
+var dbHealthy atomic.Bool
+
+// StartDBHealthChecker pings the pool every interval until ctx is done,
+// flipping dbHealthy and logging each transition. pgxpool replaces broken
+// connections on its own, so recovery needs nothing beyond noticing it.
+func StartDBHealthChecker(ctx context.Context, interval time.Duration) {
+	dbHealthy.Store(dbPool != nil)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			pingCtx, cancel := context.WithTimeout(ctx, healthPingTimeout)
+			err := pingDB(pingCtx)
+			cancel()
+			if ctx.Err() != nil {
+				return
+			}
+
+			healthy := err == nil
+			if dbHealthy.Swap(healthy) == healthy {
+				continue
+			}
+			if healthy {
+				logger.Info("Database connection recovered")
+			} else {
+				logger.Warn("Database became unreachable", "error", err)
+			}
+		}
+	}()
+}
+
+// HealthCheck godoc
+// @Summary      Health check including a database ping
+// @Tags         health
+// @Produce      json
+// @Success      200  {object}  map[string]any
+// @Failure      503  {object}  map[string]any
+// @Router       /health [get]
+func HealthCheck(c *gin.Context) {
+	if dbPool == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "degraded", "error": "Database connection not established"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), healthPingTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := pingDB(ctx)
+	latency := time.Since(start)
+
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "degraded", "db_latency_ms": latency.Milliseconds()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "db_latency_ms": latency.Milliseconds()})
+}
+
+const (
+	healthOK       = "ok"
+	healthDown     = "down"
+	healthDisabled = "disabled"
+)
+
+// healthCheck probes one dependency. A nil probe means the dependency isn't
+// configured, which is reported but never counts against the total. circuit,
+// when set, reports the state of the breaker in front of the dependency.
+type healthCheck struct {
+	name    string
+	probe   func(ctx context.Context) error
+	circuit func() string
+}
+
+// dependencyChecks lists what /health/detailed probes. Redis and SMTP are
+// optional; the database and storage are always probed.
+func dependencyChecks(cfg *Config, store blobStore) []healthCheck {
+	checks := []healthCheck{
+		{name: "database", probe: func(ctx context.Context) error {
+			if dbPool == nil {
+				return errors.New("database connection not established")
+			}
+			return pingDB(ctx)
+		}, circuit: func() string {
+			if b := dbBreaker.Load(); b != nil {
+				return b.State().String()
+			}
+			return ""
+		}},
+		{name: "redis"},
+		{name: "smtp"},
+		{name: "storage", probe: store.Ping},
+	}
+	if redisClient != nil {
+		checks[1].probe = func(ctx context.Context) error { return redisClient.Ping(ctx).Err() }
+	}
+	if smtp, ok := NewEmailSender(cfg).(*smtpSender); ok {
+		checks[2].probe = smtp.Ping
+	}
+	return checks
+}
+
+// DetailedHealthCheck godoc
+// @Summary      Health of every dependency
+// @Description  Probes the database, Redis, the SMTP relay and attachment storage concurrently, each with its own timeout, and reports each one's status and latency, plus whether read-only or maintenance mode is on. The overall status is down, with a 503, if any configured dependency is. /health stays the cheap check for load balancers.
+// @Tags         health
+// @Produce      json
+// @Success      200  {object}  DetailedHealth
+// @Failure      503  {object}  DetailedHealth
+// @Router       /health/detailed [get]
+func DetailedHealthCheck(checks []healthCheck) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report := DetailedHealth{Status: healthOK, ReadOnly: readOnly.Load(),
+			Maintenance: maintenance.Load(), Components: make(map[string]HealthComponent, len(checks))}
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		for _, check := range checks {
+			if check.probe == nil {
+				report.Components[check.name] = HealthComponent{Status: healthDisabled}
+				continue
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				ctx, cancel := context.WithTimeout(c.Request.Context(), healthPingTimeout)
+				defer cancel()
+
+				start := time.Now()
+				err := check.probe(ctx)
+				latency := time.Since(start).Milliseconds()
+
+				component := HealthComponent{Status: healthOK, LatencyMs: &latency}
+				if check.circuit != nil {
+					component.Circuit = check.circuit()
+				}
+				if err != nil {
+					component.Status = healthDown
+					requestLogger(c).Warn("Health check failed", "component", check.name, "error", err)
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				report.Components[check.name] = component
+				if err != nil {
+					report.Status = healthDown
+				}
+			}()
+		}
+		wg.Wait()
+
+		status := http.StatusOK
+		if report.Status != healthOK {
+			status = http.StatusServiceUnavailable
+		}
+		c.JSON(status, report)
+	}
+}
+
+// Liveness only reports that the process is serving; it never touches the
+// database so a slow DB can't get the pod killed.
+//
+// @Summary      Liveness probe
+// @Tags         health
+// @Produce      json
+// @Success      200  {object}  map[string]string
+// @Router       /livez [get]
+func Livez(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readyz godoc
+// @Summary      Readiness probe
+// @Description  Reports the last result of the background database health check rather than pinging on each call.
+// @Tags         health
+// @Produce      json
+// @Success      200  {object}  map[string]string
+// @Failure      503  {object}  map[string]string
+// @Router       /readyz [get]
+func Readyz(c *gin.Context) {
+	if dbPool == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "not ready", "error": "Database connection not established"})
+		return
+	}
+
+	if !dbHealthy.Load() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "not ready", "error": "Database unreachable"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ready"})
+}
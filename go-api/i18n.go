@@ -0,0 +1,122 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/text/language"
+)
+
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+// sourceLanguage is the language error messages are written in. It needs no
+// bundle: its messages are served as written.
+var sourceLanguage = language.English
+
+// messageBundle translates error messages into one language. Messages maps
+// a message as written to its translation; Codes holds a generic message per
+// error code for the messages the bundle doesn't list, such as ones carrying
+// ids or limits, so a translated response never falls back to English.
+type messageBundle struct {
+	Codes    map[string]string `json:"codes"`
+	Messages map[string]string `json:"messages"`
+}
+
+var (
+	// languages are the languages responses can be in, the default first.
+	languages       []language.Tag
+	languageMatcher language.Matcher
+	bundles         map[language.Tag]*messageBundle
+)
+
+// loadMessageBundles reads locales/<tag>.json for every language besides
+// the source one and makes defaultLang the one served when Accept-Language
+// names nothing supported.
+func loadMessageBundles(defaultLang string) error {
+	def, err := language.Parse(defaultLang)
+	if err != nil {
+		return fmt.Errorf("DEFAULT_LANGUAGE: %w", err)
+	}
+
+	entries, err := fs.ReadDir(localeFiles, "locales")
+	if err != nil {
+		return err
+	}
+	loaded := map[language.Tag]*messageBundle{}
+	tags := []language.Tag{sourceLanguage}
+	for _, e := range entries {
+		tag, err := language.Parse(strings.TrimSuffix(e.Name(), ".json"))
+		if err != nil {
+			return fmt.Errorf("locale %s: %w", e.Name(), err)
+		}
+		raw, err := localeFiles.ReadFile(path.Join("locales", e.Name()))
+		if err != nil {
+			return err
+		}
+		b := &messageBundle{}
+		if err := json.Unmarshal(raw, b); err != nil {
+			return fmt.Errorf("locale %s: %w", e.Name(), err)
+		}
+		loaded[tag] = b
+		tags = append(tags, tag)
+	}
+
+	i := -1
+	for j, tag := range tags {
+		if tag == def {
+			i = j
+		}
+	}
+	if i < 0 {
+		return fmt.Errorf("DEFAULT_LANGUAGE %q has no message bundle", defaultLang)
+	}
+	tags[0], tags[i] = tags[i], tags[0]
+
+	languages, languageMatcher, bundles = tags, language.NewMatcher(tags), loaded
+	return nil
+}
+
+// requestLanguage picks the supported language that best matches the
+// request's Accept-Language, or the default one.
+func requestLanguage(c *gin.Context) language.Tag {
+	if languageMatcher == nil {
+		return sourceLanguage
+	}
+	accepted, _, _ := language.ParseAcceptLanguage(c.GetHeader("Accept-Language"))
+	_, i, confidence := languageMatcher.Match(accepted...)
+	if confidence == language.No {
+		i = 0
+	}
+	return languages[i]
+}
+
+// localize translates an error's message, and the messages of any field
+// errors in details, into lang. Codes are never translated.
+func localize(lang language.Tag, code, msg string, details any) (string, any) {
+	b, ok := bundles[lang]
+	if !ok {
+		return msg, details
+	}
+	if m, ok := b.Messages[msg]; ok {
+		msg = m
+	} else if m, ok := b.Codes[code]; ok {
+		msg = m
+	}
+	if fields, ok := details.([]FieldError); ok {
+		translated := make([]FieldError, len(fields))
+		for i, fe := range fields {
+			if m, ok := b.Messages[fe.Message]; ok {
+				fe.Message = m
+			}
+			translated[i] = fe
+		}
+		details = translated
+	}
+	return msg, details
+}
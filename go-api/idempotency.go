@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	maxIdempotencyKeyLength   = 255
+	defaultIdempotencyKeyTTL  = 24 * time.Hour
+	idempotencyReplayedHeader = "Idempotent-Replayed"
+)
+
+// submissionFingerprint identifies what a request asked for, so a key
+// reused for a different submission is caught rather than replayed.
+func submissionFingerprint(examID int, answers []SubmissionAnswerInput) (string, error) {
+	body, err := json.Marshal(answers)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(append([]byte(strconv.Itoa(examID)+":"), body...))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// claimIdempotencyKey reserves key for userID on tx. It returns the id of the
+// submission an earlier request with the same key created, or 0 when this
+// request is the first and should go ahead. A concurrent request with the
+// same key blocks here until the first one commits or rolls back.
+func claimIdempotencyKey(ctx context.Context, tx pgx.Tx, userID int, key string, examID int, fingerprint string, ttl time.Duration) (int, error) {
+	// Expired keys, this one included, are cleared as the user makes new ones.
+	if _, err := tx.Exec(ctx, "DELETE FROM idempotency_keys WHERE user_id = $1 AND expires_at <= now()", userID); err != nil {
+		return 0, err
+	}
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO idempotency_keys (user_id, key, exam_id, fingerprint, expires_at)
+		VALUES ($1, $2, $3, $4, now() + make_interval(secs => $5))
+		ON CONFLICT (user_id, key) DO NOTHING`, userID, key, examID, fingerprint, ttl.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	if tag.RowsAffected() == 1 {
+		return 0, nil
+	}
+
+	var storedExam int
+	var storedFingerprint string
+	var submissionID *int
+	err = tx.QueryRow(ctx, `
+		SELECT exam_id, fingerprint, submission_id FROM idempotency_keys
+		WHERE user_id = $1 AND key = $2`, userID, key).Scan(&storedExam, &storedFingerprint, &submissionID)
+	if err != nil {
+		return 0, err
+	}
+	if storedExam != examID || storedFingerprint != fingerprint || submissionID == nil {
+		return 0, newClientError(http.StatusUnprocessableEntity, ErrCodeIdempotencyKeyReused,
+			"Idempotency-Key was already used for a different request")
+	}
+	return *submissionID, nil
+}
+
+// bindIdempotencyKey records the submission created under key.
+func bindIdempotencyKey(ctx context.Context, tx pgx.Tx, userID int, key string, submissionID int) error {
+	_, err := tx.Exec(ctx,
+		"UPDATE idempotency_keys SET submission_id = $1 WHERE user_id = $2 AND key = $3", submissionID, userID, key)
+	return err
+}
+
+// loadSubmission reads back a stored submission in the shape CreateSubmission
+// returns it.
+func loadSubmission(ctx context.Context, q querier, id int) (*Submission, error) {
+	s := &Submission{ID: id}
+	err := q.QueryRow(ctx,
+		"SELECT exam_id, user_id, submitted_at, score, max_score FROM submissions WHERE id = $1", id).
+		Scan(&s.ExamID, &s.UserID, &s.SubmittedAt, &s.Score, &s.MaxScore)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := q.Query(ctx, "SELECT question_id, answer FROM submission_answers WHERE submission_id = $1 ORDER BY id", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	s.Answers = []SubmissionAnswer{}
+	for rows.Next() {
+		var a SubmissionAnswer
+		if err := rows.Scan(&a.QuestionID, &a.Answer); err != nil {
+			return nil, err
+		}
+		s.Answers = append(s.Answers, a)
+	}
+	return s, rows.Err()
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rankedSubmissions is each candidate's best fully scored submission to exam
+// $1. Ties go to whoever got there first, then to the lower submission id, so
+// the order never shifts between requests. Soft-deleted users drop out.
+const rankedSubmissions = `
+	WITH best AS (
+		SELECT DISTINCT ON (s.user_id) s.id, s.user_id, s.score, s.max_score, s.submitted_at
+		FROM submissions s
+		WHERE s.exam_id = $1 AND s.score IS NOT NULL
+			AND NOT EXISTS (
+				SELECT 1 FROM submission_answers a WHERE a.submission_id = s.id AND a.points_awarded IS NULL)
+		ORDER BY s.user_id, s.score DESC, s.submitted_at, s.id
+	)
+	SELECT b.user_id, u.username, b.score, b.max_score, b.submitted_at
+	FROM best b JOIN up_users u ON u.id = b.user_id AND u.deleted_at IS NULL`
+
+// GetExamLeaderboard godoc
+// @Summary      Exam leaderboard
+// @Description  Ranks candidates by their best score, earliest submission first on a tie. Submissions with short answers still waiting for a grader are left out. Pass anonymize=true to mask usernames; students always see everyone but themselves masked.
+// @Tags         submissions
+// @Produce      json
+// @Param        id         path   int   true   "Exam ID"
+// @Param        anonymize  query  bool  false  "Mask usernames"
+// @Param        limit      query  int   false  "Page size (default 20, max 100)"
+// @Param        offset     query  int   false  "Rows to skip"
+// @Success      200  {object}  Leaderboard
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/exams/{id}/leaderboard [get]
+func GetExamLeaderboard(c *gin.Context) {
+	if dbPool == nil {
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+		return
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	examID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid exam id")
+		return
+	}
+
+	limit, offset, err := parsePagination(c)
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, err.Error())
+		return
+	}
+
+	claims, ok := currentClaims(c)
+	if !ok {
+		RespondError(c, http.StatusUnauthorized, ErrCodeUnauthorized, "Authentication required")
+		return
+	}
+	anonymize := c.Query("anonymize") == "true"
+	staff := claims.Role == RoleTeacher || claims.Role == RoleAdmin
+
+	exists, err := examExists(ctx, orgID(c), examID)
+	if err != nil {
+		requestLogger(c).Error("Failed to look up exam", "exam_id", examID, "error", err)
+		respondQueryError(c, err, "Failed to fetch leaderboard")
+		return
+	}
+	if !exists {
+		RespondError(c, http.StatusNotFound, ErrCodeNotFound, "Exam not found")
+		return
+	}
+
+	rows, err := dbPool.Query(ctx, rankedSubmissions+`
+		ORDER BY b.score DESC, b.submitted_at, b.id
+		LIMIT $2 OFFSET $3`, examID, limit, offset)
+	if err != nil {
+		requestLogger(c).Error("Failed to query leaderboard", "exam_id", examID, "error", err)
+		respondQueryError(c, err, "Failed to fetch leaderboard")
+		return
+	}
+	defer rows.Close()
+
+	entries := []LeaderboardEntry{}
+	for rows.Next() {
+		var e LeaderboardEntry
+		var userID int
+		if err := rows.Scan(&userID, &e.Username, &e.Score, &e.MaxScore, &e.SubmittedAt); err != nil {
+			requestLogger(c).Error("Failed to scan leaderboard row", "error", err)
+			respondQueryError(c, err, "Error reading leaderboard")
+			return
+		}
+		e.Rank = offset + len(entries) + 1
+		if userID == claims.UserID || (staff && !anonymize) {
+			e.UserID = &userID
+		} else {
+			e.Username = maskUsername(e.Username)
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		requestLogger(c).Error("Failed to iterate leaderboard rows", "error", err)
+		respondQueryError(c, err, "Error reading leaderboard")
+		return
+	}
+
+	var total int
+	if err := dbPool.QueryRow(ctx, "SELECT COUNT(*) FROM ("+rankedSubmissions+") ranked", examID).Scan(&total); err != nil {
+		requestLogger(c).Error("Failed to count leaderboard", "exam_id", examID, "error", err)
+		respondQueryError(c, err, "Failed to count leaderboard")
+		return
+	}
+
+	c.JSON(http.StatusOK, Leaderboard{Data: entries, Total: total, Limit: limit, Offset: offset})
+}
+
+// maskUsername keeps the first character so a masked board still reads
+// like a list of people.
+func maskUsername(name string) string {
+	r, _ := utf8.DecodeRuneInString(name)
+	if r == utf8.RuneError {
+		return "***"
+	}
+	return string(r) + "***"
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	liveTickInterval = 1 * time.Second
+	liveWriteWait    = 10 * time.Second
+	livePongWait     = 60 * time.Second
+	livePingInterval = livePongWait * 9 / 10
+	liveMaxMessage   = 64 << 10
+)
+
+// Messages sent to the client over /exams/:id/live.
+const (
+	liveMsgTick      = "tick"
+	liveMsgSaved     = "saved"
+	liveMsgSubmitted = "submitted"
+	liveMsgError     = "error"
+)
+
+// liveSession is an open timed attempt. The deadline is derived from the
+// stored start time, so reconnecting resumes the same countdown.
+type liveSession struct {
+	ID       int
+	OrgID    int
+	ExamID   int
+	UserID   int
+	Deadline time.Time
+}
+
+type liveClientMessage struct {
+	Type    string                  `json:"type"`
+	Answers []SubmissionAnswerInput `json:"answers"`
+}
+
+type liveServerMessage struct {
+	Type             string      `json:"type"`
+	RemainingSeconds *int        `json:"remaining_seconds,omitempty"`
+	Deadline         *time.Time  `json:"deadline,omitempty"`
+	Submission       *Submission `json:"submission,omitempty"`
+	Message          string      `json:"message,omitempty"`
+}
+
+// LiveExam godoc
+// @Summary      Live exam timer
+// @Description  WebSocket. Starts the caller's timed session, or resumes it, and streams {"type":"tick","remaining_seconds":n} every second. Send {"type":"answers","answers":[...]} to save a draft; when the clock reaches zero the latest draft is submitted and scored and a {"type":"submitted"} message is sent before the server closes the connection. Browsers may pass the token as access_token in the query string.
+// @Tags         submissions
+// @Param        id            path   int     true   "Exam ID"
+// @Param        access_token  query  string  false  "JWT, for clients that can't set the Authorization header"
+// @Success      101
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      409  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/exams/{id}/live [get]
+func LiveExam(allowedOrigins []string) gin.HandlerFunc {
+	upgrader := websocket.Upgrader{CheckOrigin: originChecker(allowedOrigins)}
+
+	return func(c *gin.Context) {
+		if dbPool == nil {
+			RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+			return
+		}
+
+		examID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid exam id")
+			return
+		}
+
+		claims, ok := currentClaims(c)
+		if !ok {
+			RespondError(c, http.StatusUnauthorized, ErrCodeUnauthorized, "Authentication required")
+			return
+		}
+
+		// Resolve the session before upgrading so failures are still plain
+		// HTTP errors the client can read.
+		ctx, cancel := queryContext(c)
+		session, err := startLiveSession(ctx, claims.OrgID, examID, claims.UserID)
+		cancel()
+		if respondClientError(c, err) {
+			return
+		}
+		if err != nil {
+			requestLogger(c).Error("Failed to start exam session", "exam_id", examID, "user_id", claims.UserID, "error", err)
+			respondQueryError(c, err, "Failed to start exam session")
+			return
+		}
+
+		conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+		if err != nil {
+			// Upgrade has already written the HTTP error.
+			requestLogger(c).Warn("WebSocket upgrade failed", "error", err)
+			return
+		}
+		defer conn.Close()
+
+		runLiveSession(c, conn, session)
+	}
+}
+
+// runLiveSession drives one connection until the client leaves or time runs
+// out. Only this goroutine writes to conn; a reader goroutine forwards client
+// messages and reports disconnects.
+func runLiveSession(c *gin.Context, conn *websocket.Conn, session *liveSession) {
+	log := requestLogger(c).With("exam_id", session.ExamID, "user_id", session.UserID, "session_id", session.ID)
+
+	messages := make(chan liveClientMessage)
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		conn.SetReadLimit(liveMaxMessage)
+		conn.SetReadDeadline(time.Now().Add(livePongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(livePongWait))
+		})
+		for {
+			var msg liveClientMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			select {
+			case messages <- msg:
+			case <-c.Request.Context().Done():
+				return
+			}
+		}
+	}()
+
+	send := func(msg liveServerMessage) bool {
+		conn.SetWriteDeadline(time.Now().Add(liveWriteWait))
+		return conn.WriteJSON(msg) == nil
+	}
+
+	ticker := time.NewTicker(liveTickInterval)
+	defer ticker.Stop()
+	pinger := time.NewTicker(livePingInterval)
+	defer pinger.Stop()
+
+	for {
+		remaining := time.Until(session.Deadline)
+		if remaining <= 0 {
+			finishLiveSession(c, conn, log, session)
+			return
+		}
+
+		seconds := int(remaining.Round(time.Second).Seconds())
+		if !send(liveServerMessage{Type: liveMsgTick, RemainingSeconds: &seconds, Deadline: &session.Deadline}) {
+			return
+		}
+
+		select {
+		case <-ticker.C:
+		case <-pinger.C:
+			if conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(liveWriteWait)) != nil {
+				return
+			}
+		case msg := <-messages:
+			if msg.Type != "answers" {
+				send(liveServerMessage{Type: liveMsgError, Message: "unknown message type"})
+				continue
+			}
+			if err := saveLiveDraft(c, session, msg.Answers); err != nil {
+				var ce *clientError
+				if !errors.As(err, &ce) {
+					log.Error("Failed to save draft answers", "error", err)
+					ce = newClientError(http.StatusInternalServerError, ErrCodeDBError, "Failed to save answers")
+				}
+				send(liveServerMessage{Type: liveMsgError, Message: ce.msg})
+				continue
+			}
+			send(liveServerMessage{Type: liveMsgSaved})
+		case <-gone:
+			// The session stays open; reconnecting resumes the countdown.
+			log.Info("Live exam client disconnected", "remaining", remaining.Round(time.Second).String())
+			return
+		}
+	}
+}
+
+// finishLiveSession auto-submits the session and tells the client before
+// closing the connection.
+func finishLiveSession(c *gin.Context, conn *websocket.Conn, log *slog.Logger, session *liveSession) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(c.Request.Context()), queryTimeout)
+	defer cancel()
+
+	submission, err := autoSubmitSession(ctx, session)
+	conn.SetWriteDeadline(time.Now().Add(liveWriteWait))
+	if err != nil {
+		log.Error("Failed to auto-submit exam session", "error", err)
+		conn.WriteJSON(liveServerMessage{Type: liveMsgError, Message: "Failed to submit exam"})
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseInternalServerErr, ""))
+		return
+	}
+
+	conn.WriteJSON(liveServerMessage{Type: liveMsgSubmitted, Submission: submission})
+	conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "time is up"))
+}
+
+// startLiveSession returns the caller's open session for the exam, starting
+// one if needed. Starting is refused once the caller has no attempts left.
+func startLiveSession(ctx context.Context, org, examID, userID int) (*liveSession, error) {
+	session := &liveSession{OrgID: org, ExamID: examID, UserID: userID}
+	err := WithTx(ctx, func(tx pgx.Tx) error {
+		if err := lockSubmitter(ctx, tx, examID, userID); err != nil {
+			return err
+		}
+
+		var allowRetakes bool
+		var maxAttempts *int
+		err := tx.QueryRow(ctx, "SELECT allow_retakes, max_attempts FROM exams WHERE id = $1 AND org_id = $2", examID, org).
+			Scan(&allowRetakes, &maxAttempts)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return newClientError(http.StatusNotFound, ErrCodeNotFound, "Exam not found")
+		}
+		if err != nil {
+			return err
+		}
+
+		const openSession = `
+			SELECT s.id, s.started_at + make_interval(mins => e.duration_minutes)
+			FROM exam_sessions s JOIN exams e ON e.id = s.exam_id
+			WHERE s.exam_id = $1 AND s.user_id = $2 AND s.closed_at IS NULL`
+		err = tx.QueryRow(ctx, openSession, examID, userID).Scan(&session.ID, &session.Deadline)
+		if err == nil || !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+
+		if err := checkAttempts(ctx, tx, examID, userID, allowRetakes, maxAttempts); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(ctx, "INSERT INTO exam_sessions (exam_id, user_id) VALUES ($1, $2)", examID, userID); err != nil {
+			return err
+		}
+		return tx.QueryRow(ctx, openSession, examID, userID).Scan(&session.ID, &session.Deadline)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// saveLiveDraft validates answers and stores them as the session's draft,
+// replacing the previous one.
+func saveLiveDraft(c *gin.Context, session *liveSession, answers []SubmissionAnswerInput) error {
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	if err := validateAnswers(ctx, dbPool, session.ExamID, answers); err != nil {
+		return err
+	}
+	draft, err := json.Marshal(answers)
+	if err != nil {
+		return err
+	}
+	tag, err := dbPool.Exec(ctx,
+		"UPDATE exam_sessions SET draft_answers = $1 WHERE id = $2 AND closed_at IS NULL", draft, session.ID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return newClientError(http.StatusConflict, ErrCodeAlreadySubmitted, "Exam already submitted")
+	}
+	return nil
+}
+
+// autoSubmitSession submits the session's draft when its time runs out. It is
+// idempotent: if another connection or a manual submit got there first, that
+// submission is returned instead.
+func autoSubmitSession(ctx context.Context, session *liveSession) (*Submission, error) {
+	var submission *Submission
+	err := WithTx(ctx, func(tx pgx.Tx) error {
+		if err := lockSubmitter(ctx, tx, session.ExamID, session.UserID); err != nil {
+			return err
+		}
+
+		var draft []byte
+		var submissionID *int
+		var closed bool
+		err := tx.QueryRow(ctx,
+			"SELECT draft_answers, submission_id, closed_at IS NOT NULL FROM exam_sessions WHERE id = $1",
+			session.ID).Scan(&draft, &submissionID, &closed)
+		if err != nil {
+			return err
+		}
+		if closed {
+			if submissionID == nil {
+				return errors.New("exam session closed without a submission")
+			}
+			submission = &Submission{ID: *submissionID, ExamID: session.ExamID, UserID: session.UserID}
+			return tx.QueryRow(ctx, "SELECT submitted_at, score, max_score FROM submissions WHERE id = $1", *submissionID).
+				Scan(&submission.SubmittedAt, &submission.Score, &submission.MaxScore)
+		}
+
+		var answers []SubmissionAnswerInput
+		if err := json.Unmarshal(draft, &answers); err != nil {
+			return err
+		}
+		// The draft was valid when saved, but questions may have changed
+		// since; an unusable draft still closes the attempt, just empty.
+		var ce *clientError
+		if err := validateAnswers(ctx, tx, session.ExamID, answers); errors.As(err, &ce) {
+			answers = nil
+		} else if err != nil {
+			return err
+		}
+
+		submission, err = insertSubmission(ctx, tx, session.OrgID, session.ExamID, session.UserID, answers)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return submission, nil
+}
+
+// originChecker mirrors the CORS allowlist for WebSocket handshakes, which
+// browsers send cross-origin without a preflight.
+func originChecker(allowedOrigins []string) func(r *http.Request) bool {
+	allowAll := false
+	allowed := make(map[string]bool, len(allowedOrigins))
+	for _, o := range allowedOrigins {
+		if o == "*" {
+			allowAll = true
+			continue
+		}
+		allowed[o] = true
+	}
+
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		return origin == "" || allowAll || allowed[origin]
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultLoginMaxAttempts     = 5
+	defaultLoginLockoutDuration = 15 * time.Minute
+)
+
+// loginLockout locks an account for duration after maxAttempts consecutive
+// wrong passwords. A zero maxAttempts disables it.
+type loginLockout struct {
+	maxAttempts int
+	duration    time.Duration
+}
+
+// recordFailure counts a wrong password for userID and starts the lock when
+// the threshold is reached. The counter restarts with the lock, so once it
+// lapses the user gets a full set of attempts again.
+func (l loginLockout) recordFailure(ctx context.Context, userID int) error {
+	if l.maxAttempts <= 0 {
+		return nil
+	}
+	_, err := dbPool.Exec(ctx, `
+		UPDATE up_users SET
+			locked_until = CASE WHEN failed_login_attempts + 1 >= $2
+				THEN now() + make_interval(secs => $3) ELSE locked_until END,
+			failed_login_attempts = CASE WHEN failed_login_attempts + 1 >= $2
+				THEN 0 ELSE failed_login_attempts + 1 END
+		WHERE id = $1`, userID, l.maxAttempts, l.duration.Seconds())
+	return err
+}
+
+// recordSuccess clears the failure count after a good login. Users with a
+// clean record aren't written to.
+func (l loginLockout) recordSuccess(ctx context.Context, userID int) error {
+	if l.maxAttempts <= 0 {
+		return nil
+	}
+	_, err := dbPool.Exec(ctx, `
+		UPDATE up_users SET failed_login_attempts = 0, locked_until = NULL
+		WHERE id = $1 AND (failed_login_attempts > 0 OR locked_until IS NOT NULL)`, userID)
+	return err
+}
+
+// respondLocked writes the 429 for a locked account, with Retry-After set to
+// when the lock lifts.
+func respondLocked(c *gin.Context, until time.Time) {
+	retryAfter := int(math.Ceil(time.Until(until).Seconds()))
+	c.Header("Retry-After", strconv.Itoa(max(retryAfter, 1)))
+	RespondError(c, http.StatusTooManyRequests, ErrCodeAccountLocked,
+		"Too many failed login attempts; try again later")
+}
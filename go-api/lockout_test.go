@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestLoginLockout(t *testing.T) {
+	ctx := testDB(t)
+	user := testUser(t, ctx, testOrg(t, ctx), RoleStudent, "goodpass123")
+	lockout := loginLockout{maxAttempts: 3, duration: time.Hour}
+	login := func(password string) (int, string) {
+		w := postJSON(t, Login(testJWTSecret, time.Minute, time.Hour, lockout),
+			gin.H{"identifier": user.Username, "password": password}, nil)
+		if w.Code == http.StatusOK {
+			return w.Code, ""
+		}
+		return w.Code, errorCode(t, w)
+	}
+
+	for i := 1; i <= lockout.maxAttempts; i++ {
+		if status, code := login("wrongpass123"); status != http.StatusUnauthorized || code != ErrCodeInvalidCredentials {
+			t.Fatalf("wrong password %d: %d %s, want 401 %s", i, status, code, ErrCodeInvalidCredentials)
+		}
+	}
+	// The Nth failure locked the account, so even the right password is
+	// turned away.
+	if status, code := login("goodpass123"); status != http.StatusTooManyRequests || code != ErrCodeAccountLocked {
+		t.Fatalf("after %d failures: %d %s, want 429 %s", lockout.maxAttempts, status, code, ErrCodeAccountLocked)
+	}
+
+	if _, err := dbPool.Exec(ctx,
+		"UPDATE up_users SET locked_until = now() - interval '1 second' WHERE id = $1", user.ID); err != nil {
+		t.Fatalf("expire lock: %v", err)
+	}
+	if status, code := login("goodpass123"); status != http.StatusOK {
+		t.Fatalf("after the lock expired: %d %s, want 200", status, code)
+	}
+
+	var failures int
+	if err := dbPool.QueryRow(ctx, "SELECT failed_login_attempts FROM up_users WHERE id = $1", user.ID).Scan(&failures); err != nil {
+		t.Fatalf("read failure count: %v", err)
+	}
+	if failures != 0 {
+		t.Errorf("failed_login_attempts = %d after a good login, want 0", failures)
+	}
+}
+
+func TestLoginLockoutNeedsConsecutiveFailures(t *testing.T) {
+	ctx := testDB(t)
+	user := testUser(t, ctx, testOrg(t, ctx), RoleStudent, "goodpass123")
+	lockout := loginLockout{maxAttempts: 2, duration: time.Hour}
+	login := func(password string) int {
+		return postJSON(t, Login(testJWTSecret, time.Minute, time.Hour, lockout),
+			gin.H{"identifier": user.Username, "password": password}, nil).Code
+	}
+
+	// A good login between failures resets the count.
+	for _, attempt := range []struct {
+		password string
+		want     int
+	}{
+		{"wrongpass123", http.StatusUnauthorized},
+		{"goodpass123", http.StatusOK},
+		{"wrongpass123", http.StatusUnauthorized},
+		{"goodpass123", http.StatusOK},
+	} {
+		if status := login(attempt.password); status != attempt.want {
+			t.Fatalf("login with %q: %d, want %d", attempt.password, status, attempt.want)
+		}
+	}
+}
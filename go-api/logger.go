@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// logger is shared by every part of the API; main replaces it with the
+// configured JSON logger before anything else runs.
+var logger = slog.Default()
+
+func initLogger(levelName string) {
+	level := slog.LevelInfo
+	switch strings.ToLower(levelName) {
+	case "debug":
+		level = slog.LevelDebug
+	case "warn", "warning":
+		level = slog.LevelWarn
+	case "error":
+		level = slog.LevelError
+	}
+
+	logger = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: level}))
+	slog.SetDefault(logger)
+}
+
+// clientCancelHandler demotes the warnings and errors logged because a
+// client hung up, which cancels its request context and with it any query in
+// flight, to debug records marked client_cancelled. Handlers log a failed
+// query the same way whatever the cause; this keeps abandoned requests out
+// of the error logs without each of them checking.
+type clientCancelHandler struct {
+	slog.Handler
+	request context.Context
+}
+
+func (h clientCancelHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level < slog.LevelWarn || !errors.Is(h.request.Err(), context.Canceled) || !recordsCancellation(r) {
+		return h.Handler.Handle(ctx, r)
+	}
+	if !h.Handler.Enabled(ctx, slog.LevelDebug) {
+		return nil
+	}
+	demoted := slog.NewRecord(r.Time, slog.LevelDebug, r.Message, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		demoted.AddAttrs(a)
+		return true
+	})
+	demoted.AddAttrs(slog.Bool("client_cancelled", true))
+	return h.Handler.Handle(ctx, demoted)
+}
+
+// Enabled passes warnings and errors through regardless of level, since
+// Handle may demote them.
+func (h clientCancelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= slog.LevelWarn || h.Handler.Enabled(ctx, level)
+}
+
+func (h clientCancelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return clientCancelHandler{h.Handler.WithAttrs(attrs), h.request}
+}
+
+func (h clientCancelHandler) WithGroup(name string) slog.Handler {
+	return clientCancelHandler{h.Handler.WithGroup(name), h.request}
+}
+
+// recordsCancellation reports whether r carries an error caused by context
+// cancellation.
+func recordsCancellation(r slog.Record) bool {
+	found := false
+	r.Attrs(func(a slog.Attr) bool {
+		if err, ok := a.Value.Any().(error); ok && errors.Is(err, context.Canceled) {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// fatal logs at error level and exits, replacing log.Fatalf.
+func fatal(msg string, args ...any) {
+	logger.Error(msg, args...)
+	os.Exit(1)
+}
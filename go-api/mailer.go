@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+const (
+	defaultSMTPPort  = 587
+	defaultEmailFrom = "Quick Quiz <no-reply@localhost>"
+	emailSendTimeout = 30 * time.Second
+)
+
+// emailMessage is a plain-text email to a single recipient.
+type emailMessage struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// emailSender delivers email. Handlers only see this interface, so tests and
+// local runs can swap the SMTP sender for a fake or the no-op one.
+type emailSender interface {
+	Send(ctx context.Context, msg emailMessage) error
+}
+
+// NewEmailSender returns an SMTP sender when SMTP_HOST is set, and otherwise
+// one that only logs, so development needs no mail server.
+func NewEmailSender(cfg *Config) emailSender {
+	if cfg.SMTPHost == "" {
+		return noopSender{}
+	}
+
+	s := &smtpSender{
+		host: cfg.SMTPHost,
+		addr: net.JoinHostPort(cfg.SMTPHost, strconv.Itoa(cfg.SMTPPort)),
+		from: cfg.SMTPFrom,
+	}
+	if cfg.SMTPUser != "" {
+		// PlainAuth refuses to send credentials unless the connection is TLS
+		// or to localhost.
+		s.auth = smtp.PlainAuth("", cfg.SMTPUser, cfg.SMTPPass, cfg.SMTPHost)
+	}
+	return s
+}
+
+// noopSender logs messages instead of sending them. The body is logged at
+// debug level so links can be followed in development.
+type noopSender struct{}
+
+func (noopSender) Send(ctx context.Context, msg emailMessage) error {
+	logger.Info("Email not sent, SMTP is not configured", "to", msg.To, "subject", msg.Subject)
+	logger.Debug("Unsent email body", "to", msg.To, "body", msg.Body)
+	return nil
+}
+
+// smtpSender delivers through an SMTP relay, upgrading to TLS with STARTTLS
+// whenever the server offers it.
+type smtpSender struct {
+	host string
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+func (s *smtpSender) Send(ctx context.Context, msg emailMessage) error {
+	from, err := mail.ParseAddress(s.from)
+	if err != nil {
+		return fmt.Errorf("invalid sender address: %w", err)
+	}
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("invalid recipient address: %w", err)
+	}
+
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	// net/smtp has no context support; the deadline bounds the whole exchange.
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: s.host}); err != nil {
+			return err
+		}
+	}
+	if s.auth != nil {
+		if err := client.Auth(s.auth); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(from.Address); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to.Address); err != nil {
+		return err
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(formatEmail(from, to, msg)); err != nil {
+		w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// Ping opens a session with the relay and quits without sending, checking
+// it is reachable and still greets us.
+func (s *smtpSender) Ping(ctx context.Context) error {
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+	if err := client.Hello("localhost"); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// formatEmail renders msg as an RFC 5322 message with a quoted-printable
+// UTF-8 body.
+func formatEmail(from, to *mail.Address, msg emailMessage) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from.String())
+	fmt.Fprintf(&buf, "To: %s\r\n", to.String())
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	qp := quotedprintable.NewWriter(&buf)
+	qp.Write([]byte(strings.ReplaceAll(msg.Body, "\n", "\r\n")))
+	qp.Close()
+	return buf.Bytes()
+}
+
+// accountMailer sends the account emails (verification, password reset).
+// publicURL is the API's externally reachable address; resetURL is the page
+// that collects a new password.
+type accountMailer struct {
+	sender          emailSender
+	publicURL       string
+	verificationTTL time.Duration
+	resetURL        string
+	resetTTL        time.Duration
+}
+
+// send renders tmpl with the user's name, link and expiry, and sends it.
+func (m *accountMailer) send(ctx context.Context, user User, subject string, tmpl *template.Template, link string, expiresAt time.Time) error {
+	var body bytes.Buffer
+	err := tmpl.Execute(&body, map[string]any{
+		"Username":  user.Username,
+		"Link":      link,
+		"ExpiresAt": expiresAt.UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	return m.sender.Send(ctx, emailMessage{To: user.Email, Subject: subject, Body: body.String()})
+}
+
+// mailJobs tracks emails still being sent so shutdown can let them finish.
+var mailJobs sync.WaitGroup
+
+// sendInBackground runs job on its own goroutine, detached from the request
+// that triggered it, and logs rather than returns its error.
+func sendInBackground(log *slog.Logger, job func(ctx context.Context) error) {
+	mailJobs.Add(1)
+	go func() {
+		defer mailJobs.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), emailSendTimeout)
+		defer cancel()
+		if err := job(ctx); err != nil {
+			log.Error("Failed to send email", "error", err)
+		}
+	}()
+}
+
+// WaitForMail blocks until background sends are done or ctx expires.
+func WaitForMail(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		mailJobs.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		logger.Warn("Shut down with emails still sending")
+	}
+}
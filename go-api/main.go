@@ -1,37 +1,390 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	swaggerFiles "github.com/swaggo/files"
+	ginSwagger "github.com/swaggo/gin-swagger"
+	"google.golang.org/grpc"
+
+	_ "go-api/docs"
 )
 
+const shutdownTimeout = 10 * time.Second
+
+// Per-route request budgets; routes without one use DB_QUERY_TIMEOUT per query.
+const (
+	readRouteTimeout   = 3 * time.Second
+	bulkRouteTimeout   = 2 * time.Minute
+	exportRouteTimeout = 10 * time.Minute
+)
+
+//go:generate go run github.com/swaggo/swag/cmd/swag init -g main.go -o docs
+
+// @title                       Quick Quiz Go API
+// @version                     1.0
+// @description                 Go backend for the Quick Quiz exam platform, sharing the Strapi PostgreSQL database.
+// @BasePath                    /
+// @securityDefinitions.apikey  BearerAuth
+// @in                          header
+// @name                        Authorization
+// @description                 Type "Bearer" followed by a space and the JWT.
+// @securityDefinitions.apikey  ApiKeyAuth
+// @in                          header
+// @name                        X-API-Key
+// @description                 An API key minted with POST /v1/api-keys, for machine clients. Routes that accept a bearer token accept this instead.
 func main() {
 	// Attempt to load .env file if it exists (useful for local run outside docker)
 	_ = godotenv.Load("../.env")
 
+	cfg, err := LoadConfig()
+	if err != nil {
+		fatal("Invalid configuration", "error", err)
+	}
+
+	initLogger(cfg.LogLevel)
+	shutdownTracing, err := InitTracing(cfg)
+	if err != nil {
+		fatal("Unable to set up tracing", "error", err)
+	}
+	bcryptCost = cfg.BcryptCost
+	registerValidators()
+	if err := loadAnswerSchemas(); err != nil {
+		fatal("Invalid answer schemas", "error", err)
+	}
+	if err := loadMessageBundles(cfg.DefaultLanguage); err != nil {
+		fatal("Invalid message bundles", "error", err)
+	}
+
 	// Connect to Database
-	ConnectDB()
-	defer CloseDB()
+	if err := ConnectDB(cfg); err != nil {
+		fatal("Unable to connect to database", "error", err)
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		err := runMigrateCommand(os.Args[2:])
+		CloseDB()
+		if err != nil {
+			fatal("Migration failed", "error", err)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "seed" {
+		err := runSeedCommand(cfg, os.Args[2:])
+		CloseDB()
+		if err != nil {
+			fatal("Seeding failed", "error", err)
+		}
+		return
+	}
+
+	if err := InitCache(cfg); err != nil {
+		fatal("Invalid Redis configuration", "error", err)
+	}
+
+	if cfg.RunMigrations {
+		if err := MigrateUp(context.Background(), dbPool); err != nil {
+			fatal("Migration failed", "error", err)
+		}
+	}
+
+	healthCtx, stopHealth := context.WithCancel(context.Background())
+	StartDBHealthChecker(healthCtx, cfg.DBHealthInterval)
+	StartFeatureFlagRefresher(healthCtx, cfg.FeatureFlagRefresh)
+	webhookCtx, stopWebhooks := context.WithCancel(context.Background())
+	waitWebhooks := StartWebhookDispatcher(webhookCtx)
+
+	r, err := newRouter(cfg)
+	if err != nil {
+		fatal("Unable to set up router", "error", err)
+	}
+
+	// Start server
+	srv := &http.Server{
+		Addr:    ":" + cfg.Port,
+		Handler: r,
+	}
+
+	go func() {
+		var err error
+		if cfg.TLSEnabled() {
+			logger.Info("Starting Go API", "port", cfg.Port, "tls", true)
+			err = srv.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile)
+		} else {
+			logger.Info("Starting Go API", "port", cfg.Port)
+			err = srv.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fatal("Failed to start server", "error", err)
+		}
+	}()
+
+	var grpcSrv *grpc.Server
+	if cfg.GRPCPort != "" {
+		grpcSrv = NewGRPCServer([]byte(cfg.JWTSecret))
+		go ServeGRPC(grpcSrv, cfg.GRPCPort)
+	}
+
+	var redirectSrv *http.Server
+	if cfg.HTTPRedirectPort != "" {
+		redirectSrv = &http.Server{
+			Addr:              ":" + cfg.HTTPRedirectPort,
+			Handler:           httpsRedirect(cfg.Port),
+			ReadHeaderTimeout: 5 * time.Second,
+		}
+		go func() {
+			logger.Info("Redirecting HTTP to HTTPS", "port", cfg.HTTPRedirectPort)
+			if err := redirectSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				fatal("Failed to start HTTP redirect server", "error", err)
+			}
+		}()
+	}
+
+	// Wait for an interrupt or SIGTERM, then drain in-flight requests
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	<-quit
+	logger.Info("Shutting down server")
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if redirectSrv != nil {
+		redirectSrv.Shutdown(ctx)
+	}
+	// Both servers drain at once, within the same deadline.
+	grpcStopped := make(chan struct{})
+	go func() {
+		defer close(grpcStopped)
+		if grpcSrv != nil {
+			StopGRPC(ctx, grpcSrv)
+		}
+	}()
+	if err := srv.Shutdown(ctx); err != nil {
+		logger.Error("Server forced to shut down", "error", err)
+	}
+	<-grpcStopped
 
+	// Only release the pool once no handler or queued email can still be using it
+	WaitForMail(ctx)
+	stopWebhooks()
+	waitWebhooks()
+	stopHealth()
+	CloseDB()
+	CloseCache()
+	if err := shutdownTracing(ctx); err != nil {
+		logger.Warn("Failed to flush traces", "error", err)
+	}
+	logger.Info("Server exited")
+}
+
+// httpsRedirect sends every request to the same host and path over HTTPS on
+// httpsPort, permanently so clients learn to skip the hop.
+func httpsRedirect(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := strings.Trim(r.Host, "[]")
+		if h, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = h
+		}
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
+
+// newRouter builds the Gin engine with its middleware stack and routes.
+func newRouter(cfg *Config) (*gin.Engine, error) {
 	// Initialize Gin router
-	r := gin.Default()
+	r := gin.New()
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("invalid TRUSTED_PROXIES: %w", err)
+	}
+	r.Use(Tracing())
+	r.Use(RequestID())
+	r.Use(AccessLog(cfg.AccessLogSkipPaths))
+	r.Use(Recovery())
+	r.Use(Metrics())
+	if cfg.GzipEnabled {
+		r.Use(Gzip(cfg.GzipMinSize))
+	}
+	r.Use(CORS(cfg.CORSAllowedOrigins))
+	r.Use(Maintenance(cfg.MaintenanceBypassToken, retryAfterSeconds(cfg.MaintenanceRetryAfter)))
+	r.Use(BodyLimit(int64(cfg.MaxRequestBytes)))
+	if cfg.RateLimitRPS > 0 || cfg.RateLimitUserRPS > 0 {
+		r.Use(RateLimit(cfg.RateLimitRPS, cfg.RateLimitBurst, cfg.RateLimitUserRPS, cfg.RateLimitUserBurst, []byte(cfg.JWTSecret)))
+	}
+
+	readOnly.Store(cfg.ReadOnlyMode)
+	maintenance.Store(cfg.MaintenanceMode)
 
 	// Define routes
-	r.GET("/users", GetUsers)
-	r.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{"status": "ok"})
-	})
+	store, err := NewBlobStore(cfg)
+	if err != nil {
+		return nil, err
+	}
 
-	// Start server
-	port := os.Getenv("API_PORT")
-	if port == "" {
-		port = "8080"
+	r.GET("/health", HealthCheck)
+	r.GET("/health/detailed", DetailedHealthCheck(dependencyChecks(cfg, store)))
+	r.GET("/livez", Livez)
+	r.GET("/readyz", Readyz)
+	r.GET("/metrics", MetricsHandler())
+	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	// Local attachments are served by the API itself; keys are random UUIDs,
+	// the same unguessable-link model as a public S3 bucket.
+	if cfg.AttachmentStorage == storageBackendLocal && strings.HasPrefix(cfg.AttachmentBaseURL, "/") {
+		r.Static(cfg.AttachmentBaseURL, cfg.AttachmentDir)
 	}
-	log.Printf("Starting Go API on port %s", port)
-	if err := r.Run(":" + port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+
+	// Each API version is its own group, so a /v2 can be mounted next to /v1
+	// and the two retired independently.
+	v1 := r.Group("/v1")
+	registerV1Routes(v1, cfg, store)
+
+	// GraphQL fields are answered by a second copy of the v1 routes, without
+	// the per-client middleware above: the GraphQL request itself has already
+	// been logged, counted and rate limited.
+	rest := gin.New()
+	if err := rest.SetTrustedProxies(nil); err != nil {
+		return nil, err
+	}
+	rest.Use(RequestID(), Recovery())
+	registerV1Routes(rest.Group("/v1"), cfg, store)
+	schema, err := newGraphQLSchema()
+	if err != nil {
+		return nil, err
+	}
+	v1.POST("/graphql", AuthRequired([]byte(cfg.JWTSecret)), RequireOrg(), RequireFeature(flagGraphQL), GraphQL(schema, rest))
+
+	return r, nil
+}
+
+// registerV1Routes mounts the version 1 API on api.
+func registerV1Routes(api *gin.RouterGroup, cfg *Config, store blobStore) {
+	mailer := &accountMailer{
+		sender:          NewEmailSender(cfg),
+		publicURL:       cfg.PublicURL,
+		verificationTTL: cfg.EmailVerificationTTL,
+		resetURL:        cfg.PasswordResetURL,
+		resetTTL:        cfg.PasswordResetTTL,
+	}
+	// The mode switches sit in front of the read-only guard so read-only mode
+	// can be turned back off.
+	admin := api.Group("/admin", AuthRequired([]byte(cfg.JWTSecret)), RequireOrg(), RequireRole(RoleAdmin), RejectAPIKeys())
+	admin.GET("/read-only", GetReadOnlyMode)
+	admin.PUT("/read-only", SetReadOnlyMode)
+	admin.GET("/maintenance", GetMaintenanceMode)
+	admin.PUT("/maintenance", SetMaintenanceMode)
+
+	flags := admin.Group("/feature-flags", ReadOnlyGuard())
+	flags.GET("", GetFeatureFlags)
+	flags.POST("", CreateFeatureFlag)
+	flags.GET("/:key", GetFeatureFlag)
+	flags.PUT("/:key", UpdateFeatureFlag)
+	flags.DELETE("/:key", DeleteFeatureFlag)
+
+	api = api.Group("", ReadOnlyGuard())
+	api.POST("/auth/register", Register(mailer))
+	api.POST("/auth/forgot-password", ForgotPassword(mailer))
+	api.POST("/auth/reset-password", ResetPassword)
+	api.GET("/auth/verify", Mutation(), VerifyEmail)
+	lockout := loginLockout{maxAttempts: cfg.LoginMaxAttempts, duration: cfg.LoginLockoutDuration}
+	api.POST("/auth/login", Login([]byte(cfg.JWTSecret), cfg.JWTExpiresIn, cfg.RefreshTokenTTL, lockout))
+	api.POST("/auth/refresh", RefreshToken([]byte(cfg.JWTSecret), cfg.JWTExpiresIn, cfg.RefreshTokenTTL))
+
+	protected := api.Group("", AuthRequired([]byte(cfg.JWTSecret)), RequireOrg())
+	protected.POST("/auth/logout", RejectAPIKeys(), Logout)
+	protected.POST("/auth/resend-verification", ResendVerification(mailer))
+
+	// Every route a scoped caller can reach names the scopes that open it.
+	usersRead, usersWrite := RequireScope(ScopeUsersRead), RequireScope(ScopeUsersWrite)
+	examsRead, examsWrite := RequireScope(ScopeExamsRead), RequireScope(ScopeExamsWrite)
+	submissionsRead := RequireScope(ScopeSubmissionsRead, ScopeExamsGrade)
+	submissionsWrite := RequireScope(ScopeSubmissionsWrite)
+
+	read := Timeout(readRouteTimeout)
+	bulk := Timeout(bulkRouteTimeout)
+	conditional := ETag()
+
+	protected.GET("/me", read, GetMe)
+	protected.POST("/me/password", RejectAPIKeys(), ChangePassword)
+
+	protected.GET("/users", RequireRole(RoleTeacher, RoleAdmin), usersRead, conditional, GetUsers)
+	protected.GET("/users/export", RequireRole(RoleAdmin), usersRead, Timeout(exportRouteTimeout), ExportUsers)
+	protected.GET("/users/stream", RequireRole(RoleAdmin), usersRead, Timeout(exportRouteTimeout), StreamUsers)
+	protected.POST("/users/import", RequireRole(RoleAdmin), usersWrite, bulk, BodyLimit(int64(cfg.UserImportMaxBytes)),
+		ImportUsers(int64(cfg.UserImportMaxBytes)))
+	// GetUserByID lets non-admins through for their own account only.
+	protected.GET("/users/:id", usersRead, read, conditional, GetUserByID)
+	protected.POST("/users", RequireRole(RoleAdmin), usersWrite, CreateUser)
+	protected.POST("/users/batch", RequireRole(RoleAdmin), usersWrite, bulk, CreateUsersBatch(cfg.UserBatchMaxSize))
+	protected.POST("/users/bulk-delete", RequireRole(RoleAdmin), usersWrite, bulk, BulkDeleteUsers(cfg.UserBatchMaxSize))
+	// UpdateUser lets non-admins through for their own account only.
+	protected.PATCH("/users/:id", usersWrite, UpdateUser)
+	protected.DELETE("/users/:id", RequireRole(RoleAdmin), usersWrite, DeleteUser)
+	protected.POST("/users/:id/restore", RequireRole(RoleAdmin), usersWrite, RestoreUser)
+
+	protected.GET("/exams", examsRead, read, conditional, GetExams)
+	protected.GET("/exams/:id", examsRead, read, conditional, GetExamByID)
+	protected.POST("/exams", RequireRole(RoleTeacher, RoleAdmin), examsWrite, CreateExam)
+	protected.PATCH("/exams/:id", RequireRole(RoleTeacher, RoleAdmin), examsWrite, UpdateExam)
+	protected.DELETE("/exams/:id", RequireRole(RoleTeacher, RoleAdmin), examsWrite, DeleteExam)
+	protected.GET("/questions", RequireRole(RoleTeacher, RoleAdmin), examsRead, read, GetQuestionBank)
+	protected.GET("/exams/:id/questions", examsRead, read, GetExamQuestions)
+	protected.POST("/exams/:id/questions", RequireRole(RoleTeacher, RoleAdmin), examsWrite, CreateQuestion)
+	protected.PATCH("/exams/:id/questions/:questionId", RequireRole(RoleTeacher, RoleAdmin), examsWrite, UpdateQuestion)
+	protected.DELETE("/exams/:id/questions/:questionId", RequireRole(RoleTeacher, RoleAdmin), examsWrite, DeleteQuestion)
+	protected.GET("/exams/:id/questions/:questionId/options", examsRead, read, GetQuestionOptions)
+	protected.PUT("/exams/:id/questions/:questionId/options", RequireRole(RoleTeacher, RoleAdmin), examsWrite, ReplaceQuestionOptions)
+	protected.POST("/exams/:id/attachments", RequireRole(RoleTeacher, RoleAdmin), examsWrite, bulk,
+		BodyLimit(int64(cfg.AttachmentMaxBytes)+multipartOverhead), UploadAttachment(store, int64(cfg.AttachmentMaxBytes), cfg.AttachmentAllowedTypes))
+
+	verified := RequireVerified()
+	protected.POST("/exams/:id/submissions", submissionsWrite, verified, CreateSubmission(cfg.IdempotencyKeyTTL))
+	protected.GET("/exams/:id/results", RequireRole(RoleTeacher, RoleAdmin), submissionsRead, GetExamResults)
+	protected.GET("/exams/:id/analytics", RequireRole(RoleTeacher, RoleAdmin), submissionsRead, GetExamAnalytics)
+	protected.GET("/exams/:id/leaderboard", examsRead, read, GetExamLeaderboard)
+	// Scores are computed and stored on first read.
+	protected.GET("/submissions/:id/score", submissionsRead, Mutation(), GetSubmissionScore)
+	protected.PATCH("/submissions/batch-grade", RequireRole(RoleTeacher, RoleAdmin), RequireScope(ScopeExamsGrade), bulk, BatchGrade)
+	protected.POST("/submissions/:id/events", submissionsWrite, ProctoringRateLimit(), RecordProctoringEvents)
+	protected.GET("/submissions/:id/events", RequireRole(RoleTeacher, RoleAdmin), submissionsRead, read, GetProctoringEvents)
+	protected.GET("/submissions/:id/pdf", submissionsRead, Timeout(exportRouteTimeout), GetSubmissionPDF)
+	// No Timeout: the connection lives for the length of the exam.
+	protected.GET("/exams/:id/live", submissionsWrite, Mutation(), verified, LiveExam(cfg.CORSAllowedOrigins))
+
+	protected.GET("/audit", RequireRole(RoleAdmin), RequireScope(ScopeAuditRead), GetAuditLogs)
+
+	org := protected.Group("/organization", RequireRole(RoleAdmin), RejectAPIKeys())
+	org.GET("/registration", GetOrgRegistration)
+	org.PUT("/registration", SetOrgRegistration)
+	org.POST("/join-code", RotateJoinCode)
+	org.DELETE("/join-code", RemoveJoinCode)
+
+	keys := protected.Group("/api-keys", RequireRole(RoleAdmin), RejectAPIKeys())
+	keys.GET("", GetAPIKeys)
+	keys.POST("", CreateAPIKey)
+	keys.DELETE("/:id", RevokeAPIKey)
+
+	webhooksRead, webhooksWrite := RequireScope(ScopeWebhooksRead), RequireScope(ScopeWebhooksWrite)
+	protected.GET("/webhooks", RequireRole(RoleAdmin), webhooksRead, GetWebhooks)
+	protected.POST("/webhooks", RequireRole(RoleAdmin), webhooksWrite, CreateWebhook)
+	protected.DELETE("/webhooks/:id", RequireRole(RoleAdmin), webhooksWrite, DeleteWebhook)
+	protected.GET("/webhooks/:id/deliveries", RequireRole(RoleAdmin), webhooksRead, GetWebhookDeliveries)
+
+	// Left unregistered unless enabled, so they 404 by default.
+	if cfg.DebugEndpoints {
+		protected.GET("/debug/pool", RequireRole(RoleAdmin), RejectAPIKeys(), DebugPool)
 	}
 }
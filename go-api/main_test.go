@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Tests that need PostgreSQL run against TEST_DATABASE_URL and are skipped
+// without it. The database must be disposable: it is migrated to the latest
+// version, and tests leave their rows behind, each under its own organization.
+const testDatabaseURLVar = "TEST_DATABASE_URL"
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	registerValidators()
+	bcryptCost = bcrypt.MinCost
+	code := m.Run()
+	CloseDB()
+	os.Exit(code)
+}
+
+var (
+	testDBOnce sync.Once
+	testDBErr  error
+)
+
+// strapiUsersTable is the part of Strapi's up_users the API relies on. Strapi
+// creates the table in a real deployment; migrations only alter it.
+const strapiUsersTable = `
+	CREATE TABLE IF NOT EXISTS up_users (
+		id                   serial PRIMARY KEY,
+		username             varchar(255),
+		email                varchar(255),
+		provider             varchar(255),
+		password             varchar(255),
+		reset_password_token varchar(255),
+		confirmation_token   varchar(255),
+		confirmed            boolean,
+		blocked              boolean,
+		created_at           timestamp(6),
+		updated_at           timestamp(6)
+	)`
+
+// testDB connects dbPool to the test database and migrates it, once per run,
+// or skips the test when there is none. It returns a context for the test's
+// queries.
+func testDB(t testing.TB) context.Context {
+	t.Helper()
+	url := os.Getenv(testDatabaseURLVar)
+	if url == "" {
+		t.Skip(testDatabaseURLVar + " is not set")
+	}
+
+	testDBOnce.Do(func() {
+		testDBErr = ConnectDB(&Config{
+			DatabaseURL:          url,
+			DBQueryTimeout:       10 * time.Second,
+			DBMaxConns:           4,
+			DBMaxConnLifetime:    time.Hour,
+			DBMaxConnIdleTime:    30 * time.Minute,
+			DBQueryExecMode:      "cache_statement",
+			DBStatementCacheSize: defaultStatementCacheSize,
+			DBConnectRetries:     1,
+		})
+		if testDBErr != nil {
+			return
+		}
+		ctx := context.Background()
+		if _, testDBErr = dbPool.Exec(ctx, strapiUsersTable); testDBErr != nil {
+			return
+		}
+		testDBErr = MigrateUp(ctx, dbPool)
+	})
+	if testDBErr != nil {
+		t.Fatalf("test database: %v", testDBErr)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+// uniqueName is prefix with a random suffix, for rows that must not collide
+// with those of other tests or earlier runs.
+func uniqueName(prefix string) string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return prefix + hex.EncodeToString(b)
+}
+
+// testOrg creates an organization for one test.
+func testOrg(t testing.TB, ctx context.Context) int {
+	t.Helper()
+	slug := uniqueName("test-")
+	var id int
+	if err := dbPool.QueryRow(ctx,
+		"INSERT INTO organizations (name, slug) VALUES ($1, $1) RETURNING id", slug).Scan(&id); err != nil {
+		t.Fatalf("create organization: %v", err)
+	}
+	return id
+}
+
+// testUser creates a verified user with role in org. An empty password
+// leaves the user without one.
+func testUser(t testing.TB, ctx context.Context, org int, role, password string) User {
+	t.Helper()
+	var hash *string
+	if password != "" {
+		h, err := hashPassword(password)
+		if err != nil {
+			t.Fatalf("hash password: %v", err)
+		}
+		hash = &h
+	}
+	name := uniqueName("user")
+	user, err := insertUser(ctx, org, name, name+"@example.com", hash, true)
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if _, err := dbPool.Exec(ctx, "UPDATE up_users SET role = $1 WHERE id = $2", role, user.ID); err != nil {
+		t.Fatalf("set role: %v", err)
+	}
+	user.Role = role
+	return user
+}
+
+// postJSON sends body to handler as a JSON POST and returns the response.
+// With claims set the request is authenticated as them.
+func postJSON(t testing.TB, handler gin.HandlerFunc, body any, claims *Claims) *httptest.ResponseRecorder {
+	t.Helper()
+	return serveRoute(t, http.MethodPost, "/", "/", handler, body, claims)
+}
+
+// serveRoute sends a request for target to handler mounted at route, so it
+// sees route's path params, and returns the response. A non-nil body is sent
+// as JSON; with claims set the request is authenticated as them.
+func serveRoute(t testing.TB, method, route, target string, handler gin.HandlerFunc, body any, claims *Claims) *httptest.ResponseRecorder {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("marshal body: %v", err)
+		}
+		reader = bytes.NewReader(raw)
+	}
+	r := gin.New()
+	r.Handle(method, route, func(c *gin.Context) {
+		if claims != nil {
+			c.Set(claimsKey, claims)
+		}
+		c.Next()
+	}, handler)
+	req := httptest.NewRequest(method, target, reader)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// errorCode returns the error code in w's body, or "" if it has none.
+func errorCode(t testing.TB, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	var body ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("body %s: %v", w.Body, err)
+	}
+	return body.Error.Code
+}
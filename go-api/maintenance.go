@@ -0,0 +1,102 @@
+package main
+
+import (
+	"crypto/subtle"
+	"math"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maintenanceBypassHeader      = "X-Maintenance-Bypass"
+	defaultMaintenanceRetryAfter = 300 // seconds
+)
+
+// maintenance is maintenance mode: everything but the health and metrics
+// endpoints gets a 503. It starts from MAINTENANCE_MODE and is flipped by PUT
+// /v1/admin/maintenance, which only reaches the instance that serves it.
+var maintenance atomic.Bool
+
+// maintenanceExempt keeps probes and scrapes working, so an instance in
+// maintenance isn't restarted or reported as gone.
+var maintenanceExempt = map[string]bool{
+	"/health":          true,
+	"/health/detailed": true,
+	"/livez":           true,
+	"/readyz":          true,
+	"/metrics":         true,
+}
+
+// Maintenance answers every request with a 503 and Retry-After while
+// maintenance mode is on, unless it carries bypassToken in the
+// X-Maintenance-Bypass header. An empty bypassToken lets nobody through.
+func Maintenance(bypassToken string, retryAfterSeconds int) gin.HandlerFunc {
+	retryAfter := strconv.Itoa(retryAfterSeconds)
+	return func(c *gin.Context) {
+		if !maintenance.Load() || maintenanceExempt[c.Request.URL.Path] {
+			c.Next()
+			return
+		}
+		if got := c.GetHeader(maintenanceBypassHeader); bypassToken != "" &&
+			subtle.ConstantTimeCompare([]byte(got), []byte(bypassToken)) == 1 {
+			c.Next()
+			return
+		}
+
+		c.Header("Retry-After", retryAfter)
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeMaintenance,
+			"Quick Quiz is down for planned maintenance and will be back shortly")
+	}
+}
+
+// retryAfterSeconds rounds a MAINTENANCE_RETRY_AFTER duration up to whole
+// seconds, as Retry-After wants.
+func retryAfterSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// GetMaintenanceMode godoc
+// @Summary      Get maintenance mode
+// @Description  Admin only.
+// @Tags         admin
+// @Produce      json
+// @Success      200  {object}  MaintenanceMode
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/admin/maintenance [get]
+func GetMaintenanceMode(c *gin.Context) {
+	c.JSON(http.StatusOK, MaintenanceMode{Enabled: maintenance.Load()})
+}
+
+// SetMaintenanceMode godoc
+// @Summary      Turn maintenance mode on or off
+// @Description  While on, every route but the health and metrics endpoints returns 503 MAINTENANCE with a Retry-After, unless the request carries the MAINTENANCE_BYPASS_TOKEN in X-Maintenance-Bypass; so turning it back off needs that header too. Only the instance that serves this request changes; MAINTENANCE_MODE sets the mode at startup. Admin only.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        mode  body      SetMaintenanceModeRequest  true  "Whether maintenance mode is on"
+// @Success      200   {object}  MaintenanceMode
+// @Failure      400   {object}  ErrorResponse
+// @Failure      401   {object}  ErrorResponse
+// @Failure      403   {object}  ErrorResponse
+// @Failure      503   {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/admin/maintenance [put]
+func SetMaintenanceMode(c *gin.Context) {
+	var req SetMaintenanceModeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindingError(c, err)
+		return
+	}
+
+	if maintenance.Swap(*req.Enabled) != *req.Enabled {
+		requestLogger(c).Warn("Maintenance mode changed", "enabled", *req.Enabled, "actor_id", *actorID(c))
+	}
+	c.JSON(http.StatusOK, MaintenanceMode{Enabled: *req.Enabled})
+}
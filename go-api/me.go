@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// GetMe godoc
+// @Summary      Get the current user
+// @Description  Returns the profile of the user the bearer token was issued to, with last_login_at for admins.
+// @Tags         me
+// @Produce      json
+// @Success      200  {object}  User
+// @Failure      401  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/me [get]
+func GetMe(c *gin.Context) {
+	if dbPool == nil {
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+		return
+	}
+
+	claims, ok := currentClaims(c)
+	if !ok {
+		RespondError(c, http.StatusUnauthorized, ErrCodeUnauthorized, "Authentication required")
+		return
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	columns, scan := userColumns, scanUser
+	if claims.Role == RoleAdmin {
+		columns, scan = adminUserColumns, scanAdminUser
+	}
+	var user User
+	err := scan(dbPool.QueryRow(ctx,
+		"SELECT "+columns+" FROM up_users WHERE id = $1 AND org_id = $2 AND deleted_at IS NULL",
+		claims.UserID, claims.OrgID), &user)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// The token outlived the account.
+		RespondError(c, http.StatusNotFound, ErrCodeNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		requestLogger(c).Error("Failed to query user", "user_id", claims.UserID, "error", err)
+		respondQueryError(c, err, "Failed to fetch user")
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// ChangePassword godoc
+// @Summary      Change the current user's password
+// @Description  Replaces the password after checking the current one. Every refresh token the user holds is revoked, so other sessions have to log in again once their access tokens expire.
+// @Tags         me
+// @Accept       json
+// @Produce      json
+// @Param        request  body      ChangePasswordRequest  true  "Current and new password"
+// @Success      200      {object}  MessageResponse
+// @Failure      400      {object}  ErrorResponse
+// @Failure      401      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse
+// @Failure      500      {object}  ErrorResponse
+// @Failure      503      {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/me/password [post]
+func ChangePassword(c *gin.Context) {
+	if dbPool == nil {
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+		return
+	}
+
+	claims, ok := currentClaims(c)
+	if !ok {
+		RespondError(c, http.StatusUnauthorized, ErrCodeUnauthorized, "Authentication required")
+		return
+	}
+
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindingError(c, err)
+		return
+	}
+	if req.NewPassword == req.CurrentPassword {
+		RespondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "New password must differ from the current one")
+		return
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	var currentHash *string
+	err := dbPool.QueryRow(ctx,
+		"SELECT password FROM up_users WHERE id = $1 AND deleted_at IS NULL", claims.UserID).Scan(&currentHash)
+	if errors.Is(err, pgx.ErrNoRows) {
+		RespondError(c, http.StatusNotFound, ErrCodeNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		requestLogger(c).Error("Failed to query user", "user_id", claims.UserID, "error", err)
+		respondQueryError(c, err, "Failed to change password")
+		return
+	}
+	if currentHash == nil || !verifyPassword(*currentHash, req.CurrentPassword) {
+		RespondError(c, http.StatusBadRequest, ErrCodeInvalidCredentials, "Current password is incorrect")
+		return
+	}
+
+	newHash, err := hashPassword(req.NewPassword)
+	if err != nil {
+		requestLogger(c).Error("Failed to hash password", "error", err)
+		RespondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to change password")
+		return
+	}
+
+	err = WithTx(ctx, func(tx pgx.Tx) error {
+		// Matching on the old hash means a concurrent change wins and this
+		// one fails, instead of one silently overwriting the other.
+		tag, err := tx.Exec(ctx,
+			"UPDATE up_users SET password = $1, updated_at = now() WHERE id = $2 AND password = $3",
+			newHash, claims.UserID, *currentHash)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return newClientError(http.StatusBadRequest, ErrCodeInvalidCredentials, "Current password is incorrect")
+		}
+		_, err = tx.Exec(ctx, "DELETE FROM refresh_tokens WHERE user_id = $1", claims.UserID)
+		return err
+	})
+	if respondClientError(c, err) {
+		return
+	}
+	if err != nil {
+		requestLogger(c).Error("Failed to change password", "user_id", claims.UserID, "error", err)
+		respondQueryError(c, err, "Failed to change password")
+		return
+	}
+
+	requestLogger(c).Info("Password changed", "user_id", claims.UserID)
+	recordAudit(c, &claims.UserID, auditPasswordChange, auditTarget{"user", claims.UserID}, gin.H{"ip": c.ClientIP()})
+	c.JSON(http.StatusOK, MessageResponse{Message: "Password updated"})
+}
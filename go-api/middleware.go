@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	requestIDHeader    = "X-Request-ID"
+	requestIDKey       = "request_id"
+	requestLoggerKey   = "logger"
+	routeTimeoutKey    = "route_timeout"
+	rawBodyKey         = "raw_body"
+	maxRequestIDLength = 128
+
+	defaultMaxRequestBytes = 1 << 20
+
+	corsAllowMethods  = "GET, POST, PUT, PATCH, DELETE, OPTIONS"
+	corsAllowHeaders  = "Authorization, Content-Type, If-None-Match, " + apiKeyHeader + ", " + requestIDHeader + ", " + idempotencyKeyHeader
+	corsExposeHeaders = requestIDHeader + ", ETag, Link, " + rateLimitRemainingHeader + ", " + idempotencyReplayedHeader
+	corsMaxAge        = "600"
+)
+
+// RequestID tags each request with a correlation ID, reusing the caller's
+// X-Request-ID when it looks sane, and attaches a logger carrying that ID,
+// which logs failures caused by the client disconnecting at debug level.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+
+		c.Set(requestIDKey, id)
+		handler := clientCancelHandler{logger.Handler(), c.Request.Context()}
+		c.Set(requestLoggerKey, slog.New(handler).With("request_id", id))
+		c.Header(requestIDHeader, id)
+		c.Next()
+	}
+}
+
+// AccessLog writes one structured line per request once it completes: info
+// normally, warn for 5xx. Requests to skipPaths (health probes, scrapes) are
+// not logged at all.
+func AccessLog(skipPaths []string) gin.HandlerFunc {
+	skip := make(map[string]bool, len(skipPaths))
+	for _, p := range skipPaths {
+		skip[p] = true
+	}
+
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		if skip[path] {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelWarn
+		}
+		requestLogger(c).LogAttrs(c.Request.Context(), level, "Request completed",
+			slog.String("method", c.Request.Method),
+			slog.String("path", path),
+			slog.Int("status", status),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("client_ip", c.ClientIP()),
+			slog.Int("bytes", max(c.Writer.Size(), 0)),
+		)
+	}
+}
+
+// Timeout bounds the whole request to d. The deadline is set on the request
+// context, and queryContext adopts d in place of the global query timeout, so
+// database calls are canceled when it passes. If the handler comes back
+// late without having responded, the client gets a 504.
+func Timeout(d time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), d)
+		defer cancel()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Set(routeTimeoutKey, d)
+		c.Next()
+
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) && !c.Writer.Written() {
+			RespondError(c, http.StatusGatewayTimeout, ErrCodeTimeout, "Request timed out")
+		}
+	}
+}
+
+// BodyLimit caps the request body at n bytes; reads past it fail with
+// *http.MaxBytesError, which the binding helpers turn into a 413. It is
+// applied to every request, and again on upload routes to raise the cap: each
+// application wraps the original body, so the last one wins. That is also why
+// an oversized Content-Length isn't refused up front.
+func BodyLimit(n int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body := c.Request.Body
+		if raw, ok := c.Get(rawBodyKey); ok {
+			body = raw.(io.ReadCloser)
+		} else {
+			c.Set(rawBodyKey, body)
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, body, n)
+		c.Next()
+	}
+}
+
+func respondTooLarge(c *gin.Context, n int64) {
+	RespondError(c, http.StatusRequestEntityTooLarge, ErrCodePayloadTooLarge,
+		"Request body must be at most "+strconv.FormatInt(n, 10)+" bytes")
+}
+
+// validRequestID rejects empty, oversized or non-printable IDs so a client
+// can't smuggle arbitrary bytes into our logs.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		if r < 0x21 || r > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// requestLogger returns the logger for the current request, falling back to
+// the package logger outside the RequestID middleware.
+func requestLogger(c *gin.Context) *slog.Logger {
+	if l, ok := c.Get(requestLoggerKey); ok {
+		if l, ok := l.(*slog.Logger); ok {
+			return l
+		}
+	}
+	return logger
+}
+
+// Recovery turns a panic into a logged stack trace and a JSON 500. The panic
+// value is only echoed to the client outside release mode.
+func Recovery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+
+			requestLogger(c).Error("Panic recovered",
+				"panic", fmt.Sprint(rec),
+				"method", c.Request.Method,
+				"path", c.Request.URL.Path,
+				"stack", string(debug.Stack()))
+
+			if c.Writer.Written() {
+				c.Abort()
+				return
+			}
+			var details any
+			if gin.Mode() != gin.ReleaseMode {
+				details = gin.H{"panic": fmt.Sprint(rec)}
+			}
+			RespondErrorDetails(c, http.StatusInternalServerError, ErrCodeInternal, "Internal server error", details)
+		}()
+		c.Next()
+	}
+}
+
+// CORS emits CORS headers for the configured origins and answers preflight
+// requests. A "*" entry allows any origin but never with credentials; a
+// matching explicit origin is echoed back with credentials enabled.
+func CORS(allowedOrigins []string) gin.HandlerFunc {
+	allowAll := false
+	allowed := make(map[string]bool, len(allowedOrigins))
+	for _, o := range allowedOrigins {
+		if o == "*" {
+			allowAll = true
+			continue
+		}
+		allowed[o] = true
+	}
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+
+		c.Writer.Header().Add("Vary", "Origin")
+		switch {
+		case allowed[origin]:
+			c.Header("Access-Control-Allow-Origin", origin)
+			c.Header("Access-Control-Allow-Credentials", "true")
+		case allowAll:
+			c.Header("Access-Control-Allow-Origin", "*")
+		default:
+			if c.Request.Method == http.MethodOptions {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Next()
+			return
+		}
+		c.Header("Access-Control-Expose-Headers", corsExposeHeaders)
+
+		if c.Request.Method == http.MethodOptions {
+			c.Header("Access-Control-Allow-Methods", corsAllowMethods)
+			c.Header("Access-Control-Allow-Headers", corsAllowHeaders)
+			c.Header("Access-Control-Max-Age", corsMaxAge)
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		c.Next()
+	}
+}
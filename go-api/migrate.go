@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockID is the pg_advisory_lock key held while migrating so that
+// several replicas starting at once don't race each other.
+const migrationLockID = 727_458_112
+
+type migration struct {
+	version int
+	name    string
+	up      string
+	down    string
+}
+
+// loadMigrations reads the embedded NNNN_name.up.sql / NNNN_name.down.sql
+// pairs, sorted by version.
+func loadMigrations() ([]migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*migration{}
+	for _, e := range entries {
+		file := e.Name()
+		base, direction, ok := strings.Cut(strings.TrimSuffix(file, ".sql"), ".")
+		if !ok || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("migration %s: expected NNNN_name.up.sql or NNNN_name.down.sql", file)
+		}
+		num, name, _ := strings.Cut(base, "_")
+		version, err := strconv.Atoi(num)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: invalid version: %w", file, err)
+		}
+
+		body, err := migrationFiles.ReadFile(path.Join("migrations", file))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &migration{version: version, name: name}
+			byVersion[version] = m
+		}
+		if direction == "up" {
+			m.up = string(body)
+		} else {
+			m.down = string(body)
+		}
+	}
+
+	migrations := make([]migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" {
+			return nil, fmt.Errorf("migration %04d_%s has no up file", m.version, m.name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+	return migrations, nil
+}
+
+// MigrateUp applies every pending migration, each in its own transaction.
+func MigrateUp(ctx context.Context, pool *pgxpool.Pool) error {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+
+	return withMigrationLock(ctx, pool, func(conn *pgxpool.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range migrations {
+			if applied[m.version] {
+				continue
+			}
+			logger.Info("Applying migration", "version", m.version, "name", m.name)
+			err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+				if _, err := tx.Exec(ctx, m.up); err != nil {
+					return err
+				}
+				_, err := tx.Exec(ctx, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", m.version, m.name)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("migration %04d_%s: %w", m.version, m.name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// MigrateDown reverts the most recently applied steps migrations.
+func MigrateDown(ctx context.Context, pool *pgxpool.Pool, steps int) error {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+
+	return withMigrationLock(ctx, pool, func(conn *pgxpool.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+			m := migrations[i]
+			if !applied[m.version] {
+				continue
+			}
+			if m.down == "" {
+				return fmt.Errorf("migration %04d_%s has no down file", m.version, m.name)
+			}
+			logger.Info("Reverting migration", "version", m.version, "name", m.name)
+			err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+				if _, err := tx.Exec(ctx, m.down); err != nil {
+					return err
+				}
+				_, err := tx.Exec(ctx, "DELETE FROM schema_migrations WHERE version = $1", m.version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("revert %04d_%s: %w", m.version, m.name, err)
+			}
+			steps--
+		}
+		return nil
+	})
+}
+
+func withMigrationLock(ctx context.Context, pool *pgxpool.Pool, fn func(*pgxpool.Conn) error) error {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", migrationLockID); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockID)
+
+	_, err = conn.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    bigint PRIMARY KEY,
+		name       text NOT NULL,
+		applied_at timestamptz NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	return fn(conn)
+}
+
+func appliedVersions(ctx context.Context, conn *pgxpool.Conn) (map[int]bool, error) {
+	rows, err := conn.Query(ctx, "SELECT version FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	versions, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int]bool, len(versions))
+	for _, v := range versions {
+		applied[v] = true
+	}
+	return applied, nil
+}
+
+// runMigrateCommand implements `go run . migrate up` and
+// `go run . migrate down [steps]`.
+func runMigrateCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: migrate up | migrate down [steps]")
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		return MigrateUp(ctx, dbPool)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("steps must be a positive integer")
+			}
+			steps = n
+		}
+		return MigrateDown(ctx, dbPool, steps)
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+}
@@ -0,0 +1,2 @@
+ALTER TABLE up_users DROP CONSTRAINT IF EXISTS up_users_role_check;
+ALTER TABLE up_users DROP COLUMN IF EXISTS role;
@@ -0,0 +1,4 @@
+ALTER TABLE up_users ADD COLUMN IF NOT EXISTS role varchar(32) NOT NULL DEFAULT 'student';
+
+ALTER TABLE up_users DROP CONSTRAINT IF EXISTS up_users_role_check;
+ALTER TABLE up_users ADD CONSTRAINT up_users_role_check CHECK (role IN ('student', 'teacher', 'admin'));
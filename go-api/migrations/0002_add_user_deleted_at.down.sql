@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS up_users_active_idx;
+ALTER TABLE up_users DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,3 @@
+ALTER TABLE up_users ADD COLUMN IF NOT EXISTS deleted_at timestamptz;
+
+CREATE INDEX IF NOT EXISTS up_users_active_idx ON up_users (id) WHERE deleted_at IS NULL;
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS exams (
+    id               serial PRIMARY KEY,
+    title            varchar(200) NOT NULL,
+    description      text NOT NULL DEFAULT '',
+    duration_minutes integer NOT NULL CHECK (duration_minutes > 0),
+    created_by       integer REFERENCES up_users (id) ON DELETE SET NULL,
+    created_at       timestamptz NOT NULL DEFAULT now(),
+    updated_at       timestamptz NOT NULL DEFAULT now()
+);
@@ -0,0 +1,13 @@
+-- Named exam_questions because Strapi already owns a "questions" table.
+CREATE TABLE IF NOT EXISTS exam_questions (
+    id         serial PRIMARY KEY,
+    exam_id    integer NOT NULL REFERENCES exams (id) ON DELETE CASCADE,
+    prompt     text NOT NULL,
+    type       varchar(32) NOT NULL CHECK (type IN ('multiple_choice', 'true_false', 'short_answer')),
+    points     integer NOT NULL DEFAULT 1 CHECK (points >= 0),
+    position   integer NOT NULL DEFAULT 0,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    updated_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS exam_questions_exam_position_idx ON exam_questions (exam_id, position);
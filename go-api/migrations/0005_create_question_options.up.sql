@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS question_options (
+    id          serial PRIMARY KEY,
+    question_id integer NOT NULL REFERENCES exam_questions (id) ON DELETE CASCADE,
+    text        text NOT NULL,
+    is_correct  boolean NOT NULL DEFAULT false,
+    position    integer NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS question_options_question_idx ON question_options (question_id, position);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS submission_answers;
+DROP TABLE IF EXISTS submissions;
+ALTER TABLE exams DROP COLUMN IF EXISTS allow_retakes;
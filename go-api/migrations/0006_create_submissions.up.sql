@@ -0,0 +1,18 @@
+ALTER TABLE exams ADD COLUMN IF NOT EXISTS allow_retakes boolean NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS submissions (
+    id           serial PRIMARY KEY,
+    exam_id      integer NOT NULL REFERENCES exams (id) ON DELETE CASCADE,
+    user_id      integer NOT NULL REFERENCES up_users (id) ON DELETE CASCADE,
+    submitted_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS submissions_exam_user_idx ON submissions (exam_id, user_id);
+
+CREATE TABLE IF NOT EXISTS submission_answers (
+    id            serial PRIMARY KEY,
+    submission_id integer NOT NULL REFERENCES submissions (id) ON DELETE CASCADE,
+    question_id   integer NOT NULL REFERENCES exam_questions (id) ON DELETE CASCADE,
+    answer        jsonb NOT NULL,
+    UNIQUE (submission_id, question_id)
+);
@@ -0,0 +1,6 @@
+ALTER TABLE submission_answers DROP COLUMN IF EXISTS points_awarded;
+
+ALTER TABLE submissions
+    DROP COLUMN IF EXISTS scored_at,
+    DROP COLUMN IF EXISTS max_score,
+    DROP COLUMN IF EXISTS score;
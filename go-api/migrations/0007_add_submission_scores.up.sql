@@ -0,0 +1,7 @@
+ALTER TABLE submissions
+    ADD COLUMN IF NOT EXISTS score     integer,
+    ADD COLUMN IF NOT EXISTS max_score integer,
+    ADD COLUMN IF NOT EXISTS scored_at timestamptz;
+
+-- NULL means not yet graded, which is how short answers stay pending.
+ALTER TABLE submission_answers ADD COLUMN IF NOT EXISTS points_awarded integer;
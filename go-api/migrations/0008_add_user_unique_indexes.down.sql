@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS up_users_username_key;
+DROP INDEX IF EXISTS up_users_email_key;
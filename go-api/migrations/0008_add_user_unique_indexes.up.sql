@@ -0,0 +1,4 @@
+-- Strapi only checks uniqueness in its own layer; enforce it in the database
+-- with stable names the API can map to EMAIL_TAKEN / USERNAME_TAKEN.
+CREATE UNIQUE INDEX IF NOT EXISTS up_users_email_key ON up_users (email);
+CREATE UNIQUE INDEX IF NOT EXISTS up_users_username_key ON up_users (username);
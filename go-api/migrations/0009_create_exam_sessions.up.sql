@@ -0,0 +1,13 @@
+-- An exam session is a timed attempt: the server's record of when the clock
+-- started, so the countdown survives reconnects and can't be reset client-side.
+CREATE TABLE IF NOT EXISTS exam_sessions (
+    id            serial PRIMARY KEY,
+    exam_id       integer NOT NULL REFERENCES exams (id) ON DELETE CASCADE,
+    user_id       integer NOT NULL REFERENCES up_users (id) ON DELETE CASCADE,
+    started_at    timestamptz NOT NULL DEFAULT now(),
+    draft_answers jsonb NOT NULL DEFAULT '[]',
+    submission_id integer REFERENCES submissions (id) ON DELETE SET NULL,
+    closed_at     timestamptz
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS exam_sessions_open_idx ON exam_sessions (exam_id, user_id) WHERE closed_at IS NULL;
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS attachments (
+    id           serial PRIMARY KEY,
+    exam_id      integer NOT NULL REFERENCES exams (id) ON DELETE CASCADE,
+    question_id  integer REFERENCES exam_questions (id) ON DELETE SET NULL,
+    filename     varchar(255) NOT NULL,
+    content_type varchar(100) NOT NULL,
+    size_bytes   bigint NOT NULL,
+    storage_key  text NOT NULL UNIQUE,
+    url          text NOT NULL,
+    uploaded_by  integer REFERENCES up_users (id) ON DELETE SET NULL,
+    created_at   timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS attachments_exam_idx ON attachments (exam_id);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS password_resets (
+    id         serial PRIMARY KEY,
+    user_id    integer NOT NULL REFERENCES up_users (id) ON DELETE CASCADE,
+    token_hash char(64) NOT NULL UNIQUE,
+    expires_at timestamptz NOT NULL,
+    used_at    timestamptz,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS password_resets_user_idx ON password_resets (user_id);
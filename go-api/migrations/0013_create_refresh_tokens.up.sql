@@ -0,0 +1,17 @@
+-- Refresh tokens from one login share a family_id. Rotation marks a token
+-- rotated_at and links its successor; seeing a rotated token again means it
+-- was copied, and the whole family is revoked.
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id          serial PRIMARY KEY,
+    user_id     integer NOT NULL REFERENCES up_users (id) ON DELETE CASCADE,
+    family_id   uuid NOT NULL,
+    token_hash  char(64) NOT NULL UNIQUE,
+    expires_at  timestamptz NOT NULL,
+    rotated_at  timestamptz,
+    replaced_by integer REFERENCES refresh_tokens (id) ON DELETE SET NULL,
+    revoked_at  timestamptz,
+    created_at  timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_family_idx ON refresh_tokens (family_id);
+CREATE INDEX IF NOT EXISTS refresh_tokens_user_idx ON refresh_tokens (user_id);
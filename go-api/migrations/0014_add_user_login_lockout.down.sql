@@ -0,0 +1,2 @@
+ALTER TABLE up_users DROP COLUMN IF EXISTS locked_until;
+ALTER TABLE up_users DROP COLUMN IF EXISTS failed_login_attempts;
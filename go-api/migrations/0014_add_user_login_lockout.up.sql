@@ -0,0 +1,2 @@
+ALTER TABLE up_users ADD COLUMN IF NOT EXISTS failed_login_attempts integer NOT NULL DEFAULT 0;
+ALTER TABLE up_users ADD COLUMN IF NOT EXISTS locked_until timestamptz;
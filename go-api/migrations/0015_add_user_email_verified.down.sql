@@ -0,0 +1 @@
+ALTER TABLE up_users DROP COLUMN IF EXISTS email_verified;
@@ -0,0 +1,3 @@
+-- Defaults to true so existing accounts, and ones created by admins or by
+-- Strapi, keep working; self-registration inserts false explicitly.
+ALTER TABLE up_users ADD COLUMN IF NOT EXISTS email_verified boolean NOT NULL DEFAULT true;
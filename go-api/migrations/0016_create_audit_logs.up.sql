@@ -0,0 +1,15 @@
+-- Append-only record of sensitive actions. Actors and targets are plain ids
+-- without foreign keys so entries outlive what they refer to.
+CREATE TABLE IF NOT EXISTS audit_logs (
+    id            bigserial PRIMARY KEY,
+    actor_user_id integer,
+    action        varchar(64) NOT NULL,
+    target_type   varchar(32),
+    target_id     integer,
+    metadata      jsonb,
+    created_at    timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS audit_logs_created_idx ON audit_logs (created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS audit_logs_actor_idx ON audit_logs (actor_user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS audit_logs_action_idx ON audit_logs (action, created_at DESC);
@@ -0,0 +1 @@
+ALTER TABLE exams DROP COLUMN IF EXISTS max_attempts;
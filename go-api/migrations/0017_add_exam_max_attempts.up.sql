@@ -0,0 +1,3 @@
+-- NULL or 0 means no limit. Only applies when allow_retakes is true; an exam
+-- without retakes always allows exactly one attempt.
+ALTER TABLE exams ADD COLUMN IF NOT EXISTS max_attempts integer CHECK (max_attempts >= 0);
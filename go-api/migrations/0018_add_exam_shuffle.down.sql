@@ -0,0 +1,3 @@
+ALTER TABLE exams
+    DROP COLUMN IF EXISTS shuffle_options,
+    DROP COLUMN IF EXISTS shuffle_questions;
@@ -0,0 +1,3 @@
+ALTER TABLE exams
+    ADD COLUMN IF NOT EXISTS shuffle_questions boolean NOT NULL DEFAULT false,
+    ADD COLUMN IF NOT EXISTS shuffle_options boolean NOT NULL DEFAULT false;
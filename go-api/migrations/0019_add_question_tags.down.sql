@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS exam_questions_tags_idx;
+ALTER TABLE exam_questions DROP COLUMN IF EXISTS tags;
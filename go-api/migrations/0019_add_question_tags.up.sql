@@ -0,0 +1,4 @@
+ALTER TABLE exam_questions ADD COLUMN IF NOT EXISTS tags text[] NOT NULL DEFAULT '{}';
+
+-- Serves the tags @> filter on both the per-exam and the bank-wide listing.
+CREATE INDEX IF NOT EXISTS exam_questions_tags_idx ON exam_questions USING gin (tags);
@@ -0,0 +1,9 @@
+ALTER TABLE submissions ALTER COLUMN score TYPE integer USING round(score);
+ALTER TABLE submission_answers ALTER COLUMN points_awarded TYPE integer USING round(points_awarded);
+
+ALTER TABLE exam_questions DROP COLUMN IF EXISTS scoring_strategy;
+
+DELETE FROM exam_questions WHERE type = 'multi_select';
+ALTER TABLE exam_questions DROP CONSTRAINT IF EXISTS exam_questions_type_check;
+ALTER TABLE exam_questions ADD CONSTRAINT exam_questions_type_check
+    CHECK (type IN ('multiple_choice', 'true_false', 'short_answer'));
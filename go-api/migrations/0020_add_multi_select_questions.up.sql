@@ -0,0 +1,11 @@
+ALTER TABLE exam_questions DROP CONSTRAINT IF EXISTS exam_questions_type_check;
+ALTER TABLE exam_questions ADD CONSTRAINT exam_questions_type_check
+    CHECK (type IN ('multiple_choice', 'true_false', 'short_answer', 'multi_select'));
+
+-- Only multi_select questions have anything between right and wrong to score.
+ALTER TABLE exam_questions ADD COLUMN IF NOT EXISTS scoring_strategy varchar(32) NOT NULL DEFAULT 'all_or_nothing'
+    CHECK (scoring_strategy IN ('all_or_nothing', 'partial'));
+
+-- Partial credit makes fractional points possible.
+ALTER TABLE submission_answers ALTER COLUMN points_awarded TYPE numeric(10, 2);
+ALTER TABLE submissions ALTER COLUMN score TYPE numeric(10, 2);
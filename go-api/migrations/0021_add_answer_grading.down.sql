@@ -0,0 +1,4 @@
+ALTER TABLE submission_answers
+    DROP COLUMN IF EXISTS graded_at,
+    DROP COLUMN IF EXISTS graded_by,
+    DROP COLUMN IF EXISTS feedback;
@@ -0,0 +1,4 @@
+ALTER TABLE submission_answers
+    ADD COLUMN IF NOT EXISTS feedback  text,
+    ADD COLUMN IF NOT EXISTS graded_by integer REFERENCES up_users (id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS graded_at timestamptz;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS proctoring_events (
+    id            bigserial PRIMARY KEY,
+    submission_id integer NOT NULL REFERENCES submissions (id) ON DELETE CASCADE,
+    event_type    varchar(32) NOT NULL
+        CHECK (event_type IN ('tab_switch', 'focus_lost', 'paste', 'face_not_detected')),
+    occurred_at   timestamptz NOT NULL,
+    details       jsonb,
+    received_at   timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS proctoring_events_submission_idx ON proctoring_events (submission_id, occurred_at, id);
@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS webhooks (
+    id         serial PRIMARY KEY,
+    url        text NOT NULL,
+    -- Kept in the clear: it's the HMAC key, so it has to be usable as is.
+    secret     text NOT NULL,
+    events     text[] NOT NULL,
+    created_by integer REFERENCES up_users (id) ON DELETE SET NULL,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+-- An outbox: rows are written in the same transaction as the event, then
+-- picked up and sent by the dispatcher.
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id               bigserial PRIMARY KEY,
+    webhook_id       integer NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+    event            text NOT NULL,
+    payload          jsonb NOT NULL,
+    status           varchar(16) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'failed')),
+    attempts         integer NOT NULL DEFAULT 0,
+    next_attempt_at  timestamptz NOT NULL DEFAULT now(),
+    last_status_code integer,
+    last_error       text,
+    delivered_at     timestamptz,
+    created_at       timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook_idx ON webhook_deliveries (webhook_id, id);
@@ -0,0 +1,15 @@
+-- The primary key is what makes two concurrent requests with one key
+-- serialize: the second insert waits for the first transaction and then
+-- finds its row.
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    user_id       integer NOT NULL REFERENCES up_users (id) ON DELETE CASCADE,
+    key           varchar(255) NOT NULL,
+    exam_id       integer NOT NULL,
+    fingerprint   char(64) NOT NULL,
+    submission_id integer REFERENCES submissions (id) ON DELETE CASCADE,
+    created_at    timestamptz NOT NULL DEFAULT now(),
+    expires_at    timestamptz NOT NULL,
+    PRIMARY KEY (user_id, key)
+);
+
+CREATE INDEX IF NOT EXISTS idempotency_keys_expires_idx ON idempotency_keys (user_id, expires_at);
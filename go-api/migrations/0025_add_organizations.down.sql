@@ -0,0 +1,14 @@
+ALTER TABLE submissions DROP CONSTRAINT IF EXISTS submissions_user_org_fkey;
+ALTER TABLE submissions DROP CONSTRAINT IF EXISTS submissions_exam_org_fkey;
+ALTER TABLE exam_questions DROP CONSTRAINT IF EXISTS exam_questions_exam_org_fkey;
+ALTER TABLE up_users DROP CONSTRAINT IF EXISTS up_users_id_org_key;
+ALTER TABLE exams DROP CONSTRAINT IF EXISTS exams_id_org_key;
+
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS org_id;
+ALTER TABLE webhooks DROP COLUMN IF EXISTS org_id;
+ALTER TABLE submissions DROP COLUMN IF EXISTS org_id;
+ALTER TABLE exam_questions DROP COLUMN IF EXISTS org_id;
+ALTER TABLE exams DROP COLUMN IF EXISTS org_id;
+ALTER TABLE up_users DROP COLUMN IF EXISTS org_id;
+
+DROP TABLE IF EXISTS organizations;
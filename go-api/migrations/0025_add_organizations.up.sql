@@ -0,0 +1,47 @@
+CREATE TABLE IF NOT EXISTS organizations (
+    id         serial PRIMARY KEY,
+    name       varchar(255) NOT NULL,
+    slug       varchar(100) NOT NULL UNIQUE,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+-- Everything that predates tenancy belongs to this organization.
+INSERT INTO organizations (id, name, slug) VALUES (1, 'Default', 'default') ON CONFLICT DO NOTHING;
+SELECT setval(pg_get_serial_sequence('organizations', 'id'), (SELECT MAX(id) FROM organizations));
+
+-- Strapi inserts users without knowing about organizations, so up_users keeps
+-- its default. The API's own tables are always written with an explicit org.
+ALTER TABLE up_users ADD COLUMN IF NOT EXISTS org_id integer NOT NULL DEFAULT 1 REFERENCES organizations (id);
+
+ALTER TABLE exams ADD COLUMN IF NOT EXISTS org_id integer NOT NULL DEFAULT 1 REFERENCES organizations (id);
+ALTER TABLE exams ALTER COLUMN org_id DROP DEFAULT;
+
+ALTER TABLE exam_questions ADD COLUMN IF NOT EXISTS org_id integer NOT NULL DEFAULT 1;
+ALTER TABLE exam_questions ALTER COLUMN org_id DROP DEFAULT;
+
+ALTER TABLE submissions ADD COLUMN IF NOT EXISTS org_id integer NOT NULL DEFAULT 1;
+ALTER TABLE submissions ALTER COLUMN org_id DROP DEFAULT;
+
+ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS org_id integer NOT NULL DEFAULT 1 REFERENCES organizations (id);
+ALTER TABLE webhooks ALTER COLUMN org_id DROP DEFAULT;
+
+-- Like the actor and target, a plain id, so entries outlive the org.
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS org_id integer;
+UPDATE audit_logs a SET org_id = u.org_id FROM up_users u WHERE u.id = a.actor_user_id;
+
+-- Composite keys make a row that points across organizations impossible to
+-- store: a question belongs to its exam's org, a submission to both its
+-- exam's and its user's.
+ALTER TABLE exams ADD CONSTRAINT exams_id_org_key UNIQUE (id, org_id);
+ALTER TABLE up_users ADD CONSTRAINT up_users_id_org_key UNIQUE (id, org_id);
+ALTER TABLE exam_questions ADD CONSTRAINT exam_questions_exam_org_fkey
+    FOREIGN KEY (exam_id, org_id) REFERENCES exams (id, org_id) ON DELETE CASCADE;
+ALTER TABLE submissions ADD CONSTRAINT submissions_exam_org_fkey
+    FOREIGN KEY (exam_id, org_id) REFERENCES exams (id, org_id) ON DELETE CASCADE;
+ALTER TABLE submissions ADD CONSTRAINT submissions_user_org_fkey
+    FOREIGN KEY (user_id, org_id) REFERENCES up_users (id, org_id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS up_users_org_idx ON up_users (org_id);
+CREATE INDEX IF NOT EXISTS exams_org_idx ON exams (org_id);
+CREATE INDEX IF NOT EXISTS exam_questions_org_idx ON exam_questions (org_id);
+CREATE INDEX IF NOT EXISTS audit_logs_org_idx ON audit_logs (org_id, created_at);
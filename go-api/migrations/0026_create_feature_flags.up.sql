@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS feature_flags (
+    key         varchar(100) PRIMARY KEY,
+    description text NOT NULL DEFAULT '',
+    -- On for everyone; otherwise only for the listed users and organizations.
+    enabled     boolean NOT NULL DEFAULT false,
+    user_ids    integer[] NOT NULL DEFAULT '{}',
+    org_ids     integer[] NOT NULL DEFAULT '{}',
+    updated_by  integer REFERENCES up_users (id) ON DELETE SET NULL,
+    created_at  timestamptz NOT NULL DEFAULT now(),
+    updated_at  timestamptz NOT NULL DEFAULT now()
+);
@@ -0,0 +1,18 @@
+-- Keys for machine clients. A key acts as the admin who minted it, within
+-- its scopes, and stops working if that admin is deleted or blocked. Only the
+-- SHA-256 of the key is stored; prefix is its first characters, so a key can
+-- be recognized in a list without being recoverable.
+CREATE TABLE IF NOT EXISTS api_keys (
+    id           serial PRIMARY KEY,
+    org_id       integer NOT NULL REFERENCES organizations (id),
+    label        varchar(100) NOT NULL,
+    prefix       varchar(16) NOT NULL,
+    key_hash     char(64) NOT NULL UNIQUE,
+    scopes       text[] NOT NULL,
+    created_by   integer NOT NULL REFERENCES up_users (id) ON DELETE CASCADE,
+    created_at   timestamptz NOT NULL DEFAULT now(),
+    last_used_at timestamptz,
+    revoked_at   timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS api_keys_org_idx ON api_keys (org_id, id);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS up_users_org_last_login_idx;
+ALTER TABLE up_users DROP COLUMN IF EXISTS last_login_at;
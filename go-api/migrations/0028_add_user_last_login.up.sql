@@ -0,0 +1,3 @@
+ALTER TABLE up_users ADD COLUMN IF NOT EXISTS last_login_at timestamptz;
+
+CREATE INDEX IF NOT EXISTS up_users_org_last_login_idx ON up_users (org_id, last_login_at) WHERE deleted_at IS NULL;
@@ -0,0 +1,14 @@
+-- Back to the zoneless columns Strapi creates, holding UTC.
+DO $$
+DECLARE
+    col text;
+BEGIN
+    FOR col IN
+        SELECT column_name FROM information_schema.columns
+        WHERE table_schema = current_schema() AND table_name = 'up_users'
+          AND column_name IN ('created_at', 'updated_at', 'published_at')
+          AND data_type = 'timestamp with time zone'
+    LOOP
+        EXECUTE format('ALTER TABLE up_users ALTER COLUMN %I TYPE timestamp(6) USING %I AT TIME ZONE ''UTC''', col, col);
+    END LOOP;
+END $$;
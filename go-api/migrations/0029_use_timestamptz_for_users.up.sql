@@ -0,0 +1,15 @@
+-- Strapi creates up_users with timestamp columns that carry no zone, so the
+-- same instant read back differently depending on the session's TimeZone.
+-- Every writer so far has run in UTC, so existing values are read as UTC.
+DO $$
+DECLARE
+    col text;
+BEGIN
+    FOR col IN
+        SELECT column_name FROM information_schema.columns
+        WHERE table_schema = current_schema() AND table_name = 'up_users'
+          AND data_type = 'timestamp without time zone'
+    LOOP
+        EXECUTE format('ALTER TABLE up_users ALTER COLUMN %I TYPE timestamptz USING %I AT TIME ZONE ''UTC''', col, col);
+    END LOOP;
+END $$;
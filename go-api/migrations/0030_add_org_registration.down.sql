@@ -0,0 +1,2 @@
+ALTER TABLE organizations DROP COLUMN IF EXISTS join_code_hash;
+ALTER TABLE organizations DROP COLUMN IF EXISTS open_registration;
@@ -0,0 +1,9 @@
+-- Self-registration used to admit anyone who knew an organization's slug.
+-- Now an organization takes sign-ups only while open, or from someone holding
+-- its current join code, stored as a SHA-256 like other opaque tokens.
+ALTER TABLE organizations ADD COLUMN IF NOT EXISTS open_registration boolean NOT NULL DEFAULT false;
+ALTER TABLE organizations ADD COLUMN IF NOT EXISTS join_code_hash char(64);
+
+-- Registration without a slug has always joined the default organization, so
+-- it stays open.
+UPDATE organizations SET open_registration = true WHERE slug = 'default';
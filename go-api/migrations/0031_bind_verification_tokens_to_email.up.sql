@@ -0,0 +1,8 @@
+-- A verification token vouches for the address it was mailed to, not for the
+-- account, so it must stop working once the account's email changes.
+-- Outstanding tokens are taken to be for the current address.
+ALTER TABLE email_verification_tokens ADD COLUMN IF NOT EXISTS email varchar(255);
+
+UPDATE email_verification_tokens t SET email = u.email
+FROM up_users u
+WHERE u.id = t.user_id AND t.email IS NULL;
@@ -1,7 +1,628 @@
 package main
 
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	RoleStudent = "student"
+	RoleTeacher = "teacher"
+	RoleAdmin   = "admin"
+)
+
 type User struct {
-	ID       int    `json:"id"`
+	ID            int        `json:"id"`
+	Username      string     `json:"username"`
+	Email         string     `json:"email"`
+	Role          string     `json:"role"`
+	EmailVerified bool       `json:"email_verified"`
+	DeletedAt     *time.Time `json:"deleted_at,omitempty"`
+	// LastLoginAt is only read for admins, and is absent for everyone else
+	// and for users who have never logged in.
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+}
+
+type CreateUserRequest struct {
+	Username string `json:"username" binding:"required,min=3,max=50"`
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"omitempty,password"`
+}
+
+// BulkDeleteUsersRequest is the body of POST /users/bulk-delete. Confirm must
+// be true; it keeps a stray request from deleting a batch of accounts.
+type BulkDeleteUsersRequest struct {
+	IDs     []int `json:"ids" binding:"required,min=1,dive,min=1"`
+	Confirm bool  `json:"confirm"`
+}
+
+// BulkDeleteUsersResult lists the users deleted and the requested ids that
+// matched no active user. On a dry run it describes what would have been
+// deleted; nothing is kept.
+type BulkDeleteUsersResult struct {
+	DryRun     bool  `json:"dry_run"`
+	Deleted    int   `json:"deleted"`
+	UserIDs    []int `json:"user_ids"`
+	MissingIDs []int `json:"missing_ids"`
+}
+
+// ImportUserRow is one data row of a POST /users/import CSV. Role defaults to
+// student when the column is absent or empty.
+type ImportUserRow struct {
+	Line     int    `json:"-"`
+	Username string `json:"username" binding:"required,min=3,max=50"`
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"omitempty,password"`
+	Role     string `json:"role" binding:"omitempty,oneof=student teacher admin"`
+}
+
+type ImportRowError struct {
+	Line   int          `json:"line"`
+	Fields []FieldError `json:"fields"`
+}
+
+// ImportUsersResult counts rows inserted and rows skipped because the
+// username or email already exists, and lists what happened to each row. On a
+// dry run it describes what would have happened; nothing is kept.
+type ImportUsersResult struct {
+	DryRun  bool              `json:"dry_run"`
+	Created int               `json:"created"`
+	Skipped int               `json:"skipped"`
+	Rows    []ImportRowResult `json:"rows"`
+}
+
+// ImportRowResult is the outcome of one CSV data row: created or skipped.
+type ImportRowResult struct {
+	Line     int    `json:"line"`
 	Username string `json:"username"`
 	Email    string `json:"email"`
+	Status   string `json:"status" enums:"created,skipped"`
+}
+
+const (
+	importRowCreated = "created"
+	importRowSkipped = "skipped"
+)
+
+type RegisterRequest struct {
+	Username string `json:"username" binding:"required,min=3,max=50"`
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required,password"`
+	// Organization is the slug of the school to join; blank joins the
+	// default one.
+	Organization string `json:"organization" binding:"max=100"`
+	// JoinCode admits the registration to an organization that isn't open.
+	JoinCode string `json:"join_code" binding:"max=100"`
+}
+
+type UpdateUserRequest struct {
+	Username *string `json:"username" binding:"omitempty,min=3,max=50"`
+	Email    *string `json:"email" binding:"omitempty,email"`
+}
+
+type PaginatedUsers struct {
+	Data   []User `json:"data"`
+	Total  int    `json:"total"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+}
+
+// CursorUsers is the keyset-paged form of GetUsers. NextCursor is nil on the
+// last page.
+type CursorUsers struct {
+	Data       []User `json:"data"`
+	NextCursor *int   `json:"next_cursor"`
+	Limit      int    `json:"limit"`
+}
+
+type LoginRequest struct {
+	Identifier string `json:"identifier" binding:"required"`
+	Password   string `json:"password" binding:"required"`
+}
+
+type LoginResponse struct {
+	Token            string    `json:"token"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type VerifyEmailResponse struct {
+	Message string `json:"message"`
+	UserID  int    `json:"user_id"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,password"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,password"`
+}
+
+// MessageResponse is returned by endpoints with nothing to report but an
+// acknowledgement.
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
+type Exam struct {
+	ID              int    `json:"id"`
+	Title           string `json:"title"`
+	Description     string `json:"description"`
+	DurationMinutes int    `json:"duration_minutes"`
+	AllowRetakes    bool   `json:"allow_retakes"`
+	// MaxAttempts caps submissions per candidate when retakes are allowed.
+	// Null or 0 means no limit.
+	MaxAttempts *int `json:"max_attempts"`
+	// ShuffleQuestions and ShuffleOptions give each candidate their own
+	// question and option order.
+	ShuffleQuestions bool      `json:"shuffle_questions"`
+	ShuffleOptions   bool      `json:"shuffle_options"`
+	CreatedBy        *int      `json:"created_by"`
+	CreatedAt        time.Time `json:"created_at"`
+	// RemainingAttempts is how many more times the caller may submit. Only
+	// set on the exam detail response, and left out when there's no limit.
+	RemainingAttempts *int `json:"remaining_attempts,omitempty"`
+}
+
+type CreateExamRequest struct {
+	Title            string `json:"title" binding:"required,max=200"`
+	Description      string `json:"description"`
+	DurationMinutes  int    `json:"duration_minutes" binding:"required,min=1"`
+	AllowRetakes     bool   `json:"allow_retakes"`
+	MaxAttempts      *int   `json:"max_attempts" binding:"omitempty,min=0"`
+	ShuffleQuestions bool   `json:"shuffle_questions"`
+	ShuffleOptions   bool   `json:"shuffle_options"`
+}
+
+type UpdateExamRequest struct {
+	Title            *string `json:"title" binding:"omitempty,min=1,max=200"`
+	Description      *string `json:"description"`
+	DurationMinutes  *int    `json:"duration_minutes" binding:"omitempty,min=1"`
+	AllowRetakes     *bool   `json:"allow_retakes"`
+	MaxAttempts      *int    `json:"max_attempts" binding:"omitempty,min=0"`
+	ShuffleQuestions *bool   `json:"shuffle_questions"`
+	ShuffleOptions   *bool   `json:"shuffle_options"`
+}
+
+const (
+	QuestionTypeMultipleChoice = "multiple_choice"
+	QuestionTypeTrueFalse      = "true_false"
+	QuestionTypeShortAnswer    = "short_answer"
+	QuestionTypeMultiSelect    = "multi_select"
+)
+
+// Scoring strategies. They only make a difference for multi_select, where
+// partial awards credit for a partly right selection.
+const (
+	ScoringAllOrNothing = "all_or_nothing"
+	ScoringPartial      = "partial"
+)
+
+type Question struct {
+	ID              int              `json:"id"`
+	ExamID          int              `json:"exam_id"`
+	Prompt          string           `json:"prompt"`
+	Type            string           `json:"type" enums:"multiple_choice,true_false,short_answer,multi_select"`
+	ScoringStrategy string           `json:"scoring_strategy" enums:"all_or_nothing,partial"`
+	Points          int              `json:"points"`
+	Position        int              `json:"position"`
+	Tags            []string         `json:"tags"`
+	Options         []QuestionOption `json:"options,omitempty"`
+}
+
+type PaginatedQuestions struct {
+	Data   []Question `json:"data"`
+	Total  int        `json:"total"`
+	Limit  int        `json:"limit"`
+	Offset int        `json:"offset"`
+}
+
+// QuestionOption is a selectable answer. IsCorrect is nil in the
+// student-facing view so the answer key never leaves the server.
+type QuestionOption struct {
+	ID         int    `json:"id"`
+	QuestionID int    `json:"question_id"`
+	Text       string `json:"text"`
+	IsCorrect  *bool  `json:"is_correct,omitempty"`
+}
+
+type OptionInput struct {
+	Text      string `json:"text" binding:"required"`
+	IsCorrect bool   `json:"is_correct"`
+}
+
+type ReplaceOptionsRequest struct {
+	Options []OptionInput `json:"options" binding:"required,min=2,dive"`
+}
+
+type CreateQuestionRequest struct {
+	Prompt          string   `json:"prompt" binding:"required"`
+	Type            string   `json:"type" binding:"required,oneof=multiple_choice true_false short_answer multi_select"`
+	ScoringStrategy string   `json:"scoring_strategy" binding:"omitempty,oneof=all_or_nothing partial"`
+	Points          *int     `json:"points" binding:"omitempty,min=0"`
+	Position        *int     `json:"position" binding:"omitempty,min=0"`
+	Tags            []string `json:"tags" binding:"max=20,dive,max=50"`
+}
+
+type UpdateQuestionRequest struct {
+	Prompt          *string `json:"prompt" binding:"omitempty,min=1"`
+	Type            *string `json:"type" binding:"omitempty,oneof=multiple_choice true_false short_answer multi_select"`
+	ScoringStrategy *string `json:"scoring_strategy" binding:"omitempty,oneof=all_or_nothing partial"`
+	Points          *int    `json:"points" binding:"omitempty,min=0"`
+	Position        *int    `json:"position" binding:"omitempty,min=0"`
+	// Tags replaces the question's tags; an empty list clears them.
+	Tags []string `json:"tags" binding:"omitempty,max=20,dive,max=50"`
+}
+
+// Submission is one attempt at an exam. Answers holds the raw JSON value the
+// candidate gave per question: an option id for multiple_choice and
+// true_false, an array of option ids for multi_select, a string for
+// short_answer.
+type Submission struct {
+	ID          int                `json:"id"`
+	ExamID      int                `json:"exam_id"`
+	UserID      int                `json:"user_id"`
+	SubmittedAt time.Time          `json:"submitted_at"`
+	Score       *float64           `json:"score"`
+	MaxScore    *int               `json:"max_score"`
+	Answers     []SubmissionAnswer `json:"answers"`
+}
+
+type SubmissionAnswer struct {
+	QuestionID int             `json:"question_id"`
+	Answer     json.RawMessage `json:"answer" swaggertype:"object"`
+}
+
+type SubmissionAnswerInput struct {
+	QuestionID int             `json:"question_id" binding:"required"`
+	Answer     json.RawMessage `json:"answer" binding:"required" swaggertype:"object"`
+}
+
+type CreateSubmissionRequest struct {
+	Answers []SubmissionAnswerInput `json:"answers" binding:"required,min=1,dive"`
+}
+
+const (
+	ScoreStatusCorrect    = "correct"
+	ScoreStatusPartial    = "partial"
+	ScoreStatusIncorrect  = "incorrect"
+	ScoreStatusPending    = "pending"
+	ScoreStatusUnanswered = "unanswered"
+)
+
+// QuestionScore is one line of a score breakdown. PointsAwarded is nil while
+// the question is pending manual grading.
+type QuestionScore struct {
+	QuestionID    int      `json:"question_id"`
+	Type          string   `json:"type"`
+	PointsAwarded *float64 `json:"points_awarded"`
+	// AnswerID identifies the response for grading; nil when unanswered.
+	AnswerID  *int    `json:"answer_id"`
+	Feedback  *string `json:"feedback,omitempty"`
+	MaxPoints int     `json:"max_points"`
+	Status    string  `json:"status" enums:"correct,partial,incorrect,pending,unanswered"`
+}
+
+// GradeInput is a grader's mark for one short-answer response.
+type GradeInput struct {
+	SubmissionAnswerID int      `json:"submission_answer_id" binding:"required"`
+	Points             *float64 `json:"points" binding:"required,min=0"`
+	Feedback           *string  `json:"feedback" binding:"omitempty,max=2000"`
+}
+
+type BatchGradeRequest struct {
+	Grades []GradeInput `json:"grades" binding:"required,min=1,max=500,dive"`
+}
+
+type BatchGradeResponse struct {
+	Submissions []SubmissionScore `json:"submissions"`
+}
+
+// SubmissionScore totals only graded questions; Pending counts the short
+// answers still waiting for a grader, so Score is a lower bound until it is 0.
+type SubmissionScore struct {
+	SubmissionID int             `json:"submission_id"`
+	Score        float64         `json:"score"`
+	MaxScore     int             `json:"max_score"`
+	Pending      int             `json:"pending"`
+	Questions    []QuestionScore `json:"questions"`
+}
+
+// ExamResult is one row of an exam's grading dashboard. Score and MaxScore
+// are nil for submissions that have not been scored yet.
+type ExamResult struct {
+	SubmissionID int       `json:"submission_id"`
+	UserID       int       `json:"user_id"`
+	Username     string    `json:"username"`
+	Score        *float64  `json:"score"`
+	MaxScore     *int      `json:"max_score"`
+	SubmittedAt  time.Time `json:"submitted_at"`
+}
+
+// ResultsSummary aggregates over every submission of the exam, not just the
+// current page. Average, Min and Max are nil until something has been scored.
+type ResultsSummary struct {
+	Count   int      `json:"count"`
+	Average *float64 `json:"average"`
+	Min     *float64 `json:"min"`
+	Max     *float64 `json:"max"`
+}
+
+type ExamResults struct {
+	Data    []ExamResult   `json:"data"`
+	Summary ResultsSummary `json:"summary"`
+	Total   int            `json:"total"`
+	Limit   int            `json:"limit"`
+	Offset  int            `json:"offset"`
+}
+
+// LeaderboardEntry is one candidate's best scored attempt. UserID is left out
+// when the username is masked.
+type LeaderboardEntry struct {
+	Rank        int       `json:"rank"`
+	UserID      *int      `json:"user_id,omitempty"`
+	Username    string    `json:"username"`
+	Score       float64   `json:"score"`
+	MaxScore    int       `json:"max_score"`
+	SubmittedAt time.Time `json:"submitted_at"`
+}
+
+type Leaderboard struct {
+	Data   []LeaderboardEntry `json:"data"`
+	Total  int                `json:"total"`
+	Limit  int                `json:"limit"`
+	Offset int                `json:"offset"`
+}
+
+// ExamAnalytics is the item analysis for an exam. The score statistics are
+// over scored submissions and are nil until there is one.
+type ExamAnalytics struct {
+	ExamID      int                 `json:"exam_id"`
+	Submissions int                 `json:"submissions"`
+	Scored      int                 `json:"scored"`
+	Mean        *float64            `json:"mean"`
+	Median      *float64            `json:"median"`
+	StdDev      *float64            `json:"stddev"`
+	Questions   []QuestionAnalytics `json:"questions"`
+}
+
+// QuestionAnalytics describes how one question performed. Attempts counts
+// answers given; PercentCorrect and MeanPoints cover the graded ones and are
+// nil when none are.
+type QuestionAnalytics struct {
+	QuestionID     int               `json:"question_id"`
+	Type           string            `json:"type"`
+	Points         int               `json:"points"`
+	Attempts       int               `json:"attempts"`
+	Graded         int               `json:"graded"`
+	PercentCorrect *float64          `json:"percent_correct"`
+	MeanPoints     *float64          `json:"mean_points"`
+	Options        []OptionAnalytics `json:"options"`
+}
+
+// OptionAnalytics is how often an option was picked. For multi_select the
+// percentages can add up to more than 100.
+type OptionAnalytics struct {
+	OptionID      int      `json:"option_id"`
+	Text          string   `json:"text"`
+	IsCorrect     bool     `json:"is_correct"`
+	Picked        int      `json:"picked"`
+	PickedPercent *float64 `json:"picked_percent"`
+}
+
+// ProctoringEventInput is one integrity signal from the exam client.
+// OccurredAt is the client's clock; Details is free-form context such as
+// how long focus was lost.
+type ProctoringEventInput struct {
+	Type       string          `json:"type" binding:"required,oneof=tab_switch focus_lost paste face_not_detected"`
+	OccurredAt time.Time       `json:"occurred_at" binding:"required"`
+	Details    json.RawMessage `json:"details,omitempty" swaggertype:"object"`
+}
+
+type RecordProctoringEventsRequest struct {
+	Events []ProctoringEventInput `json:"events" binding:"required,min=1,max=100,dive"`
+}
+
+type ProctoringEvent struct {
+	ID           int64           `json:"id"`
+	SubmissionID int             `json:"submission_id"`
+	Type         string          `json:"type"`
+	OccurredAt   time.Time       `json:"occurred_at"`
+	Details      json.RawMessage `json:"details,omitempty" swaggertype:"object"`
+	ReceivedAt   time.Time       `json:"received_at"`
+}
+
+type PaginatedProctoringEvents struct {
+	Data   []ProctoringEvent `json:"data"`
+	Total  int               `json:"total"`
+	Limit  int               `json:"limit"`
+	Offset int               `json:"offset"`
+}
+
+// Webhook is a subscription to API events. Secret is only set in the
+// response that creates it.
+type Webhook struct {
+	ID        int       `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedBy *int      `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// APIKey is a machine client's credential. Key is only set in the response
+// that mints it; Prefix is enough to recognize it afterwards.
+type APIKey struct {
+	ID         int        `json:"id"`
+	Label      string     `json:"label"`
+	Prefix     string     `json:"prefix"`
+	Key        string     `json:"key,omitempty"`
+	Scopes     []string   `json:"scopes"`
+	CreatedBy  int        `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+type CreateAPIKeyRequest struct {
+	Label  string   `json:"label" binding:"required,max=100"`
+	Scopes []string `json:"scopes" binding:"required,min=1,dive,oneof=users:read users:write exams:read exams:write exams:grade submissions:read submissions:write audit:read webhooks:read webhooks:write"`
+}
+
+type CreateWebhookRequest struct {
+	URL    string   `json:"url" binding:"required,max=2000"`
+	Events []string `json:"events" binding:"required,min=1,dive,oneof=submission.scored"`
+	Secret string   `json:"secret" binding:"omitempty,min=16,max=200"`
+}
+
+// WebhookDelivery is one queued notification. NextAttemptAt is set while it
+// is still pending.
+type WebhookDelivery struct {
+	ID             int64      `json:"id"`
+	WebhookID      int        `json:"webhook_id"`
+	Event          string     `json:"event"`
+	Status         string     `json:"status" enums:"pending,delivered,failed"`
+	Attempts       int        `json:"attempts"`
+	LastStatusCode *int       `json:"last_status_code"`
+	LastError      *string    `json:"last_error"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+type PaginatedWebhookDeliveries struct {
+	Data   []WebhookDelivery `json:"data"`
+	Total  int               `json:"total"`
+	Limit  int               `json:"limit"`
+	Offset int               `json:"offset"`
+}
+
+type Attachment struct {
+	ID          int       `json:"id"`
+	ExamID      int       `json:"exam_id"`
+	QuestionID  *int      `json:"question_id"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	URL         string    `json:"url"`
+	UploadedBy  *int      `json:"uploaded_by"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type AuditLog struct {
+	ID          int64           `json:"id"`
+	ActorUserID *int            `json:"actor_user_id"`
+	Action      string          `json:"action"`
+	TargetType  *string         `json:"target_type"`
+	TargetID    *int            `json:"target_id"`
+	Metadata    json.RawMessage `json:"metadata,omitempty" swaggertype:"object"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+type PaginatedAuditLogs struct {
+	Data   []AuditLog `json:"data"`
+	Total  int        `json:"total"`
+	Limit  int        `json:"limit"`
+	Offset int        `json:"offset"`
+}
+
+// HealthComponent is one dependency in the detailed health report. Status is
+// ok, down, or disabled when the dependency isn't configured.
+type HealthComponent struct {
+	Status    string `json:"status"`
+	LatencyMs *int64 `json:"latency_ms,omitempty"`
+	// Circuit is the database circuit breaker's state, when it is enabled.
+	Circuit string `json:"circuit,omitempty" enums:"closed,open,half_open"`
+}
+
+type DetailedHealth struct {
+	Status      string                     `json:"status"`
+	ReadOnly    bool                       `json:"read_only"`
+	Maintenance bool                       `json:"maintenance"`
+	Components  map[string]HealthComponent `json:"components"`
+}
+
+// OrgRegistration is how an organization takes self-registrations: from
+// anyone while open, otherwise only with its join code. JoinCode is only
+// returned by the request that generates it.
+type OrgRegistration struct {
+	Open        bool   `json:"open"`
+	HasJoinCode bool   `json:"has_join_code"`
+	JoinCode    string `json:"join_code,omitempty"`
+}
+
+type SetOrgRegistrationRequest struct {
+	Open *bool `json:"open" binding:"required"`
+}
+
+type ReadOnlyMode struct {
+	Enabled bool `json:"enabled"`
+}
+
+type SetReadOnlyModeRequest struct {
+	Enabled *bool `json:"enabled" binding:"required"`
+}
+
+type MaintenanceMode struct {
+	Enabled bool `json:"enabled"`
+}
+
+type SetMaintenanceModeRequest struct {
+	Enabled *bool `json:"enabled" binding:"required"`
+}
+
+// FeatureFlag is on for everyone when Enabled, otherwise only for the users
+// in UserIDs and the members of the organizations in OrgIDs.
+type FeatureFlag struct {
+	Key         string    `json:"key"`
+	Description string    `json:"description"`
+	Enabled     bool      `json:"enabled"`
+	UserIDs     []int     `json:"user_ids"`
+	OrgIDs      []int     `json:"org_ids"`
+	UpdatedBy   *int      `json:"updated_by"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type FeatureFlagRequest struct {
+	Description string `json:"description" binding:"max=500"`
+	Enabled     *bool  `json:"enabled" binding:"required"`
+	UserIDs     []int  `json:"user_ids" binding:"max=1000,dive,min=1"`
+	OrgIDs      []int  `json:"org_ids" binding:"max=1000,dive,min=1"`
+}
+
+type CreateFeatureFlagRequest struct {
+	Key string `json:"key" binding:"required,max=100"`
+	FeatureFlagRequest
+}
+
+// GraphQLRequest is a POST /v1/graphql body, as sent by standard GraphQL
+// clients.
+type GraphQLRequest struct {
+	Query         string         `json:"query" binding:"required"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+// GraphQLResponse is what POST /v1/graphql returns, errors included. Each
+// error's extensions hold the REST error code and status.
+type GraphQLResponse struct {
+	Data   any   `json:"data" swaggertype:"object"`
+	Errors []any `json:"errors,omitempty" swaggertype:"array,object"`
 }
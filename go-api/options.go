@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// canSeeAnswers reports whether the caller gets the teacher view with
+// is_correct flags. Teachers and admins can still ask for the student view
+// with ?view=student to preview what candidates see.
+func canSeeAnswers(c *gin.Context) bool {
+	claims, ok := currentClaims(c)
+	if !ok || (claims.Role != RoleTeacher && claims.Role != RoleAdmin) {
+		return false
+	}
+	return c.Query("view") != "student"
+}
+
+// loadOptions fetches the options for the given questions, keyed by question
+// id. The answer key is stripped unless withAnswers is set.
+func loadOptions(ctx context.Context, q querier, questionIDs []int, withAnswers bool) (map[int][]QuestionOption, error) {
+	rows, err := q.Query(ctx, `
+		SELECT id, question_id, text, is_correct FROM question_options
+		WHERE question_id = ANY($1) ORDER BY question_id, position, id`, questionIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	options := make(map[int][]QuestionOption)
+	for rows.Next() {
+		var o QuestionOption
+		var isCorrect bool
+		if err := rows.Scan(&o.ID, &o.QuestionID, &o.Text, &isCorrect); err != nil {
+			return nil, err
+		}
+		if withAnswers {
+			o.IsCorrect = &isCorrect
+		}
+		options[o.QuestionID] = append(options[o.QuestionID], o)
+	}
+	return options, rows.Err()
+}
+
+// GetQuestionOptions godoc
+// @Summary      List a question's options
+// @Description  is_correct is only included for teachers and admins, unless they pass view=student.
+// @Tags         questions
+// @Produce      json
+// @Param        id          path      int     true   "Exam ID"
+// @Param        questionId  path      int     true   "Question ID"
+// @Param        view        query     string  false  "Set to student to hide the answer key"  Enums(student)
+// @Success      200         {array}   QuestionOption
+// @Failure      400         {object}  ErrorResponse
+// @Failure      401         {object}  ErrorResponse
+// @Failure      404         {object}  ErrorResponse
+// @Failure      500         {object}  ErrorResponse
+// @Failure      503         {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/exams/{id}/questions/{questionId}/options [get]
+func GetQuestionOptions(c *gin.Context) {
+	if dbPool == nil {
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+		return
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	examID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid exam id")
+		return
+	}
+	questionID, err := strconv.Atoi(c.Param("questionId"))
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid question id")
+		return
+	}
+
+	var questionType string
+	err = dbPool.QueryRow(ctx,
+		"SELECT type FROM exam_questions WHERE id = $1 AND exam_id = $2 AND org_id = $3", questionID, examID, orgID(c)).
+		Scan(&questionType)
+	if errors.Is(err, pgx.ErrNoRows) {
+		RespondError(c, http.StatusNotFound, ErrCodeNotFound, "Question not found")
+		return
+	}
+	if err != nil {
+		requestLogger(c).Error("Failed to look up question", "question_id", questionID, "error", err)
+		respondQueryError(c, err, "Failed to fetch options")
+		return
+	}
+
+	options, err := loadOptions(ctx, dbPool, []int{questionID}, canSeeAnswers(c))
+	if err != nil {
+		requestLogger(c).Error("Failed to query options", "question_id", questionID, "error", err)
+		respondQueryError(c, err, "Failed to fetch options")
+		return
+	}
+
+	result := options[questionID]
+	if result == nil {
+		result = []QuestionOption{}
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// ReplaceQuestionOptions godoc
+// @Summary      Replace a question's options
+// @Description  Atomically replaces the full option set. At least two options and at least one correct option are required; true_false questions take exactly two. Teacher or admin only.
+// @Tags         questions
+// @Accept       json
+// @Produce      json
+// @Param        id          path      int                    true  "Exam ID"
+// @Param        questionId  path      int                    true  "Question ID"
+// @Param        options     body      ReplaceOptionsRequest  true  "New option set"
+// @Success      200         {array}   QuestionOption
+// @Failure      400         {object}  ErrorResponse
+// @Failure      401         {object}  ErrorResponse
+// @Failure      403         {object}  ErrorResponse
+// @Failure      404         {object}  ErrorResponse
+// @Failure      500         {object}  ErrorResponse
+// @Failure      503         {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/exams/{id}/questions/{questionId}/options [put]
+func ReplaceQuestionOptions(c *gin.Context) {
+	if dbPool == nil {
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+		return
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	examID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid exam id")
+		return
+	}
+	questionID, err := strconv.Atoi(c.Param("questionId"))
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid question id")
+		return
+	}
+
+	var req ReplaceOptionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindingError(c, err)
+		return
+	}
+
+	correct := 0
+	for _, o := range req.Options {
+		if o.IsCorrect {
+			correct++
+		}
+	}
+	if correct == 0 {
+		RespondError(c, http.StatusBadRequest, ErrCodeValidationFailed, "At least one option must be correct")
+		return
+	}
+
+	var options []QuestionOption
+	org := orgID(c)
+	err = WithTx(ctx, func(tx pgx.Tx) error {
+		// Lock the question so concurrent replaces can't interleave.
+		var questionType string
+		err := tx.QueryRow(ctx,
+			"SELECT type FROM exam_questions WHERE id = $1 AND exam_id = $2 AND org_id = $3 FOR UPDATE",
+			questionID, examID, org).
+			Scan(&questionType)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return newClientError(http.StatusNotFound, ErrCodeNotFound, "Question not found")
+		}
+		if err != nil {
+			return err
+		}
+		if questionType == QuestionTypeShortAnswer ||
+			(questionType == QuestionTypeTrueFalse && (len(req.Options) != 2 || correct != 1)) {
+			return newClientError(http.StatusBadRequest, ErrCodeValidationFailed,
+				"short_answer questions take no options; true_false questions take exactly two with one correct")
+		}
+
+		if _, err := tx.Exec(ctx, "DELETE FROM question_options WHERE question_id = $1", questionID); err != nil {
+			return err
+		}
+		for i, o := range req.Options {
+			_, err := tx.Exec(ctx,
+				"INSERT INTO question_options (question_id, text, is_correct, position) VALUES ($1, $2, $3, $4)",
+				questionID, o.Text, o.IsCorrect, i)
+			if err != nil {
+				return err
+			}
+		}
+
+		loaded, err := loadOptions(ctx, tx, []int{questionID}, true)
+		options = loaded[questionID]
+		return err
+	})
+	if respondClientError(c, err) {
+		return
+	}
+	if err != nil {
+		requestLogger(c).Error("Failed to replace options", "question_id", questionID, "error", err)
+		respondQueryError(c, err, "Failed to save options")
+		return
+	}
+
+	c.JSON(http.StatusOK, options)
+}
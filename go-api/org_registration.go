@@ -0,0 +1,153 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetOrgRegistration godoc
+// @Summary      Get how the organization takes registrations
+// @Description  Admin only, and not with an API key.
+// @Tags         organization
+// @Produce      json
+// @Success      200  {object}  OrgRegistration
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/organization/registration [get]
+func GetOrgRegistration(c *gin.Context) {
+	if dbPool == nil {
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+		return
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	var reg OrgRegistration
+	if err := dbPool.QueryRow(ctx,
+		"SELECT open_registration, join_code_hash IS NOT NULL FROM organizations WHERE id = $1",
+		orgID(c)).Scan(&reg.Open, &reg.HasJoinCode); err != nil {
+		requestLogger(c).Error("Failed to query organization registration", "error", err)
+		respondQueryError(c, err, "Failed to fetch registration settings")
+		return
+	}
+	c.JSON(http.StatusOK, reg)
+}
+
+// SetOrgRegistration godoc
+// @Summary      Open or close the organization to registration
+// @Description  While open, anyone can register into the organization by its slug. While closed, registering needs the join code. Admin only, and not with an API key.
+// @Tags         organization
+// @Accept       json
+// @Produce      json
+// @Param        registration  body      SetOrgRegistrationRequest  true  "Whether registration is open"
+// @Success      200           {object}  OrgRegistration
+// @Failure      400           {object}  ErrorResponse
+// @Failure      401           {object}  ErrorResponse
+// @Failure      403           {object}  ErrorResponse
+// @Failure      500           {object}  ErrorResponse
+// @Failure      503           {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/organization/registration [put]
+func SetOrgRegistration(c *gin.Context) {
+	if dbPool == nil {
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+		return
+	}
+
+	var req SetOrgRegistrationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindingError(c, err)
+		return
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	var reg OrgRegistration
+	if err := dbPool.QueryRow(ctx,
+		"UPDATE organizations SET open_registration = $1 WHERE id = $2 RETURNING open_registration, join_code_hash IS NOT NULL",
+		*req.Open, orgID(c)).Scan(&reg.Open, &reg.HasJoinCode); err != nil {
+		requestLogger(c).Error("Failed to update organization registration", "error", err)
+		respondQueryError(c, err, "Failed to update registration settings")
+		return
+	}
+
+	recordAudit(c, actorID(c), auditOrgRegistrationUpdate, auditTarget{"organization", orgID(c)}, gin.H{"open": reg.Open})
+	c.JSON(http.StatusOK, reg)
+}
+
+// RotateJoinCode godoc
+// @Summary      Generate a new join code
+// @Description  Replaces the organization's join code, so the old one stops admitting registrations. The code is only ever returned here. Admin only, and not with an API key.
+// @Tags         organization
+// @Produce      json
+// @Success      201  {object}  OrgRegistration
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/organization/join-code [post]
+func RotateJoinCode(c *gin.Context) {
+	if dbPool == nil {
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+		return
+	}
+
+	code, hash, err := newOpaqueToken()
+	if err != nil {
+		requestLogger(c).Error("Failed to generate join code", "error", err)
+		RespondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to generate join code")
+		return
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	reg := OrgRegistration{HasJoinCode: true, JoinCode: code}
+	if err := dbPool.QueryRow(ctx,
+		"UPDATE organizations SET join_code_hash = $1 WHERE id = $2 RETURNING open_registration",
+		hash, orgID(c)).Scan(&reg.Open); err != nil {
+		requestLogger(c).Error("Failed to store join code", "error", err)
+		respondQueryError(c, err, "Failed to generate join code")
+		return
+	}
+
+	recordAudit(c, actorID(c), auditOrgJoinCodeRotate, auditTarget{"organization", orgID(c)}, nil)
+	c.JSON(http.StatusCreated, reg)
+}
+
+// RemoveJoinCode godoc
+// @Summary      Remove the join code
+// @Description  The organization's join code stops working, so a closed organization takes no registrations at all. Admin only, and not with an API key.
+// @Tags         organization
+// @Success      204
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/organization/join-code [delete]
+func RemoveJoinCode(c *gin.Context) {
+	if dbPool == nil {
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+		return
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	if _, err := dbPool.Exec(ctx, "UPDATE organizations SET join_code_hash = NULL WHERE id = $1", orgID(c)); err != nil {
+		requestLogger(c).Error("Failed to remove join code", "error", err)
+		respondQueryError(c, err, "Failed to remove join code")
+		return
+	}
+
+	recordAudit(c, actorID(c), auditOrgJoinCodeRemove, auditTarget{"organization", orgID(c)}, nil)
+	c.Status(http.StatusNoContent)
+}
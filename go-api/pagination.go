@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// parsePagination reads ?limit= and ?offset= from the query string. The limit
+// defaults to defaultPageLimit and is capped at maxPageLimit.
+func parsePagination(c *gin.Context) (limit, offset int, err error) {
+	limit = defaultPageLimit
+	if v := c.Query("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 0 {
+			return 0, 0, fmt.Errorf("limit must be a non-negative integer")
+		}
+		if limit > maxPageLimit {
+			limit = maxPageLimit
+		}
+	}
+
+	if v := c.Query("offset"); v != "" {
+		offset, err = strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return 0, 0, fmt.Errorf("offset must be a non-negative integer")
+		}
+	}
+
+	return limit, offset, nil
+}
+
+// setPageLinks sets an RFC 8288 Link header with the first, prev, next and
+// last pages of an offset-paged listing, as relative URLs that keep the rest of
+// the request's query. count is the number of rows on this page; a negative
+// total means it wasn't counted, so there's no last link and next is offered
+// whenever the page came back full.
+func setPageLinks(c *gin.Context, limit, offset, count, total int) {
+	if limit == 0 {
+		return
+	}
+	page := func(offset int, rel string) string {
+		query := c.Request.URL.Query()
+		query.Set("limit", strconv.Itoa(limit))
+		query.Set("offset", strconv.Itoa(offset))
+		u := url.URL{Path: c.Request.URL.Path, RawQuery: query.Encode()}
+		return "<" + u.String() + `>; rel="` + rel + `"`
+	}
+
+	links := []string{page(0, "first")}
+	if offset > 0 {
+		links = append(links, page(max(offset-limit, 0), "prev"))
+	}
+	if (total >= 0 && offset+limit < total) || (total < 0 && count == limit) {
+		links = append(links, page(offset+limit, "next"))
+	}
+	if total >= 0 {
+		links = append(links, page(max(total-1, 0)/limit*limit, "last"))
+	}
+	c.Header("Link", strings.Join(links, ", "))
+}
+
+// parseSort builds an ORDER BY clause from ?sort= and ?order=. Column names
+// are only ever taken from the allowed map, never from the request itself.
+// tieBreaker is appended so pages stay stable when sort values repeat.
+func parseSort(c *gin.Context, allowed map[string]string, defaultSort, tieBreaker string) (string, error) {
+	key := c.DefaultQuery("sort", defaultSort)
+	column, ok := allowed[key]
+	if !ok {
+		keys := make([]string, 0, len(allowed))
+		for k := range allowed {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return "", fmt.Errorf("invalid sort %q: must be one of %s", key, strings.Join(keys, ", "))
+	}
+
+	direction := "ASC"
+	switch strings.ToLower(c.DefaultQuery("order", "asc")) {
+	case "asc":
+	case "desc":
+		direction = "DESC"
+	default:
+		return "", fmt.Errorf("invalid order: must be asc or desc")
+	}
+
+	clause := " ORDER BY " + column + " " + direction
+	if tieBreaker != "" && column != tieBreaker {
+		clause += ", " + tieBreaker + " " + direction
+	}
+	return clause, nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// pageLinks runs setPageLinks for a request to target and returns the Link
+// header it set.
+func pageLinks(target string, limit, offset, count, total int) string {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, target, nil)
+	setPageLinks(c, limit, offset, count, total)
+	return w.Header().Get("Link")
+}
+
+func TestSetPageLinks(t *testing.T) {
+	tests := []struct {
+		name                        string
+		limit, offset, count, total int
+		want                        string
+	}{
+		{
+			name: "first page", limit: 10, offset: 0, count: 10, total: 25,
+			want: `</v1/users?limit=10&offset=0&sort=email>; rel="first", ` +
+				`</v1/users?limit=10&offset=10&sort=email>; rel="next", ` +
+				`</v1/users?limit=10&offset=20&sort=email>; rel="last"`,
+		},
+		{
+			name: "middle page", limit: 10, offset: 10, count: 10, total: 25,
+			want: `</v1/users?limit=10&offset=0&sort=email>; rel="first", ` +
+				`</v1/users?limit=10&offset=0&sort=email>; rel="prev", ` +
+				`</v1/users?limit=10&offset=20&sort=email>; rel="next", ` +
+				`</v1/users?limit=10&offset=20&sort=email>; rel="last"`,
+		},
+		{
+			name: "last page", limit: 10, offset: 20, count: 5, total: 25,
+			want: `</v1/users?limit=10&offset=0&sort=email>; rel="first", ` +
+				`</v1/users?limit=10&offset=10&sort=email>; rel="prev", ` +
+				`</v1/users?limit=10&offset=20&sort=email>; rel="last"`,
+		},
+		{
+			name: "last page filled exactly", limit: 10, offset: 10, count: 10, total: 20,
+			want: `</v1/users?limit=10&offset=0&sort=email>; rel="first", ` +
+				`</v1/users?limit=10&offset=0&sort=email>; rel="prev", ` +
+				`</v1/users?limit=10&offset=10&sort=email>; rel="last"`,
+		},
+		{
+			name: "offset off the page grid", limit: 10, offset: 5, count: 10, total: 25,
+			want: `</v1/users?limit=10&offset=0&sort=email>; rel="first", ` +
+				`</v1/users?limit=10&offset=0&sort=email>; rel="prev", ` +
+				`</v1/users?limit=10&offset=15&sort=email>; rel="next", ` +
+				`</v1/users?limit=10&offset=20&sort=email>; rel="last"`,
+		},
+		{
+			name: "empty listing", limit: 10, offset: 0, count: 0, total: 0,
+			want: `</v1/users?limit=10&offset=0&sort=email>; rel="first", ` +
+				`</v1/users?limit=10&offset=0&sort=email>; rel="last"`,
+		},
+		{
+			name: "uncounted, full page", limit: 10, offset: 10, count: 10, total: -1,
+			want: `</v1/users?limit=10&offset=0&sort=email>; rel="first", ` +
+				`</v1/users?limit=10&offset=0&sort=email>; rel="prev", ` +
+				`</v1/users?limit=10&offset=20&sort=email>; rel="next"`,
+		},
+		{
+			name: "uncounted, short page", limit: 10, offset: 10, count: 3, total: -1,
+			want: `</v1/users?limit=10&offset=0&sort=email>; rel="first", ` +
+				`</v1/users?limit=10&offset=0&sort=email>; rel="prev"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := pageLinks("/v1/users?sort=email&offset=999", tt.limit, tt.offset, tt.count, tt.total)
+			if got != tt.want {
+				t.Errorf("Link =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetPageLinksZeroLimit(t *testing.T) {
+	if got := pageLinks("/v1/users", 0, 0, 0, 25); got != "" {
+		t.Errorf("Link = %q, want none for limit 0", got)
+	}
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"golang.org/x/crypto/bcrypt"
+)
+
+// bcryptCost is set from Config.BcryptCost at startup.
+var bcryptCost = bcrypt.DefaultCost
+
+// hashPassword returns the bcrypt hash of a plaintext password. Each call uses
+// a fresh salt, so hashing the same password twice gives different results.
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// verifyPassword reports whether password matches the stored bcrypt hash.
+func verifyPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
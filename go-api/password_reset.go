@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"text/template"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	defaultPasswordResetTTL = 1 * time.Hour
+	forgotPasswordAck       = "If an account uses that email, a reset link is on its way"
+	invalidResetToken       = "Reset link is invalid or has expired"
+)
+
+var passwordResetEmailTemplate = template.Must(template.New("password-reset").Parse(`Hi {{.Username}},
+
+Someone asked to reset the password for your Quick Quiz account. To choose a new one, open this link:
+
+{{.Link}}
+
+The link works once and expires on {{.ExpiresAt.Format "2 Jan 2006 15:04 MST"}}. If you didn't ask for this, you can ignore this email; your password hasn't changed.
+`))
+
+// ForgotPassword godoc
+// @Summary      Request a password reset email
+// @Description  Emails a single-use reset link if an active account has this address. The response is the same either way, so it can't be used to discover accounts.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request  body      ForgotPasswordRequest  true  "Account email"
+// @Success      200      {object}  MessageResponse
+// @Failure      400      {object}  ErrorResponse
+// @Failure      503      {object}  ErrorResponse
+// @Router       /v1/auth/forgot-password [post]
+func ForgotPassword(mailer *accountMailer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if dbPool == nil {
+			RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+			return
+		}
+
+		var req ForgotPasswordRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondBindingError(c, err)
+			return
+		}
+
+		// The lookup happens in the background too, so response time says
+		// nothing about whether the account exists.
+		sendInBackground(requestLogger(c), func(ctx context.Context) error {
+			return mailer.sendPasswordReset(ctx, req.Email)
+		})
+		c.JSON(http.StatusOK, MessageResponse{Message: forgotPasswordAck})
+	}
+}
+
+// sendPasswordReset issues a reset token for the active account with email,
+// if there is one, and emails the link.
+func (m *accountMailer) sendPasswordReset(ctx context.Context, email string) error {
+	var user User
+	err := dbPool.QueryRow(ctx, `
+		SELECT id, username, email
+		FROM up_users
+		WHERE lower(email) = lower($1) AND blocked IS NOT TRUE AND deleted_at IS NULL
+		LIMIT 1`, email).Scan(&user.ID, &user.Username, &user.Email)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	token, hash, err := newOpaqueToken()
+	if err != nil {
+		return err
+	}
+	var expiresAt time.Time
+	err = dbPool.QueryRow(ctx, `
+		INSERT INTO password_resets (user_id, token_hash, expires_at)
+		VALUES ($1, $2, now() + make_interval(secs => $3)) RETURNING expires_at`,
+		user.ID, hash, m.resetTTL.Seconds()).Scan(&expiresAt)
+	if err != nil {
+		return err
+	}
+
+	return m.send(ctx, user, "Reset your Quick Quiz password", passwordResetEmailTemplate, tokenLink(m.resetURL, token), expiresAt)
+}
+
+// ResetPassword godoc
+// @Summary      Reset a password
+// @Description  Sets a new password using the token from a reset email. Each token works once and only until it expires; using one also voids the account's other outstanding reset links.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request  body      ResetPasswordRequest  true  "Reset token and new password"
+// @Success      200      {object}  MessageResponse
+// @Failure      400      {object}  ErrorResponse
+// @Failure      500      {object}  ErrorResponse
+// @Failure      503      {object}  ErrorResponse
+// @Router       /v1/auth/reset-password [post]
+func ResetPassword(c *gin.Context) {
+	if dbPool == nil {
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+		return
+	}
+
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindingError(c, err)
+		return
+	}
+
+	// Hash before the transaction so bcrypt doesn't hold the row lock.
+	passwordHash, err := hashPassword(req.Password)
+	if err != nil {
+		requestLogger(c).Error("Failed to hash password", "error", err)
+		RespondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to reset password")
+		return
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	var userID int
+	err = WithTx(ctx, func(tx pgx.Tx) error {
+		var usable bool
+		// The row lock makes two concurrent uses of one token serialize, so
+		// only the first gets through.
+		err := tx.QueryRow(ctx, `
+			SELECT user_id, used_at IS NULL AND expires_at > now()
+			FROM password_resets
+			WHERE token_hash = $1
+			FOR UPDATE`, hashOpaqueToken(req.Token)).Scan(&userID, &usable)
+		if errors.Is(err, pgx.ErrNoRows) || (err == nil && !usable) {
+			return newClientError(http.StatusBadRequest, ErrCodeInvalidToken, invalidResetToken)
+		}
+		if err != nil {
+			return err
+		}
+
+		tag, err := tx.Exec(ctx, `
+			UPDATE up_users SET password = $1, updated_at = now()
+			WHERE id = $2 AND blocked IS NOT TRUE AND deleted_at IS NULL`, passwordHash, userID)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return newClientError(http.StatusBadRequest, ErrCodeInvalidToken, invalidResetToken)
+		}
+
+		_, err = tx.Exec(ctx,
+			"UPDATE password_resets SET used_at = now() WHERE user_id = $1 AND used_at IS NULL", userID)
+		return err
+	})
+	if respondClientError(c, err) {
+		return
+	}
+	if err != nil {
+		requestLogger(c).Error("Failed to reset password", "error", err)
+		respondQueryError(c, err, "Failed to reset password")
+		return
+	}
+
+	requestLogger(c).Info("Password reset", "user_id", userID)
+	recordAudit(c, &userID, auditPasswordReset, auditTarget{"user", userID}, gin.H{"ip": c.ClientIP()})
+	c.JSON(http.StatusOK, MessageResponse{Message: "Password updated"})
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// issueTestResetToken stores a reset token for userID that expires after
+// ttl, which may be negative, and returns it.
+func issueTestResetToken(t *testing.T, ctx context.Context, userID int, ttl time.Duration) string {
+	t.Helper()
+	token, hash, err := newOpaqueToken()
+	if err != nil {
+		t.Fatalf("newOpaqueToken: %v", err)
+	}
+	if _, err := dbPool.Exec(ctx, `
+		INSERT INTO password_resets (user_id, token_hash, expires_at)
+		VALUES ($1, $2, now() + make_interval(secs => $3))`, userID, hash, ttl.Seconds()); err != nil {
+		t.Fatalf("store reset token: %v", err)
+	}
+	return token
+}
+
+// storedPasswordIs reports whether userID's password is now password.
+func storedPasswordIs(t *testing.T, ctx context.Context, userID int, password string) bool {
+	t.Helper()
+	var hash string
+	if err := dbPool.QueryRow(ctx, "SELECT password FROM up_users WHERE id = $1", userID).Scan(&hash); err != nil {
+		t.Fatalf("read password: %v", err)
+	}
+	return verifyPassword(hash, password)
+}
+
+func TestResetPasswordExpiredToken(t *testing.T) {
+	ctx := testDB(t)
+	user := testUser(t, ctx, testOrg(t, ctx), RoleStudent, "oldpass123")
+	token := issueTestResetToken(t, ctx, user.ID, -time.Second)
+
+	w := postJSON(t, ResetPassword, gin.H{"token": token, "password": "newpass123"}, nil)
+	if w.Code != http.StatusBadRequest || errorCode(t, w) != ErrCodeInvalidToken {
+		t.Fatalf("expired token: %d %s, want 400 %s", w.Code, w.Body, ErrCodeInvalidToken)
+	}
+	if !storedPasswordIs(t, ctx, user.ID, "oldpass123") {
+		t.Error("an expired token changed the password")
+	}
+}
+
+func TestResetPasswordSingleUse(t *testing.T) {
+	ctx := testDB(t)
+	user := testUser(t, ctx, testOrg(t, ctx), RoleStudent, "oldpass123")
+	token := issueTestResetToken(t, ctx, user.ID, time.Hour)
+	other := issueTestResetToken(t, ctx, user.ID, time.Hour)
+
+	w := postJSON(t, ResetPassword, gin.H{"token": token, "password": "newpass123"}, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("first use: %d %s, want 200", w.Code, w.Body)
+	}
+	if !storedPasswordIs(t, ctx, user.ID, "newpass123") {
+		t.Fatal("the password wasn't changed")
+	}
+
+	w = postJSON(t, ResetPassword, gin.H{"token": token, "password": "again12345"}, nil)
+	if w.Code != http.StatusBadRequest || errorCode(t, w) != ErrCodeInvalidToken {
+		t.Errorf("reuse: %d %s, want 400 %s", w.Code, w.Body, ErrCodeInvalidToken)
+	}
+	w = postJSON(t, ResetPassword, gin.H{"token": other, "password": "again12345"}, nil)
+	if w.Code != http.StatusBadRequest || errorCode(t, w) != ErrCodeInvalidToken {
+		t.Errorf("other outstanding token: %d %s, want 400 %s", w.Code, w.Body, ErrCodeInvalidToken)
+	}
+	if !storedPasswordIs(t, ctx, user.ID, "newpass123") {
+		t.Error("a used token changed the password again")
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import "testing"
+
+func TestHashPasswordRoundTrip(t *testing.T) {
+	hash, err := hashPassword("correct horse 1")
+	if err != nil {
+		t.Fatalf("hashPassword: %v", err)
+	}
+	if hash == "correct horse 1" {
+		t.Fatal("hash is the plaintext")
+	}
+	if !verifyPassword(hash, "correct horse 1") {
+		t.Error("the password it was hashed from doesn't verify")
+	}
+	for _, wrong := range []string{"", "correct horse 2", "Correct horse 1", "correct horse 1 "} {
+		if verifyPassword(hash, wrong) {
+			t.Errorf("wrong password %q verifies", wrong)
+		}
+	}
+}
+
+func TestHashPasswordSalts(t *testing.T) {
+	first, err := hashPassword("correct horse 1")
+	if err != nil {
+		t.Fatalf("hashPassword: %v", err)
+	}
+	second, err := hashPassword("correct horse 1")
+	if err != nil {
+		t.Fatalf("hashPassword: %v", err)
+	}
+	if first == second {
+		t.Error("hashing the same password twice gave the same hash")
+	}
+	if !verifyPassword(second, "correct horse 1") {
+		t.Error("the second hash doesn't verify")
+	}
+}
+
+func TestVerifyPasswordMalformedHash(t *testing.T) {
+	if verifyPassword("not a bcrypt hash", "anything") {
+		t.Error("a malformed hash verifies")
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Ingest caps. Events are advisory, so a client that trips one loses events
+// rather than holding anything up.
+const (
+	proctoringMaxBodyBytes    = 64 << 10
+	proctoringMaxEvents       = 2000
+	proctoringMaxDetailsBytes = 1 << 10
+	proctoringRPS             = 1
+	proctoringBurst           = 10
+	// proctoringClockSkew is how far ahead of the server a client clock may
+	// run before its timestamps are refused.
+	proctoringClockSkew = 5 * time.Minute
+)
+
+// ProctoringRateLimit throttles event ingest per signed-in user, separately
+// from the global per-IP limit, so a chatty exam client can't crowd out
+// the rest of its own traffic.
+func ProctoringRateLimit() gin.HandlerFunc {
+	return rateLimitBy(proctoringRPS, proctoringBurst, func(c *gin.Context) string {
+		if claims, ok := currentClaims(c); ok {
+			return strconv.Itoa(claims.UserID)
+		}
+		return c.ClientIP()
+	})
+}
+
+// RecordProctoringEvents godoc
+// @Summary      Record proctoring events
+// @Description  Stores a batch of integrity signals the exam client collected for the caller's own submission, for later review. Clients buffer events during the exam and send them once it is submitted, so reporting never gets in the way of answering. A batch holds at most 100 events, details at most 1 KiB each, and a submission at most 2000 events in all.
+// @Tags         proctoring
+// @Accept       json
+// @Param        id      path  int                            true  "Submission ID"
+// @Param        events  body  RecordProctoringEventsRequest  true  "Events"
+// @Success      204
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      413  {object}  ErrorResponse
+// @Failure      429  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/submissions/{id}/events [post]
+func RecordProctoringEvents(c *gin.Context) {
+	if dbPool == nil {
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid submission id")
+		return
+	}
+
+	claims, ok := currentClaims(c)
+	if !ok {
+		RespondError(c, http.StatusUnauthorized, ErrCodeUnauthorized, "Authentication required")
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, proctoringMaxBodyBytes)
+	var req RecordProctoringEventsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindingError(c, err)
+		return
+	}
+
+	types := make([]string, len(req.Events))
+	times := make([]time.Time, len(req.Events))
+	details := make([]*string, len(req.Events))
+	latest := time.Now().Add(proctoringClockSkew)
+	var problems []FieldError
+	for i, e := range req.Events {
+		field := "events[" + strconv.Itoa(i) + "]"
+		if e.OccurredAt.After(latest) {
+			problems = append(problems, FieldError{Field: field + ".occurred_at", Message: "is in the future"})
+		}
+		if len(e.Details) > proctoringMaxDetailsBytes {
+			problems = append(problems, FieldError{Field: field + ".details",
+				Message: "must be at most " + strconv.Itoa(proctoringMaxDetailsBytes) + " bytes"})
+		}
+		types[i], times[i] = e.Type, e.OccurredAt
+		if len(e.Details) > 0 && string(e.Details) != "null" {
+			d := string(e.Details)
+			details[i] = &d
+		}
+	}
+	if len(problems) > 0 {
+		RespondErrorDetails(c, http.StatusBadRequest, ErrCodeValidationFailed, "Invalid events", problems)
+		return
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	err = WithTx(ctx, func(tx pgx.Tx) error {
+		// The row lock serializes batches for one submission so the cap
+		// below can't be overshot by concurrent requests.
+		var ownerID int
+		err := tx.QueryRow(ctx,
+			"SELECT user_id FROM submissions WHERE id = $1 AND org_id = $2 FOR UPDATE", id, orgID(c)).Scan(&ownerID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return newClientError(http.StatusNotFound, ErrCodeNotFound, "Submission not found")
+		}
+		if err != nil {
+			return err
+		}
+		if ownerID != claims.UserID {
+			return newClientError(http.StatusForbidden, ErrCodeForbidden, "Events can only be recorded for your own submission")
+		}
+
+		var stored int
+		if err := tx.QueryRow(ctx, "SELECT COUNT(*) FROM proctoring_events WHERE submission_id = $1", id).Scan(&stored); err != nil {
+			return err
+		}
+		if stored+len(req.Events) > proctoringMaxEvents {
+			return newClientError(http.StatusRequestEntityTooLarge, ErrCodePayloadTooLarge,
+				"A submission can have at most "+strconv.Itoa(proctoringMaxEvents)+" events")
+		}
+
+		_, err = tx.Exec(ctx, `
+			INSERT INTO proctoring_events (submission_id, event_type, occurred_at, details)
+			SELECT $1, t, o, d::jsonb FROM unnest($2::text[], $3::timestamptz[], $4::text[]) AS e (t, o, d)`,
+			id, types, times, details)
+		return err
+	})
+	if respondClientError(c, err) {
+		return
+	}
+	if err != nil {
+		requestLogger(c).Error("Failed to record proctoring events", "submission_id", id, "error", err)
+		respondQueryError(c, err, "Failed to record events")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetProctoringEvents godoc
+// @Summary      List proctoring events
+// @Description  Returns a submission's integrity events in the order they happened. Teacher or admin only.
+// @Tags         proctoring
+// @Produce      json
+// @Param        id      path      int  true   "Submission ID"
+// @Param        limit   query     int  false  "Page size (default 20, max 100)"
+// @Param        offset  query     int  false  "Rows to skip"
+// @Success      200     {object}  PaginatedProctoringEvents
+// @Failure      400     {object}  ErrorResponse
+// @Failure      401     {object}  ErrorResponse
+// @Failure      403     {object}  ErrorResponse
+// @Failure      404     {object}  ErrorResponse
+// @Failure      500     {object}  ErrorResponse
+// @Failure      503     {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/submissions/{id}/events [get]
+func GetProctoringEvents(c *gin.Context) {
+	if dbPool == nil {
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+		return
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid submission id")
+		return
+	}
+
+	limit, offset, err := parsePagination(c)
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, err.Error())
+		return
+	}
+
+	var total int
+	err = dbPool.QueryRow(ctx, `
+		SELECT (SELECT COUNT(*) FROM proctoring_events WHERE submission_id = s.id)
+		FROM submissions s WHERE s.id = $1 AND s.org_id = $2`, id, orgID(c)).Scan(&total)
+	if errors.Is(err, pgx.ErrNoRows) {
+		RespondError(c, http.StatusNotFound, ErrCodeNotFound, "Submission not found")
+		return
+	}
+	if err != nil {
+		requestLogger(c).Error("Failed to count proctoring events", "submission_id", id, "error", err)
+		respondQueryError(c, err, "Failed to fetch events")
+		return
+	}
+
+	rows, err := dbPool.Query(ctx, `
+		SELECT id, event_type, occurred_at, details, received_at
+		FROM proctoring_events
+		WHERE submission_id = $1
+		ORDER BY occurred_at, id
+		LIMIT $2 OFFSET $3`, id, limit, offset)
+	if err != nil {
+		requestLogger(c).Error("Failed to query proctoring events", "submission_id", id, "error", err)
+		respondQueryError(c, err, "Failed to fetch events")
+		return
+	}
+	defer rows.Close()
+
+	events := []ProctoringEvent{}
+	for rows.Next() {
+		e := ProctoringEvent{SubmissionID: id}
+		var details []byte
+		if err := rows.Scan(&e.ID, &e.Type, &e.OccurredAt, &details, &e.ReceivedAt); err != nil {
+			requestLogger(c).Error("Failed to scan proctoring event row", "error", err)
+			respondQueryError(c, err, "Error reading events")
+			return
+		}
+		if details != nil {
+			e.Details = details
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		requestLogger(c).Error("Failed to iterate proctoring event rows", "error", err)
+		respondQueryError(c, err, "Error reading events")
+		return
+	}
+
+	c.JSON(http.StatusOK, PaginatedProctoringEvents{Data: events, Total: total, Limit: limit, Offset: offset})
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxTagLength bounds a single tag. Tags are free-form otherwise.
+const maxTagLength = 50
+
+// normalizeTags trims and lowercases tags and drops blanks and duplicates,
+// so "Algebra" and " algebra" are the same tag. The result is sorted.
+func normalizeTags(tags []string) []string {
+	out := make([]string, 0, len(tags))
+	for _, t := range tags {
+		if t = strings.ToLower(strings.TrimSpace(t)); t != "" {
+			out = append(out, t)
+		}
+	}
+	slices.Sort(out)
+	return slices.Compact(out)
+}
+
+// tagFilter reads the repeatable tag query parameter. A question matches
+// when it has every tag given.
+func tagFilter(c *gin.Context) ([]string, error) {
+	tags := normalizeTags(c.QueryArray("tag"))
+	for _, t := range tags {
+		if len(t) > maxTagLength {
+			return nil, newClientError(http.StatusBadRequest, ErrCodeInvalidRequest,
+				"tag must be at most "+strconv.Itoa(maxTagLength)+" characters")
+		}
+	}
+	return tags, nil
+}
+
+// GetQuestionBank godoc
+// @Summary      Search the question bank
+// @Description  Lists questions across every exam, with their options and answer key, so they can be reused when assembling a new exam. Repeat tag to require several tags. Teacher or admin only.
+// @Tags         questions
+// @Produce      json
+// @Param        tag     query     []string  false  "Only questions with this tag"  collectionFormat(multi)
+// @Param        type    query     string    false  "Only questions of this type"  Enums(multiple_choice, true_false, short_answer, multi_select)
+// @Param        limit   query     int       false  "Page size (default 20, max 100)"
+// @Param        offset  query     int       false  "Rows to skip"
+// @Success      200     {object}  PaginatedQuestions
+// @Failure      400     {object}  ErrorResponse
+// @Failure      401     {object}  ErrorResponse
+// @Failure      403     {object}  ErrorResponse
+// @Failure      500     {object}  ErrorResponse
+// @Failure      503     {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/questions [get]
+func GetQuestionBank(c *gin.Context) {
+	if dbPool == nil {
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+		return
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	limit, offset, err := parsePagination(c)
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, err.Error())
+		return
+	}
+	tags, err := tagFilter(c)
+	if respondClientError(c, err) {
+		return
+	}
+
+	conds := []string{"org_id = $1"}
+	args := []any{orgID(c)}
+	if len(tags) > 0 {
+		args = append(args, tags)
+		conds = append(conds, "tags @> $"+strconv.Itoa(len(args)))
+	}
+	if v := c.Query("type"); v != "" {
+		args = append(args, v)
+		conds = append(conds, "type = $"+strconv.Itoa(len(args)))
+	}
+	where := " WHERE " + strings.Join(conds, " AND ")
+
+	pageArgs := append(args, limit, offset)
+	rows, err := dbPool.Query(ctx, "SELECT "+questionColumns+" FROM exam_questions"+where+
+		" ORDER BY id LIMIT $"+strconv.Itoa(len(args)+1)+" OFFSET $"+strconv.Itoa(len(args)+2), pageArgs...)
+	if err != nil {
+		requestLogger(c).Error("Failed to query question bank", "error", err)
+		respondQueryError(c, err, "Failed to fetch questions")
+		return
+	}
+	defer rows.Close()
+
+	questions := []Question{}
+	for rows.Next() {
+		var q Question
+		if err := scanQuestion(rows, &q); err != nil {
+			requestLogger(c).Error("Failed to scan question row", "error", err)
+			respondQueryError(c, err, "Error reading questions")
+			return
+		}
+		questions = append(questions, q)
+	}
+	if err := rows.Err(); err != nil {
+		requestLogger(c).Error("Failed to iterate question rows", "error", err)
+		respondQueryError(c, err, "Error reading questions")
+		return
+	}
+
+	ids := make([]int, len(questions))
+	for i, q := range questions {
+		ids[i] = q.ID
+	}
+	options, err := loadOptions(ctx, dbPool, ids, true)
+	if err != nil {
+		requestLogger(c).Error("Failed to query options", "error", err)
+		respondQueryError(c, err, "Failed to fetch questions")
+		return
+	}
+	for i := range questions {
+		questions[i].Options = options[questions[i].ID]
+	}
+
+	var total int
+	if err := dbPool.QueryRow(ctx, "SELECT COUNT(*) FROM exam_questions"+where, args...).Scan(&total); err != nil {
+		requestLogger(c).Error("Failed to count questions", "error", err)
+		respondQueryError(c, err, "Failed to count questions")
+		return
+	}
+
+	c.JSON(http.StatusOK, PaginatedQuestions{Data: questions, Total: total, Limit: limit, Offset: offset})
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+const questionColumns = "id, exam_id, prompt, type, scoring_strategy, points, position, tags"
+
+func scanQuestion(row pgx.Row, q *Question) error {
+	return row.Scan(&q.ID, &q.ExamID, &q.Prompt, &q.Type, &q.ScoringStrategy, &q.Points, &q.Position, &q.Tags)
+}
+
+// examExists reports whether exam id exists in org.
+func examExists(ctx context.Context, org, id int) (bool, error) {
+	var exists bool
+	err := dbPool.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM exams WHERE id = $1 AND org_id = $2)", id, org).Scan(&exists)
+	return exists, err
+}
+
+// GetExamQuestions godoc
+// @Summary      List an exam's questions
+// @Description  Questions are ordered by position and include their options. is_correct is only included for teachers and admins, unless they pass view=student. On exams with shuffle_questions or shuffle_options, candidates instead get their own order, which stays the same for the whole attempt and changes on a retake; ids are unchanged, so answers are submitted as usual.
+// @Tags         questions
+// @Produce      json
+// @Param        id    path      int       true   "Exam ID"
+// @Param        view  query     string    false  "Set to student to hide the answer key"  Enums(student)
+// @Param        tag   query     []string  false  "Only questions with this tag; repeat to require several"  collectionFormat(multi)
+// @Success      200  {array}   Question
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/exams/{id}/questions [get]
+func GetExamQuestions(c *gin.Context) {
+	if dbPool == nil {
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+		return
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	examID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid exam id")
+		return
+	}
+
+	org := orgID(c)
+	var shuffle, shuffleOptions bool
+	err = dbPool.QueryRow(ctx, "SELECT shuffle_questions, shuffle_options FROM exams WHERE id = $1 AND org_id = $2", examID, org).
+		Scan(&shuffle, &shuffleOptions)
+	if errors.Is(err, pgx.ErrNoRows) {
+		RespondError(c, http.StatusNotFound, ErrCodeNotFound, "Exam not found")
+		return
+	}
+	if err != nil {
+		requestLogger(c).Error("Failed to look up exam", "exam_id", examID, "error", err)
+		respondQueryError(c, err, "Failed to fetch questions")
+		return
+	}
+
+	tags, err := tagFilter(c)
+	if respondClientError(c, err) {
+		return
+	}
+
+	rows, err := dbPool.Query(ctx, "SELECT "+questionColumns+` FROM exam_questions
+		WHERE exam_id = $1 AND org_id = $2 AND tags @> $3
+		ORDER BY position, id`, examID, org, tags)
+	if err != nil {
+		requestLogger(c).Error("Failed to query questions", "exam_id", examID, "error", err)
+		respondQueryError(c, err, "Failed to fetch questions")
+		return
+	}
+	defer rows.Close()
+
+	questions := []Question{}
+	for rows.Next() {
+		var q Question
+		if err := scanQuestion(rows, &q); err != nil {
+			requestLogger(c).Error("Failed to scan question row", "error", err)
+			continue
+		}
+		questions = append(questions, q)
+	}
+
+	if err := rows.Err(); err != nil {
+		requestLogger(c).Error("Failed to iterate question rows", "error", err)
+		respondQueryError(c, err, "Error reading questions")
+		return
+	}
+
+	ids := make([]int, len(questions))
+	for i, q := range questions {
+		ids[i] = q.ID
+	}
+	withAnswers := canSeeAnswers(c)
+	options, err := loadOptions(ctx, dbPool, ids, withAnswers)
+	if err != nil {
+		requestLogger(c).Error("Failed to query options", "exam_id", examID, "error", err)
+		respondQueryError(c, err, "Failed to fetch questions")
+		return
+	}
+	for i := range questions {
+		questions[i].Options = options[questions[i].ID]
+	}
+
+	// Whoever sees the answer key is editing the exam, so they get the
+	// canonical order.
+	if claims, ok := currentClaims(c); ok && !withAnswers && (shuffle || shuffleOptions) {
+		used, err := countAttempts(ctx, dbPool, examID, claims.UserID)
+		if err != nil {
+			requestLogger(c).Error("Failed to count attempts", "exam_id", examID, "error", err)
+			respondQueryError(c, err, "Failed to fetch questions")
+			return
+		}
+		shuffleQuestions(questions, attemptSeed(examID, claims.UserID, used+1), shuffle, shuffleOptions)
+	}
+
+	c.JSON(http.StatusOK, questions)
+}
+
+// CreateQuestion godoc
+// @Summary      Add a question to an exam
+// @Description  Without a position the question is appended after the existing ones. scoring_strategy defaults to all_or_nothing; set it to partial to give multi_select questions partial credit. Teacher or admin only.
+// @Tags         questions
+// @Accept       json
+// @Produce      json
+// @Param        id        path      int                    true  "Exam ID"
+// @Param        question  body      CreateQuestionRequest  true  "Question to create"
+// @Success      201       {object}  Question
+// @Failure      400       {object}  ErrorResponse
+// @Failure      401       {object}  ErrorResponse
+// @Failure      403       {object}  ErrorResponse
+// @Failure      404       {object}  ErrorResponse
+// @Failure      500       {object}  ErrorResponse
+// @Failure      503       {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/exams/{id}/questions [post]
+func CreateQuestion(c *gin.Context) {
+	if dbPool == nil {
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+		return
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	examID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid exam id")
+		return
+	}
+
+	var req CreateQuestionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindingError(c, err)
+		return
+	}
+
+	points := 1
+	if req.Points != nil {
+		points = *req.Points
+	}
+	strategy := req.ScoringStrategy
+	if strategy == "" {
+		strategy = ScoringAllOrNothing
+	}
+
+	// Selecting from exams makes a missing exam yield no row (404) instead of
+	// a foreign key error, and lets us default the position in one round trip.
+	var q Question
+	err = scanQuestion(dbPool.QueryRow(ctx, `
+		INSERT INTO exam_questions (exam_id, org_id, prompt, type, scoring_strategy, points, position, tags)
+		SELECT e.id, e.org_id, $2, $3, $4, $5,
+			COALESCE($6, (SELECT COALESCE(MAX(position), 0) + 1 FROM exam_questions WHERE exam_id = e.id)), $7
+		FROM exams e WHERE e.id = $1 AND e.org_id = $8
+		RETURNING `+questionColumns,
+		examID, req.Prompt, req.Type, strategy, points, req.Position, normalizeTags(req.Tags), orgID(c)), &q)
+	if errors.Is(err, pgx.ErrNoRows) {
+		RespondError(c, http.StatusNotFound, ErrCodeNotFound, "Exam not found")
+		return
+	}
+	if err != nil {
+		requestLogger(c).Error("Failed to insert question", "exam_id", examID, "error", err)
+		respondQueryError(c, err, "Failed to create question")
+		return
+	}
+
+	c.JSON(http.StatusCreated, q)
+}
+
+// UpdateQuestion godoc
+// @Summary      Update a question
+// @Description  Only the fields present in the body are changed. Teacher or admin only.
+// @Tags         questions
+// @Accept       json
+// @Produce      json
+// @Param        id          path      int                    true  "Exam ID"
+// @Param        questionId  path      int                    true  "Question ID"
+// @Param        question    body      UpdateQuestionRequest  true  "Fields to change"
+// @Success      200         {object}  Question
+// @Failure      400         {object}  ErrorResponse
+// @Failure      401         {object}  ErrorResponse
+// @Failure      403         {object}  ErrorResponse
+// @Failure      404         {object}  ErrorResponse
+// @Failure      500         {object}  ErrorResponse
+// @Failure      503         {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/exams/{id}/questions/{questionId} [patch]
+func UpdateQuestion(c *gin.Context) {
+	if dbPool == nil {
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+		return
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	examID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid exam id")
+		return
+	}
+	questionID, err := strconv.Atoi(c.Param("questionId"))
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid question id")
+		return
+	}
+
+	var req UpdateQuestionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindingError(c, err)
+		return
+	}
+
+	var sets []string
+	var args []any
+	if req.Prompt != nil {
+		args = append(args, *req.Prompt)
+		sets = append(sets, "prompt = $"+strconv.Itoa(len(args)))
+	}
+	if req.Type != nil {
+		args = append(args, *req.Type)
+		sets = append(sets, "type = $"+strconv.Itoa(len(args)))
+	}
+	if req.ScoringStrategy != nil {
+		args = append(args, *req.ScoringStrategy)
+		sets = append(sets, "scoring_strategy = $"+strconv.Itoa(len(args)))
+	}
+	if req.Points != nil {
+		args = append(args, *req.Points)
+		sets = append(sets, "points = $"+strconv.Itoa(len(args)))
+	}
+	if req.Position != nil {
+		args = append(args, *req.Position)
+		sets = append(sets, "position = $"+strconv.Itoa(len(args)))
+	}
+	if req.Tags != nil {
+		args = append(args, normalizeTags(req.Tags))
+		sets = append(sets, "tags = $"+strconv.Itoa(len(args)))
+	}
+	if len(sets) == 0 {
+		RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "No updatable fields provided")
+		return
+	}
+
+	args = append(args, questionID, examID, orgID(c))
+	query := "UPDATE exam_questions SET " + strings.Join(sets, ", ") + ", updated_at = now()" +
+		" WHERE id = $" + strconv.Itoa(len(args)-2) + " AND exam_id = $" + strconv.Itoa(len(args)-1) +
+		" AND org_id = $" + strconv.Itoa(len(args)) + " RETURNING " + questionColumns
+
+	var q Question
+	err = scanQuestion(dbPool.QueryRow(ctx, query, args...), &q)
+	if errors.Is(err, pgx.ErrNoRows) {
+		RespondError(c, http.StatusNotFound, ErrCodeNotFound, "Question not found")
+		return
+	}
+	if err != nil {
+		requestLogger(c).Error("Failed to update question", "question_id", questionID, "error", err)
+		respondQueryError(c, err, "Failed to update question")
+		return
+	}
+
+	c.JSON(http.StatusOK, q)
+}
+
+// DeleteQuestion godoc
+// @Summary      Delete a question
+// @Description  Teacher or admin only.
+// @Tags         questions
+// @Param        id          path  int  true  "Exam ID"
+// @Param        questionId  path  int  true  "Question ID"
+// @Success      204
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/exams/{id}/questions/{questionId} [delete]
+func DeleteQuestion(c *gin.Context) {
+	if dbPool == nil {
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+		return
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	examID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid exam id")
+		return
+	}
+	questionID, err := strconv.Atoi(c.Param("questionId"))
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid question id")
+		return
+	}
+
+	tag, err := dbPool.Exec(ctx,
+		"DELETE FROM exam_questions WHERE id = $1 AND exam_id = $2 AND org_id = $3", questionID, examID, orgID(c))
+	if err != nil {
+		requestLogger(c).Error("Failed to delete question", "question_id", questionID, "error", err)
+		respondQueryError(c, err, "Failed to delete question")
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		RespondError(c, http.StatusNotFound, ErrCodeNotFound, "Question not found")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
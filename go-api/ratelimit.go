@@ -0,0 +1,163 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+)
+
+const (
+	rateLimitCleanupInterval = 1 * time.Minute
+	rateLimitIdleTTL         = 3 * time.Minute
+	// rateLimitMaxBuckets caps each limiter's map between sweeps, so a flood
+	// of distinct clients costs bounded memory.
+	rateLimitMaxBuckets = 100_000
+
+	rateLimitRemainingHeader = "X-RateLimit-Remaining"
+)
+
+type clientBucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// ipRateLimiter hands out one token bucket per client IP, or whatever key its
+// caller uses. Buckets that have been idle for rateLimitIdleTTL are swept, and
+// past rateLimitMaxBuckets a new client evicts an old one, so the map can't
+// grow unbounded.
+type ipRateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*clientBucket
+	rps     rate.Limit
+	burst   int
+}
+
+func newIPRateLimiter(rps float64, burst int) *ipRateLimiter {
+	l := &ipRateLimiter{
+		buckets: make(map[string]*clientBucket),
+		rps:     rate.Limit(rps),
+		burst:   burst,
+	}
+	go l.cleanup()
+	return l
+}
+
+func (l *ipRateLimiter) get(key string) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= rateLimitMaxBuckets {
+			l.evict()
+		}
+		b = &clientBucket{limiter: rate.NewLimiter(l.rps, l.burst)}
+		l.buckets[key] = b
+	}
+	b.lastSeen = time.Now()
+	return b.limiter
+}
+
+// evict makes room for a new bucket: idle ones first, and if none are idle an
+// arbitrary one, which at worst hands that client a fresh burst. l.mu must be
+// held.
+func (l *ipRateLimiter) evict() {
+	l.sweep()
+	for key := range l.buckets {
+		if len(l.buckets) < rateLimitMaxBuckets {
+			return
+		}
+		delete(l.buckets, key)
+	}
+}
+
+// sweep drops idle buckets. l.mu must be held.
+func (l *ipRateLimiter) sweep() {
+	for key, b := range l.buckets {
+		if time.Since(b.lastSeen) > rateLimitIdleTTL {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+func (l *ipRateLimiter) cleanup() {
+	ticker := time.NewTicker(rateLimitCleanupInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		l.mu.Lock()
+		l.sweep()
+		l.mu.Unlock()
+	}
+}
+
+// allow takes a token from key's bucket, or answers 429 and reports false.
+// Either way it sets X-RateLimit-Remaining to the whole tokens left.
+func (l *ipRateLimiter) allow(c *gin.Context, key string) bool {
+	limiter := l.get(key)
+	now := time.Now()
+	r := limiter.ReserveN(now, 1)
+	if delay := r.DelayFrom(now); delay > 0 {
+		r.CancelAt(now)
+		c.Header(rateLimitRemainingHeader, "0")
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+		RespondError(c, http.StatusTooManyRequests, ErrCodeRateLimited, "Too many requests")
+		return false
+	}
+	c.Header(rateLimitRemainingHeader, strconv.Itoa(max(int(limiter.TokensAt(now)), 0)))
+	return true
+}
+
+// RateLimit throttles each signed-in user to userRPS requests per second with
+// userBurst, and every other request by client IP to ipRPS with ipBurst, so
+// users sharing a NAT don't share a bucket. A zero rate turns that side off:
+// without a user limit signed-in users are counted by IP like everyone else.
+//
+// It runs before AuthRequired, so users are recognized by a bearer token with
+// a valid signature; the denylist and the rest of the checks are left to
+// AuthRequired. API keys are only verified against the database and are
+// counted by IP, so made-up keys can't each get a fresh bucket. The client IP
+// honours X-Forwarded-For only from trusted proxies (see TRUSTED_PROXIES);
+// otherwise it is the connection's remote address.
+func RateLimit(ipRPS float64, ipBurst int, userRPS float64, userBurst int, secret []byte) gin.HandlerFunc {
+	var ipLimiter, userLimiter *ipRateLimiter
+	if ipRPS > 0 {
+		ipLimiter = newIPRateLimiter(ipRPS, ipBurst)
+	}
+	if userRPS > 0 {
+		userLimiter = newIPRateLimiter(userRPS, userBurst)
+	}
+
+	return func(c *gin.Context) {
+		limiter, key := ipLimiter, c.ClientIP()
+		if userLimiter != nil {
+			if token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+				if claims, err := parseToken(token, secret); err == nil {
+					limiter, key = userLimiter, strconv.Itoa(claims.UserID)
+				}
+			}
+		}
+		if limiter != nil && !limiter.allow(c, key) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// rateLimitBy throttles requests to rps per second with burst, in buckets
+// keyed by key(c).
+func rateLimitBy(rps float64, burst int, key func(*gin.Context) string) gin.HandlerFunc {
+	limiter := newIPRateLimiter(rps, burst)
+
+	return func(c *gin.Context) {
+		if !limiter.allow(c, key(c)) {
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readOnly is read-only mode: reads are served, writes get a 503. It starts
+// from READ_ONLY_MODE and is flipped by PUT /v1/admin/read-only, which only
+// reaches the instance that serves it.
+var readOnly atomic.Bool
+
+// ReadOnlyGuard rejects requests whose method could write (anything but GET,
+// HEAD and OPTIONS) while read-only mode is on. Routes that write on a GET
+// add Mutation as well.
+func ReadOnlyGuard() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			if readOnly.Load() {
+				respondReadOnly(c)
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// Mutation tags a route that writes whatever its method, so read-only mode
+// rejects it too.
+func Mutation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if readOnly.Load() {
+			respondReadOnly(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+func respondReadOnly(c *gin.Context) {
+	RespondError(c, http.StatusServiceUnavailable, ErrCodeReadOnly, "The API is in read-only mode for maintenance; try again later")
+}
+
+// GetReadOnlyMode godoc
+// @Summary      Get read-only mode
+// @Description  Admin only.
+// @Tags         admin
+// @Produce      json
+// @Success      200  {object}  ReadOnlyMode
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/admin/read-only [get]
+func GetReadOnlyMode(c *gin.Context) {
+	c.JSON(http.StatusOK, ReadOnlyMode{Enabled: readOnly.Load()})
+}
+
+// SetReadOnlyMode godoc
+// @Summary      Turn read-only mode on or off
+// @Description  While on, reads are served and every write is rejected with 503 READ_ONLY. Only the instance that serves this request changes; READ_ONLY_MODE sets the mode at startup. Admin only.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        mode  body      SetReadOnlyModeRequest  true  "Whether read-only mode is on"
+// @Success      200   {object}  ReadOnlyMode
+// @Failure      400   {object}  ErrorResponse
+// @Failure      401   {object}  ErrorResponse
+// @Failure      403   {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/admin/read-only [put]
+func SetReadOnlyMode(c *gin.Context) {
+	var req SetReadOnlyModeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindingError(c, err)
+		return
+	}
+
+	// Not audited: the audit log is a write, and the database may well be
+	// the thing under maintenance.
+	if readOnly.Swap(*req.Enabled) != *req.Enabled {
+		requestLogger(c).Warn("Read-only mode changed", "enabled", *req.Enabled, "actor_id", *actorID(c))
+	}
+	c.JSON(http.StatusOK, ReadOnlyMode{Enabled: *req.Enabled})
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	defaultRefreshTokenTTL = 30 * 24 * time.Hour
+	invalidRefreshToken    = "Invalid refresh token"
+)
+
+// issueRefreshToken stores a new refresh token for userID in family and
+// returns it. A login starts a family; every rotation adds to it, so a
+// stolen token can be traced back to, and revoked with, its whole lineage.
+func issueRefreshToken(ctx context.Context, q querier, userID int, family uuid.UUID, ttl time.Duration) (token string, id int, expiresAt time.Time, err error) {
+	token, hash, err := newOpaqueToken()
+	if err != nil {
+		return "", 0, time.Time{}, err
+	}
+	err = q.QueryRow(ctx, `
+		INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at)
+		VALUES ($1, $2, $3, now() + make_interval(secs => $4)) RETURNING id, expires_at`,
+		userID, family, hash, ttl.Seconds()).Scan(&id, &expiresAt)
+	return token, id, expiresAt, err
+}
+
+// revokeRefreshFamily revokes every live token descended from the same login.
+func revokeRefreshFamily(ctx context.Context, q querier, family uuid.UUID) error {
+	_, err := q.Exec(ctx,
+		"UPDATE refresh_tokens SET revoked_at = now() WHERE family_id = $1 AND revoked_at IS NULL", family)
+	return err
+}
+
+// RefreshToken godoc
+// @Summary      Refresh an access token
+// @Description  Exchanges a refresh token for a new access token and a new refresh token. The old refresh token stops working. Presenting one that was already exchanged is treated as theft: every token from that login is revoked and the user has to log in again.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request  body      RefreshRequest  true  "Refresh token"
+// @Success      200      {object}  LoginResponse
+// @Failure      400      {object}  ErrorResponse
+// @Failure      401      {object}  ErrorResponse
+// @Failure      500      {object}  ErrorResponse
+// @Failure      503      {object}  ErrorResponse
+// @Router       /v1/auth/refresh [post]
+func RefreshToken(secret []byte, ttl, refreshTTL time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if dbPool == nil {
+			RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+			return
+		}
+
+		var req RefreshRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondBindingError(c, err)
+			return
+		}
+
+		ctx, cancel := queryContext(c)
+		defer cancel()
+
+		var (
+			claims       *Claims
+			refresh      string
+			refreshUntil time.Time
+			// revoked is set when the family had to be revoked; that has to
+			// commit, so it's reported after the transaction, not as its error.
+			revoked string
+			userID  int
+		)
+		err := WithTx(ctx, func(tx pgx.Tx) error {
+			var (
+				id                     int
+				family                 uuid.UUID
+				role                   *string
+				org                    *int
+				expired, rotated, dead bool
+			)
+			err := tx.QueryRow(ctx, `
+				SELECT t.id, t.family_id, t.user_id, t.expires_at <= now(), t.rotated_at IS NOT NULL,
+					t.revoked_at IS NOT NULL, u.role, u.org_id
+				FROM refresh_tokens t
+				LEFT JOIN up_users u ON u.id = t.user_id AND u.blocked IS NOT TRUE AND u.deleted_at IS NULL
+				WHERE t.token_hash = $1
+				FOR UPDATE OF t`, hashOpaqueToken(req.RefreshToken)).
+				Scan(&id, &family, &userID, &expired, &rotated, &dead, &role, &org)
+			if errors.Is(err, pgx.ErrNoRows) {
+				return newClientError(http.StatusUnauthorized, ErrCodeInvalidToken, invalidRefreshToken)
+			}
+			if err != nil {
+				return err
+			}
+
+			switch {
+			case dead:
+				return newClientError(http.StatusUnauthorized, ErrCodeInvalidToken, invalidRefreshToken)
+			case rotated:
+				revoked = "reused"
+				return revokeRefreshFamily(ctx, tx, family)
+			case role == nil:
+				revoked = "account_inactive"
+				return revokeRefreshFamily(ctx, tx, family)
+			case expired:
+				return newClientError(http.StatusUnauthorized, ErrCodeTokenExpired, "Refresh token expired")
+			}
+
+			var newID int
+			refresh, newID, refreshUntil, err = issueRefreshToken(ctx, tx, userID, family, refreshTTL)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Exec(ctx,
+				"UPDATE refresh_tokens SET rotated_at = now(), replaced_by = $1 WHERE id = $2", newID, id); err != nil {
+				return err
+			}
+			claims = &Claims{UserID: userID, Role: *role, OrgID: *org}
+			return nil
+		})
+		if respondClientError(c, err) {
+			return
+		}
+		if err != nil {
+			requestLogger(c).Error("Failed to rotate refresh token", "error", err)
+			respondQueryError(c, err, "Failed to refresh token")
+			return
+		}
+		if revoked != "" {
+			requestLogger(c).Warn("Refresh token family revoked", "user_id", userID, "reason", revoked, "client_ip", c.ClientIP())
+			RespondError(c, http.StatusUnauthorized, ErrCodeInvalidToken, invalidRefreshToken)
+			return
+		}
+
+		token, expiresAt, err := signToken(claims, secret, ttl)
+		if err != nil {
+			requestLogger(c).Error("Failed to sign token", "user_id", claims.UserID, "error", err)
+			RespondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to refresh token")
+			return
+		}
+
+		c.JSON(http.StatusOK, LoginResponse{
+			Token:            token,
+			ExpiresAt:        expiresAt,
+			RefreshToken:     refresh,
+			RefreshExpiresAt: refreshUntil,
+		})
+	}
+}
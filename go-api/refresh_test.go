@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+var testJWTSecret = []byte("test-secret")
+
+// refresh exchanges token through RefreshToken.
+func refresh(t *testing.T, token string) (int, LoginResponse, string) {
+	t.Helper()
+	w := postJSON(t, RefreshToken(testJWTSecret, time.Minute, time.Hour), gin.H{"refresh_token": token}, nil)
+	if w.Code != http.StatusOK {
+		return w.Code, LoginResponse{}, errorCode(t, w)
+	}
+	var resp LoginResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("body %s: %v", w.Body, err)
+	}
+	return w.Code, resp, ""
+}
+
+func TestRefreshTokenRotates(t *testing.T) {
+	ctx := testDB(t)
+	user := testUser(t, ctx, testOrg(t, ctx), RoleStudent, "")
+	first, _, _, err := issueRefreshToken(ctx, dbPool, user.ID, uuid.New(), time.Hour)
+	if err != nil {
+		t.Fatalf("issueRefreshToken: %v", err)
+	}
+
+	status, resp, code := refresh(t, first)
+	if status != http.StatusOK {
+		t.Fatalf("refresh: %d %s, want 200", status, code)
+	}
+	if resp.Token == "" || resp.RefreshToken == "" || resp.RefreshToken == first {
+		t.Fatalf("refresh returned %+v, want a new access and refresh token", resp)
+	}
+	claims, err := parseToken(resp.Token, testJWTSecret)
+	if err != nil {
+		t.Fatalf("parse access token: %v", err)
+	}
+	if claims.UserID != user.ID || claims.Role != RoleStudent {
+		t.Errorf("access token claims = %+v, want user %d as %s", claims, user.ID, RoleStudent)
+	}
+
+	if status, _, _ := refresh(t, resp.RefreshToken); status != http.StatusOK {
+		t.Errorf("refreshing with the rotated-in token: %d, want 200", status)
+	}
+}
+
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	ctx := testDB(t)
+	user := testUser(t, ctx, testOrg(t, ctx), RoleStudent, "")
+	family := uuid.New()
+	first, _, _, err := issueRefreshToken(ctx, dbPool, user.ID, family, time.Hour)
+	if err != nil {
+		t.Fatalf("issueRefreshToken: %v", err)
+	}
+	status, second, _ := refresh(t, first)
+	if status != http.StatusOK {
+		t.Fatalf("first refresh: %d, want 200", status)
+	}
+	status, third, _ := refresh(t, second.RefreshToken)
+	if status != http.StatusOK {
+		t.Fatalf("second refresh: %d, want 200", status)
+	}
+
+	// Replaying a token that was already exchanged looks like theft.
+	if status, _, code := refresh(t, first); status != http.StatusUnauthorized || code != ErrCodeInvalidToken {
+		t.Fatalf("reusing a rotated token: %d %s, want 401 %s", status, code, ErrCodeInvalidToken)
+	}
+	if status, _, code := refresh(t, third.RefreshToken); status != http.StatusUnauthorized || code != ErrCodeInvalidToken {
+		t.Errorf("latest token after reuse: %d %s, want 401 %s", status, code, ErrCodeInvalidToken)
+	}
+
+	var live int
+	if err := dbPool.QueryRow(ctx,
+		"SELECT count(*) FROM refresh_tokens WHERE family_id = $1 AND revoked_at IS NULL", family).Scan(&live); err != nil {
+		t.Fatalf("count live tokens: %v", err)
+	}
+	if live != 0 {
+		t.Errorf("%d tokens in the family are still live, want 0", live)
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-pdf/fpdf"
+	"github.com/jackc/pgx/v5"
+)
+
+// Column widths of the breakdown table, in mm. They add up to the width of
+// an A4 page inside fpdf's default 10mm margins.
+var transcriptColumns = []struct {
+	title string
+	width float64
+	align string
+}{
+	{"#", 10, "R"},
+	{"Question", 125, "L"},
+	{"Result", 30, "L"},
+	{"Points", 25, "R"},
+}
+
+// GetSubmissionPDF godoc
+// @Summary      Download a result transcript
+// @Description  Renders the submission's score and per-question breakdown as a PDF, from the scores stored at submission or last grading. Visible to the submitter and to teachers and admins.
+// @Tags         submissions
+// @Produce      application/pdf
+// @Param        id   path      int  true  "Submission ID"
+// @Success      200  {file}    file
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/submissions/{id}/pdf [get]
+func GetSubmissionPDF(c *gin.Context) {
+	if dbPool == nil {
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+		return
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid submission id")
+		return
+	}
+
+	claims, ok := currentClaims(c)
+	if !ok {
+		RespondError(c, http.StatusUnauthorized, ErrCodeUnauthorized, "Authentication required")
+		return
+	}
+
+	var (
+		examID, ownerID int
+		username, title string
+		submittedAt     time.Time
+		score           *float64
+		maxScore        *int
+	)
+	err = dbPool.QueryRow(ctx, `
+		SELECT s.exam_id, s.user_id, u.username, e.title, s.submitted_at, s.score, s.max_score
+		FROM submissions s
+		JOIN exams e ON e.id = s.exam_id
+		JOIN up_users u ON u.id = s.user_id
+		WHERE s.id = $1 AND s.org_id = $2`, id, claims.OrgID).
+		Scan(&examID, &ownerID, &username, &title, &submittedAt, &score, &maxScore)
+	if errors.Is(err, pgx.ErrNoRows) {
+		RespondError(c, http.StatusNotFound, ErrCodeNotFound, "Submission not found")
+		return
+	}
+	if err != nil {
+		requestLogger(c).Error("Failed to look up submission", "submission_id", id, "error", err)
+		respondQueryError(c, err, "Failed to render transcript")
+		return
+	}
+	if ownerID != claims.UserID && claims.Role != RoleTeacher && claims.Role != RoleAdmin {
+		RespondError(c, http.StatusForbidden, ErrCodeForbidden, "Insufficient permissions")
+		return
+	}
+
+	rows, err := dbPool.Query(ctx, `
+		SELECT q.prompt, q.type, q.points, a.id IS NOT NULL, a.points_awarded
+		FROM exam_questions q
+		LEFT JOIN submission_answers a ON a.question_id = q.id AND a.submission_id = $1
+		WHERE q.exam_id = $2
+		ORDER BY q.position, q.id`, id, examID)
+	if err != nil {
+		requestLogger(c).Error("Failed to query transcript rows", "submission_id", id, "error", err)
+		respondQueryError(c, err, "Failed to render transcript")
+		return
+	}
+	defer rows.Close()
+
+	generatedAt := time.Now().UTC()
+	pdf := fpdf.New("P", "mm", "A4", "")
+	// The core fonts are cp1252; this maps what it can from UTF-8.
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.SetTitle(tr(title+" - result"), false)
+	pdf.SetAuthor("Quick Quiz", false)
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-15)
+		pdf.SetFont("Helvetica", "", 8)
+		left, _, _, _ := pdf.GetMargins()
+		pdf.CellFormat(0, 10, "Generated "+generatedAt.Format("2 Jan 2006 15:04 MST"), "", 0, "L", false, 0, "")
+		pdf.SetX(left)
+		pdf.CellFormat(0, 10, "Page "+strconv.Itoa(pdf.PageNo()), "", 0, "R", false, 0, "")
+	})
+	pdf.AddPage()
+
+	pdf.SetFont("Helvetica", "B", 18)
+	pdf.CellFormat(0, 12, "Exam result", "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 11)
+	for _, line := range [][2]string{
+		{"Exam", title},
+		{"Candidate", username},
+		{"Submitted", submittedAt.UTC().Format("2 Jan 2006 15:04 MST")},
+		{"Submission", "#" + strconv.Itoa(id)},
+		{"Score", transcriptScore(score, maxScore)},
+	} {
+		pdf.SetFont("Helvetica", "B", 11)
+		pdf.CellFormat(30, 7, line[0], "", 0, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "", 11)
+		pdf.CellFormat(0, 7, tr(line[1]), "", 1, "L", false, 0, "")
+	}
+	pdf.Ln(6)
+
+	header := func() {
+		pdf.SetFont("Helvetica", "B", 10)
+		pdf.SetFillColor(230, 230, 230)
+		for _, col := range transcriptColumns {
+			pdf.CellFormat(col.width, 8, col.title, "B", 0, col.align, true, 0, "")
+		}
+		pdf.Ln(-1)
+		pdf.SetFont("Helvetica", "", 10)
+	}
+	header()
+
+	// Rows go straight from the result set into the document, so only the
+	// rendered PDF is held in memory, not the questions as well.
+	n := 0
+	_, pageHeight := pdf.GetPageSize()
+	_, _, _, bottom := pdf.GetMargins()
+	for rows.Next() {
+		var (
+			prompt, questionType string
+			maxPoints            int
+			answered             bool
+			awarded              *float64
+		)
+		if err := rows.Scan(&prompt, &questionType, &maxPoints, &answered, &awarded); err != nil {
+			requestLogger(c).Error("Failed to scan transcript row", "error", err)
+			respondQueryError(c, err, "Failed to render transcript")
+			return
+		}
+		n++
+		if pdf.GetY()+7 > pageHeight-bottom-15 {
+			pdf.AddPage()
+			header()
+		}
+
+		status, points := transcriptResult(questionType, maxPoints, answered, awarded)
+		cells := []string{strconv.Itoa(n), fitCell(pdf, tr(prompt), transcriptColumns[1].width-2), status, points}
+		for i, col := range transcriptColumns {
+			pdf.CellFormat(col.width, 7, cells[i], "B", 0, col.align, false, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+	if err := rows.Err(); err != nil {
+		requestLogger(c).Error("Failed to iterate transcript rows", "error", err)
+		respondQueryError(c, err, "Failed to render transcript")
+		return
+	}
+	if err := pdf.Error(); err != nil {
+		requestLogger(c).Error("Failed to render transcript", "submission_id", id, "error", err)
+		RespondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to render transcript")
+		return
+	}
+
+	filename := "result-" + strconv.Itoa(id) + ".pdf"
+	c.Header("Content-Type", "application/pdf")
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Status(http.StatusOK)
+	if err := pdf.Output(c.Writer); err != nil {
+		// Headers are already sent; the client sees a truncated file.
+		requestLogger(c).Warn("Failed to write transcript", "submission_id", id, "error", err)
+	}
+}
+
+func transcriptScore(score *float64, maxScore *int) string {
+	if score == nil || maxScore == nil {
+		return "Not scored yet"
+	}
+	return formatPoints(*score) + " / " + strconv.Itoa(*maxScore)
+}
+
+// transcriptResult is the Result and Points cells for one question, using
+// the stored points rather than re-grading.
+func transcriptResult(questionType string, maxPoints int, answered bool, awarded *float64) (string, string) {
+	outOf := " / " + strconv.Itoa(maxPoints)
+	switch {
+	case !answered:
+		return "Unanswered", "0" + outOf
+	case awarded == nil:
+		if questionType == QuestionTypeShortAnswer {
+			return "Pending", "-" + outOf
+		}
+		return "Not scored", "-" + outOf
+	case *awarded >= float64(maxPoints):
+		return "Correct", formatPoints(*awarded) + outOf
+	case *awarded > 0:
+		return "Partial", formatPoints(*awarded) + outOf
+	default:
+		return "Incorrect", "0" + outOf
+	}
+}
+
+func formatPoints(p float64) string {
+	return strconv.FormatFloat(p, 'f', -1, 64)
+}
+
+// fitCell flattens s onto one line and cuts it to fit width, marking the cut
+// with an ellipsis.
+func fitCell(pdf *fpdf.Fpdf, s string, width float64) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if pdf.GetStringWidth(s) <= width {
+		return s
+	}
+	const ellipsis = "..."
+	for len(s) > 0 && pdf.GetStringWidth(s+ellipsis) > width {
+		s = s[:len(s)-1]
+	}
+	return s + ellipsis
+}
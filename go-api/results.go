@@ -0,0 +1,120 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+var resultSortColumns = map[string]string{
+	"id":           "s.id",
+	"username":     "u.username",
+	"score":        "s.score",
+	"submitted_at": "s.submitted_at",
+}
+
+// GetExamResults godoc
+// @Summary      List an exam's results
+// @Description  Returns a page of submissions with their scores, plus a summary over all of the exam's submissions. Teacher or admin only.
+// @Tags         submissions
+// @Produce      json
+// @Param        id      path   int     true   "Exam ID"
+// @Param        limit   query  int     false  "Page size (default 20, max 100)"
+// @Param        offset  query  int     false  "Rows to skip"
+// @Param        sort    query  string  false  "Sort column"  Enums(id, username, score, submitted_at)
+// @Param        order   query  string  false  "Sort direction"  Enums(asc, desc)
+// @Success      200  {object}  ExamResults
+// @Header       200  {string}  Link  "first, prev, next and last page URLs (RFC 8288)"
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/exams/{id}/results [get]
+func GetExamResults(c *gin.Context) {
+	if dbPool == nil {
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+		return
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	examID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid exam id")
+		return
+	}
+
+	limit, offset, err := parsePagination(c)
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, err.Error())
+		return
+	}
+
+	orderBy, err := parseSort(c, resultSortColumns, "id", "s.id")
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, err.Error())
+		return
+	}
+
+	exists, err := examExists(ctx, orgID(c), examID)
+	if err != nil {
+		requestLogger(c).Error("Failed to look up exam", "exam_id", examID, "error", err)
+		respondQueryError(c, err, "Failed to fetch results")
+		return
+	}
+	if !exists {
+		RespondError(c, http.StatusNotFound, ErrCodeNotFound, "Exam not found")
+		return
+	}
+
+	rows, err := dbPool.Query(ctx, `
+		SELECT s.id, s.user_id, u.username, s.score, s.max_score, s.submitted_at
+		FROM submissions s JOIN up_users u ON u.id = s.user_id
+		WHERE s.exam_id = $1`+orderBy+" LIMIT $2 OFFSET $3", examID, limit, offset)
+	if err != nil {
+		requestLogger(c).Error("Failed to query results", "exam_id", examID, "error", err)
+		respondQueryError(c, err, "Failed to fetch results")
+		return
+	}
+	defer rows.Close()
+
+	results := []ExamResult{}
+	for rows.Next() {
+		var r ExamResult
+		if err := rows.Scan(&r.SubmissionID, &r.UserID, &r.Username, &r.Score, &r.MaxScore, &r.SubmittedAt); err != nil {
+			requestLogger(c).Error("Failed to scan result row", "error", err)
+			continue
+		}
+		results = append(results, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		requestLogger(c).Error("Failed to iterate result rows", "error", err)
+		respondQueryError(c, err, "Error reading results")
+		return
+	}
+
+	var summary ResultsSummary
+	err = dbPool.QueryRow(ctx,
+		"SELECT COUNT(*), AVG(score)::float8, MIN(score), MAX(score) FROM submissions WHERE exam_id = $1", examID).
+		Scan(&summary.Count, &summary.Average, &summary.Min, &summary.Max)
+	if err != nil {
+		requestLogger(c).Error("Failed to summarize results", "exam_id", examID, "error", err)
+		respondQueryError(c, err, "Failed to summarize results")
+		return
+	}
+
+	setPageLinks(c, limit, offset, len(results), summary.Count)
+	c.JSON(http.StatusOK, ExamResults{
+		Data:    results,
+		Summary: summary,
+		Total:   summary.Count,
+		Limit:   limit,
+		Offset:  offset,
+	})
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// scopeStatus runs RequireScope(scopes...) for claims and returns the status
+// and, when refused, the error code.
+func scopeStatus(t *testing.T, claims *Claims, scopes ...string) (int, string) {
+	t.Helper()
+	r := gin.New()
+	r.GET("/", func(c *gin.Context) {
+		if claims != nil {
+			c.Set(claimsKey, claims)
+		}
+	}, RequireScope(scopes...), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code == http.StatusNoContent {
+		return w.Code, ""
+	}
+	return w.Code, errorCode(t, w)
+}
+
+func TestRequireScope(t *testing.T) {
+	tests := []struct {
+		name     string
+		claims   *Claims
+		scopes   []string
+		wantCode string
+	}{
+		{"key with the scope", &Claims{Role: RoleAdmin, Scopes: []string{ScopeExamsRead}}, []string{ScopeExamsRead}, ""},
+		{"key with one of the scopes", &Claims{Role: RoleAdmin, Scopes: []string{ScopeExamsGrade}}, []string{ScopeSubmissionsRead, ScopeExamsGrade}, ""},
+		{"key missing the scope", &Claims{Role: RoleAdmin, Scopes: []string{ScopeExamsRead}}, []string{ScopeUsersWrite}, ErrCodeInsufficientScope},
+		{"key with no scopes", &Claims{Role: RoleAdmin, Scopes: []string{}}, []string{ScopeUsersRead}, ErrCodeInsufficientScope},
+		{"student login reading exams", &Claims{Role: RoleStudent}, []string{ScopeExamsRead}, ""},
+		{"student login writing exams", &Claims{Role: RoleStudent}, []string{ScopeExamsWrite}, ErrCodeInsufficientScope},
+		{"student login grading", &Claims{Role: RoleStudent}, []string{ScopeExamsGrade}, ErrCodeInsufficientScope},
+		{"student login reading audit", &Claims{Role: RoleStudent}, []string{ScopeAuditRead}, ErrCodeInsufficientScope},
+		{"teacher login grading", &Claims{Role: RoleTeacher}, []string{ScopeExamsGrade}, ""},
+		{"teacher login writing webhooks", &Claims{Role: RoleTeacher}, []string{ScopeWebhooksWrite}, ErrCodeInsufficientScope},
+		{"admin login reading audit", &Claims{Role: RoleAdmin}, []string{ScopeAuditRead}, ""},
+		{"login with an unknown role", &Claims{Role: "guest"}, []string{ScopeExamsRead}, ErrCodeInsufficientScope},
+		{"scoped token narrower than its role", &Claims{Role: RoleAdmin, Scopes: []string{ScopeExamsRead}}, []string{ScopeAuditRead}, ErrCodeInsufficientScope},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, code := scopeStatus(t, tt.claims, tt.scopes...)
+			switch {
+			case tt.wantCode == "" && status != http.StatusNoContent:
+				t.Errorf("got %d %s, want it let through", status, code)
+			case tt.wantCode != "" && (status != http.StatusForbidden || code != tt.wantCode):
+				t.Errorf("got %d %s, want 403 %s", status, code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestRequireScopeNeedsClaims(t *testing.T) {
+	if status, code := scopeStatus(t, nil, ScopeExamsRead); status != http.StatusUnauthorized || code != ErrCodeUnauthorized {
+		t.Errorf("got %d %s, want 401 %s", status, code, ErrCodeUnauthorized)
+	}
+}
+
+func TestRequireGRPCScope(t *testing.T) {
+	if err := requireGRPCScope(&Claims{Role: RoleStudent}, ScopeUsersRead); err != nil {
+		t.Errorf("student login reading users: %v", err)
+	}
+	if err := requireGRPCScope(&Claims{Role: RoleAdmin, Scopes: []string{ScopeUsersRead}}, ScopeUsersWrite); err == nil {
+		t.Error("read-only key writing users was let through")
+	}
+}
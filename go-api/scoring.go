@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// scoreSubmission grades the objective answers of a submission against the
+// current answer key and stores the per-answer points and the totals. Short
+// answers keep whatever a grader awarded, or stay pending. It must run inside
+// a transaction: the exam's questions are share-locked so an answer key edit
+// can't land halfway through.
+func scoreSubmission(ctx context.Context, tx pgx.Tx, submissionID int) (*SubmissionScore, error) {
+	var examID int
+	err := tx.QueryRow(ctx, "SELECT exam_id FROM submissions WHERE id = $1 FOR UPDATE", submissionID).Scan(&examID)
+	if err != nil {
+		return nil, err
+	}
+
+	type answeredQuestion struct {
+		QuestionScore
+		strategy string
+		answer   []byte
+		awarded  *float64
+	}
+	rows, err := tx.Query(ctx, `
+		SELECT q.id, q.type, q.scoring_strategy, q.points, a.id, a.answer, a.points_awarded, a.feedback
+		FROM exam_questions q
+		LEFT JOIN submission_answers a ON a.question_id = q.id AND a.submission_id = $1
+		WHERE q.exam_id = $2
+		ORDER BY q.position, q.id
+		FOR SHARE OF q`, submissionID, examID)
+	if err != nil {
+		return nil, err
+	}
+	var questions []answeredQuestion
+	var ids []int
+	for rows.Next() {
+		var q answeredQuestion
+		if err := rows.Scan(&q.QuestionID, &q.Type, &q.strategy, &q.MaxPoints, &q.AnswerID, &q.answer, &q.awarded, &q.Feedback); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		questions = append(questions, q)
+		ids = append(ids, q.QuestionID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	options, err := loadOptions(ctx, tx, ids, true)
+	if err != nil {
+		return nil, err
+	}
+
+	score := &SubmissionScore{SubmissionID: submissionID, Questions: []QuestionScore{}}
+	graded := map[int]float64{}
+	for _, q := range questions {
+		qs := q.QuestionScore
+		switch {
+		case q.AnswerID == nil:
+			var zero float64
+			qs.Status = ScoreStatusUnanswered
+			qs.PointsAwarded = &zero
+		case qs.Type == QuestionTypeShortAnswer:
+			qs.PointsAwarded = q.awarded
+			if q.awarded == nil {
+				qs.Status = ScoreStatusPending
+			} else if *q.awarded >= float64(qs.MaxPoints) {
+				qs.Status = ScoreStatusCorrect
+			} else if *q.awarded > 0 {
+				qs.Status = ScoreStatusPartial
+			} else {
+				qs.Status = ScoreStatusIncorrect
+			}
+		default:
+			points, status := gradeObjective(qs.Type, q.strategy, qs.MaxPoints, q.answer, options[qs.QuestionID])
+			qs.Status = status
+			qs.PointsAwarded = &points
+			graded[*q.AnswerID] = points
+		}
+
+		score.MaxScore += qs.MaxPoints
+		if qs.PointsAwarded != nil {
+			score.Score += *qs.PointsAwarded
+		} else {
+			score.Pending++
+		}
+		score.Questions = append(score.Questions, qs)
+	}
+
+	for answerID, points := range graded {
+		if _, err := tx.Exec(ctx, "UPDATE submission_answers SET points_awarded = $1 WHERE id = $2", points, answerID); err != nil {
+			return nil, err
+		}
+	}
+	_, err = tx.Exec(ctx,
+		"UPDATE submissions SET score = $1, max_score = $2, scored_at = now() WHERE id = $3",
+		score.Score, score.MaxScore, submissionID)
+	if err != nil {
+		return nil, err
+	}
+	return score, nil
+}
+
+// gradeObjective scores an answer that has a right option or set of options.
+// multiple_choice and true_false answers earn everything or nothing. A
+// multi_select answer earns everything when it picks exactly the correct
+// options; with the partial strategy anything short of that earns the share
+// of correct options picked, less one share per wrong pick, never below zero.
+func gradeObjective(questionType, strategy string, maxPoints int, answer []byte, options []QuestionOption) (float64, string) {
+	if questionType != QuestionTypeMultiSelect {
+		var id int
+		if json.Unmarshal(answer, &id) == nil && isCorrectOption(options, id) {
+			return float64(maxPoints), ScoreStatusCorrect
+		}
+		return 0, ScoreStatusIncorrect
+	}
+
+	var picked []int
+	if json.Unmarshal(answer, &picked) != nil {
+		return 0, ScoreStatusIncorrect
+	}
+	var correct, hits, misses int
+	for _, o := range options {
+		right := o.IsCorrect != nil && *o.IsCorrect
+		if right {
+			correct++
+		}
+		if slices.Contains(picked, o.ID) {
+			if right {
+				hits++
+			} else {
+				misses++
+			}
+		}
+	}
+	if hits == correct && misses == 0 {
+		return float64(maxPoints), ScoreStatusCorrect
+	}
+	if strategy != ScoringPartial || correct == 0 || hits <= misses {
+		return 0, ScoreStatusIncorrect
+	}
+	share := float64(hits-misses) / float64(correct)
+	// Stored as numeric(10, 2), so round here to report what's stored.
+	return math.Round(share*float64(maxPoints)*100) / 100, ScoreStatusPartial
+}
+
+func isCorrectOption(options []QuestionOption, id int) bool {
+	for _, o := range options {
+		if o.ID == id {
+			return o.IsCorrect != nil && *o.IsCorrect
+		}
+	}
+	return false
+}
+
+// GetSubmissionScore godoc
+// @Summary      Score a submission
+// @Description  Re-grades the objective questions against the current answer key and returns the total with a per-question breakdown. multi_select questions with the partial scoring strategy can earn a fraction of their points. Short answers stay pending until graded. Visible to the submitter and to teachers and admins.
+// @Tags         submissions
+// @Produce      json
+// @Param        id   path      int  true  "Submission ID"
+// @Success      200  {object}  SubmissionScore
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/submissions/{id}/score [get]
+func GetSubmissionScore(c *gin.Context) {
+	if dbPool == nil {
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+		return
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid submission id")
+		return
+	}
+
+	claims, ok := currentClaims(c)
+	if !ok {
+		RespondError(c, http.StatusUnauthorized, ErrCodeUnauthorized, "Authentication required")
+		return
+	}
+
+	var score *SubmissionScore
+	err = WithTx(ctx, func(tx pgx.Tx) error {
+		var ownerID int
+		err := tx.QueryRow(ctx, "SELECT user_id FROM submissions WHERE id = $1 AND org_id = $2", id, claims.OrgID).Scan(&ownerID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return newClientError(http.StatusNotFound, ErrCodeNotFound, "Submission not found")
+		}
+		if err != nil {
+			return err
+		}
+		if ownerID != claims.UserID && claims.Role != RoleTeacher && claims.Role != RoleAdmin {
+			return newClientError(http.StatusForbidden, ErrCodeForbidden, "Insufficient permissions")
+		}
+
+		score, err = scoreSubmission(ctx, tx, id)
+		return err
+	})
+	if respondClientError(c, err) {
+		return
+	}
+	if err != nil {
+		requestLogger(c).Error("Failed to score submission", "submission_id", id, "error", err)
+		respondQueryError(c, err, "Failed to score submission")
+		return
+	}
+
+	c.JSON(http.StatusOK, score)
+}
@@ -0,0 +1,71 @@
+package main
+
+import "testing"
+
+// testOptions builds options 1..n, the ids in correct marked correct.
+func testOptions(n int, correct ...int) []QuestionOption {
+	options := make([]QuestionOption, n)
+	for i := range options {
+		right := false
+		for _, id := range correct {
+			right = right || id == i+1
+		}
+		options[i] = QuestionOption{ID: i + 1, IsCorrect: &right}
+	}
+	return options
+}
+
+func TestGradeObjectiveMultiSelect(t *testing.T) {
+	tests := []struct {
+		name       string
+		strategy   string
+		answer     string
+		options    []QuestionOption
+		wantPoints float64
+		wantStatus string
+	}{
+		{"exactly the correct ones", ScoringPartial, "[1, 3]", testOptions(4, 1, 3), 10, ScoreStatusCorrect},
+		{"correct ones in another order", ScoringAllOrNothing, "[3, 1]", testOptions(4, 1, 3), 10, ScoreStatusCorrect},
+		{"all selected", ScoringPartial, "[1, 2, 3, 4]", testOptions(4, 1, 3), 0, ScoreStatusIncorrect},
+		{"all selected, all correct", ScoringPartial, "[1, 2, 3]", testOptions(3, 1, 2, 3), 10, ScoreStatusCorrect},
+		{"all selected, one wrong", ScoringPartial, "[1, 2, 3, 4]", testOptions(4, 1, 2, 3), 6.67, ScoreStatusPartial},
+		{"none selected", ScoringPartial, "[]", testOptions(4, 1, 3), 0, ScoreStatusIncorrect},
+		{"none selected, all or nothing", ScoringAllOrNothing, "[]", testOptions(4, 1, 3), 0, ScoreStatusIncorrect},
+		{"some of the correct ones", ScoringPartial, "[1]", testOptions(4, 1, 3), 5, ScoreStatusPartial},
+		{"partly right, all or nothing", ScoringAllOrNothing, "[1]", testOptions(4, 1, 3), 0, ScoreStatusIncorrect},
+		{"hits offset by misses", ScoringPartial, "[1, 2, 3]", testOptions(5, 1, 2, 5), 3.33, ScoreStatusPartial},
+		{"as many misses as hits", ScoringPartial, "[1, 2]", testOptions(4, 1, 3), 0, ScoreStatusIncorrect},
+		{"more misses than hits clamps to zero", ScoringPartial, "[1, 2, 4]", testOptions(4, 1, 3), 0, ScoreStatusIncorrect},
+		{"only wrong ones", ScoringPartial, "[2, 4]", testOptions(4, 1, 3), 0, ScoreStatusIncorrect},
+		{"unknown ids are ignored", ScoringPartial, "[1, 3, 99]", testOptions(4, 1, 3), 10, ScoreStatusCorrect},
+		{"not an array", ScoringPartial, "1", testOptions(4, 1, 3), 0, ScoreStatusIncorrect},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			points, status := gradeObjective(QuestionTypeMultiSelect, tt.strategy, 10, []byte(tt.answer), tt.options)
+			if points != tt.wantPoints || status != tt.wantStatus {
+				t.Errorf("gradeObjective(%s) = %v, %s; want %v, %s", tt.answer, points, status, tt.wantPoints, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestGradeObjectiveSingleAnswer(t *testing.T) {
+	options := testOptions(3, 2)
+	tests := []struct {
+		answer     string
+		wantPoints float64
+		wantStatus string
+	}{
+		{"2", 5, ScoreStatusCorrect},
+		{"1", 0, ScoreStatusIncorrect},
+		{"99", 0, ScoreStatusIncorrect},
+		{`"2"`, 0, ScoreStatusIncorrect},
+	}
+	for _, tt := range tests {
+		points, status := gradeObjective(QuestionTypeMultipleChoice, ScoringPartial, 5, []byte(tt.answer), options)
+		if points != tt.wantPoints || status != tt.wantStatus {
+			t.Errorf("gradeObjective(%s) = %v, %s; want %v, %s", tt.answer, points, status, tt.wantPoints, tt.wantStatus)
+		}
+	}
+}
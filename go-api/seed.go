@@ -0,0 +1,325 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// seedPassword is the password of every seeded account.
+const seedPassword = "password123"
+
+var seedMaxAttempts = 3
+
+// seedOrg is the organization seeded data goes into: the default one from
+// migration 0025.
+const seedOrg = 1
+
+// devDatabaseHosts are the hosts seed runs against without --force: the
+// loopback addresses and the compose service name.
+var devDatabaseHosts = []string{"localhost", "127.0.0.1", "::1", "postgres", "db", "host.docker.internal"}
+
+type seedUser struct {
+	username string
+	role     string
+}
+
+type seedOption struct {
+	text    string
+	correct bool
+}
+
+type seedQuestion struct {
+	prompt   string
+	kind     string
+	strategy string
+	points   int
+	tags     []string
+	options  []seedOption
+}
+
+type seedExam struct {
+	title        string
+	description  string
+	minutes      int
+	allowRetakes bool
+	maxAttempts  *int
+	shuffle      bool
+	questions    []seedQuestion
+}
+
+// seedAnswer answers one question: options are indexes into its options (the
+// first one for single-choice questions), text is a short answer.
+type seedAnswer struct {
+	options []int
+	text    string
+}
+
+type seedSubmission struct {
+	exam    string
+	user    string
+	answers []seedAnswer
+}
+
+var seedUsers = []seedUser{
+	{"admin", RoleAdmin},
+	{"teacher", RoleTeacher},
+	{"alice", RoleStudent},
+	{"bob", RoleStudent},
+	{"carol", RoleStudent},
+}
+
+var seedExams = []seedExam{
+	{
+		title:        "Go basics",
+		description:  "Goroutines, maps, slices and channels.",
+		minutes:      30,
+		allowRetakes: true,
+		maxAttempts:  &seedMaxAttempts,
+		questions: []seedQuestion{
+			{prompt: "Which keyword starts a goroutine?", kind: QuestionTypeMultipleChoice, points: 1, tags: []string{"go", "concurrency"},
+				options: []seedOption{{"go", true}, {"defer", false}, {"chan", false}, {"select", false}}},
+			{prompt: "Reading from a nil map panics.", kind: QuestionTypeTrueFalse, points: 1, tags: []string{"go"},
+				options: []seedOption{{"True", false}, {"False", true}}},
+			{prompt: "Which of these are reference types?", kind: QuestionTypeMultiSelect, strategy: ScoringPartial, points: 2, tags: []string{"go"},
+				options: []seedOption{{"slice", true}, {"map", true}, {"array", false}, {"struct", false}}},
+			{prompt: "In one sentence, what is a channel for?", kind: QuestionTypeShortAnswer, points: 2, tags: []string{"go", "concurrency"}},
+		},
+	},
+	{
+		title:       "SQL fundamentals",
+		description: "Filtering, grouping and keys.",
+		minutes:     20,
+		shuffle:     true,
+		questions: []seedQuestion{
+			{prompt: "Which clause filters groups?", kind: QuestionTypeMultipleChoice, points: 1, tags: []string{"sql"},
+				options: []seedOption{{"WHERE", false}, {"HAVING", true}, {"ORDER BY", false}, {"LIMIT", false}}},
+			{prompt: "A primary key column can hold NULL.", kind: QuestionTypeTrueFalse, points: 1, tags: []string{"sql"},
+				options: []seedOption{{"True", false}, {"False", true}}},
+			{prompt: "Which of these are aggregate functions?", kind: QuestionTypeMultiSelect, points: 2, tags: []string{"sql"},
+				options: []seedOption{{"COUNT", true}, {"SUM", true}, {"UPPER", false}, {"AVG", true}}},
+		},
+	},
+}
+
+var seedSubmissions = []seedSubmission{
+	{exam: "Go basics", user: "alice", answers: []seedAnswer{
+		{options: []int{0}}, {options: []int{1}}, {options: []int{0, 1}}, {text: "Passing values between goroutines."}}},
+	{exam: "Go basics", user: "bob", answers: []seedAnswer{
+		{options: []int{1}}, {options: []int{1}}, {options: []int{0, 2}}, {text: "Locking."}}},
+	{exam: "SQL fundamentals", user: "alice", answers: []seedAnswer{
+		{options: []int{1}}, {options: []int{1}}, {options: []int{0, 1, 3}}}},
+	{exam: "SQL fundamentals", user: "carol", answers: []seedAnswer{
+		{options: []int{0}}, {options: []int{0}}, {options: []int{0, 2}}}},
+}
+
+// runSeedCommand implements "seed [--force]": it fills the database with a
+// fixed set of users, exams and submissions for local testing and demos.
+// Refusing a database that doesn't look local unless forced keeps it from
+// ever writing demo accounts into production.
+func runSeedCommand(cfg *Config, args []string) error {
+	force := false
+	for _, arg := range args {
+		if arg != "--force" && arg != "-force" {
+			return fmt.Errorf("usage: seed [--force]")
+		}
+		force = true
+	}
+	if reason, ok := productionLooking(cfg.DatabaseURL); ok && !force {
+		return fmt.Errorf("refusing to seed: %s; pass --force if this really is a development database", reason)
+	}
+	return Seed(context.Background())
+}
+
+// productionLooking reports why databaseURL doesn't look like a development
+// database, if it doesn't.
+func productionLooking(databaseURL string) (reason string, ok bool) {
+	config, err := pgx.ParseConfig(databaseURL)
+	if err != nil {
+		return "DATABASE_URL can't be parsed", true
+	}
+	if strings.Contains(strings.ToLower(config.Database), "prod") {
+		return fmt.Sprintf("database name %q mentions prod", config.Database), true
+	}
+	host := strings.ToLower(config.Host)
+	// A Unix socket is a database on this machine.
+	if strings.HasPrefix(host, "/") || slices.Contains(devDatabaseHosts, host) {
+		return "", false
+	}
+	return fmt.Sprintf("database host %q is not a local one", config.Host), true
+}
+
+// Seed inserts the development data in one transaction. Anything already
+// there, matched by username or by exam title, is left alone, as are
+// submissions for a user who has already taken the exam, so running it again
+// changes nothing.
+func Seed(ctx context.Context) error {
+	hash, err := hashPassword(seedPassword)
+	if err != nil {
+		return err
+	}
+
+	var usersCreated, examsCreated, submissionsCreated int
+	err = WithTx(ctx, func(tx pgx.Tx) error {
+		userIDs := map[string]int{}
+		for _, u := range seedUsers {
+			tag, err := tx.Exec(ctx, `
+				INSERT INTO up_users (username, email, password, role, org_id, provider, created_at, updated_at)
+				VALUES ($1, $2, $3, $4, $5, 'local', now(), now()) ON CONFLICT DO NOTHING`,
+				u.username, u.username+"@example.com", hash, u.role, seedOrg)
+			if err != nil {
+				return fmt.Errorf("user %s: %w", u.username, err)
+			}
+			usersCreated += int(tag.RowsAffected())
+
+			var id int
+			if err := tx.QueryRow(ctx, "SELECT id FROM up_users WHERE username = $1", u.username).Scan(&id); err != nil {
+				return fmt.Errorf("user %s: %w", u.username, err)
+			}
+			userIDs[u.username] = id
+		}
+
+		examIDs := map[string]int{}
+		for _, e := range seedExams {
+			id, created, err := seedExamRows(ctx, tx, e, userIDs["teacher"])
+			if err != nil {
+				return fmt.Errorf("exam %q: %w", e.title, err)
+			}
+			examIDs[e.title] = id
+			if created {
+				examsCreated++
+			}
+		}
+
+		for _, s := range seedSubmissions {
+			created, err := seedSubmissionRows(ctx, tx, examIDs[s.exam], userIDs[s.user], s.answers)
+			if err != nil {
+				return fmt.Errorf("submission of %q by %s: %w", s.exam, s.user, err)
+			}
+			if created {
+				submissionsCreated++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Seeded development data", "users", usersCreated, "exams", examsCreated,
+		"submissions", submissionsCreated, "password", seedPassword)
+	return nil
+}
+
+// seedExamRows finds the exam by title, or creates it with its questions and
+// options.
+func seedExamRows(ctx context.Context, tx pgx.Tx, e seedExam, createdBy int) (id int, created bool, err error) {
+	err = tx.QueryRow(ctx, "SELECT id FROM exams WHERE org_id = $1 AND title = $2", seedOrg, e.title).Scan(&id)
+	if err == nil {
+		return id, false, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return 0, false, err
+	}
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO exams (title, description, duration_minutes, allow_retakes, max_attempts,
+			shuffle_questions, shuffle_options, created_by, org_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $6, $7, $8) RETURNING id`,
+		e.title, e.description, e.minutes, e.allowRetakes, e.maxAttempts, e.shuffle, createdBy, seedOrg).Scan(&id)
+	if err != nil {
+		return 0, false, err
+	}
+
+	for i, q := range e.questions {
+		strategy := q.strategy
+		if strategy == "" {
+			strategy = ScoringAllOrNothing
+		}
+		var questionID int
+		err := tx.QueryRow(ctx, `
+			INSERT INTO exam_questions (exam_id, org_id, prompt, type, scoring_strategy, points, position, tags)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`,
+			id, seedOrg, q.prompt, q.kind, strategy, q.points, i+1, q.tags).Scan(&questionID)
+		if err != nil {
+			return 0, false, err
+		}
+		for j, o := range q.options {
+			_, err := tx.Exec(ctx,
+				"INSERT INTO question_options (question_id, text, is_correct, position) VALUES ($1, $2, $3, $4)",
+				questionID, o.text, o.correct, j)
+			if err != nil {
+				return 0, false, err
+			}
+		}
+	}
+	return id, true, nil
+}
+
+// seedSubmissionRows submits answers for userID unless they have already
+// submitted the exam. It goes through insertSubmission, so the submission is
+// scored and audited like a real one.
+func seedSubmissionRows(ctx context.Context, tx pgx.Tx, examID, userID int, answers []seedAnswer) (bool, error) {
+	attempts, err := countAttempts(ctx, tx, examID, userID)
+	if err != nil || attempts > 0 {
+		return false, err
+	}
+
+	rows, err := tx.Query(ctx, "SELECT id, type FROM exam_questions WHERE exam_id = $1 ORDER BY position, id", examID)
+	if err != nil {
+		return false, err
+	}
+	type question struct {
+		id   int
+		kind string
+	}
+	questions, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (question, error) {
+		var q question
+		return q, row.Scan(&q.id, &q.kind)
+	})
+	if err != nil {
+		return false, err
+	}
+	ids := make([]int, len(questions))
+	for i, q := range questions {
+		ids[i] = q.id
+	}
+	options, err := loadOptions(ctx, tx, ids, false)
+	if err != nil {
+		return false, err
+	}
+
+	inputs := make([]SubmissionAnswerInput, 0, len(answers))
+	for i, a := range answers {
+		q := questions[i]
+		var value any
+		switch q.kind {
+		case QuestionTypeShortAnswer:
+			value = a.text
+		case QuestionTypeMultiSelect:
+			picked := make([]int, len(a.options))
+			for j, o := range a.options {
+				picked[j] = options[q.id][o].ID
+			}
+			value = picked
+		default:
+			value = options[q.id][a.options[0]].ID
+		}
+		answer, err := json.Marshal(value)
+		if err != nil {
+			return false, err
+		}
+		inputs = append(inputs, SubmissionAnswerInput{QuestionID: q.id, Answer: answer})
+	}
+
+	if err := validateAnswers(ctx, tx, examID, inputs); err != nil {
+		return false, err
+	}
+	_, err = insertSubmission(ctx, tx, seedOrg, examID, userID, inputs)
+	return err == nil, err
+}
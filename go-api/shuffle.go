@@ -0,0 +1,33 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math/rand/v2"
+)
+
+// attemptSeed is the seed for one candidate's question order on one attempt
+// at an exam. Nothing is stored: the same inputs always give the same order,
+// so reloading mid-attempt changes nothing, while the attempt number moves on
+// after each submission and a retake gets a fresh order.
+func attemptSeed(examID, userID, attempt int) [32]byte {
+	return sha256.Sum256(fmt.Appendf(nil, "question-order:%d:%d:%d", examID, userID, attempt))
+}
+
+// shuffleQuestions reorders questions from seed when questions is set, and
+// the options inside each question when options is set. Only the order
+// changes; ids are untouched, so answers still refer to the canonical
+// questions and options. The input must be in canonical order for the
+// result to be reproducible.
+func shuffleQuestions(qs []Question, seed [32]byte, questions, options bool) {
+	rng := rand.New(rand.NewChaCha8(seed))
+	if questions {
+		rng.Shuffle(len(qs), func(i, j int) { qs[i], qs[j] = qs[j], qs[i] })
+	}
+	if !options {
+		return
+	}
+	for _, q := range qs {
+		rng.Shuffle(len(q.Options), func(i, j int) { q.Options[i], q.Options[j] = q.Options[j], q.Options[i] })
+	}
+}
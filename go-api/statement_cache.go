@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// defaultStatementCacheSize matches pgx's own default.
+const defaultStatementCacheSize = 512
+
+// queryExecModes are the DB_QUERY_EXEC_MODE values. cache_statement, the
+// default, prepares each distinct query once per connection and reuses the
+// plan after; the others suit poolers such as PgBouncer in transaction mode,
+// which can't keep a prepared statement on one server connection.
+var queryExecModes = map[string]pgx.QueryExecMode{
+	"cache_statement": pgx.QueryExecModeCacheStatement,
+	"cache_describe":  pgx.QueryExecModeCacheDescribe,
+	"describe_exec":   pgx.QueryExecModeDescribeExec,
+	"exec":            pgx.QueryExecModeExec,
+	"simple_protocol": pgx.QueryExecModeSimpleProtocol,
+}
+
+// statementCacheTracer counts queries with arguments, which are the ones a
+// statement cache serves, and the prepares that happen when it misses, so the
+// debug endpoint can report how often a query found its statement cached.
+type statementCacheTracer struct {
+	mode     string
+	capacity int
+	lookups  atomic.Int64
+	prepares atomic.Int64
+}
+
+var statementCache statementCacheTracer
+
+func (t *statementCacheTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	// pgx sends queries without arguments over the simple protocol.
+	if len(data.Args) > 0 {
+		t.lookups.Add(1)
+	}
+	return ctx
+}
+
+func (*statementCacheTracer) TraceQueryEnd(context.Context, *pgx.Conn, pgx.TraceQueryEndData) {}
+
+func (t *statementCacheTracer) TracePrepareStart(ctx context.Context, _ *pgx.Conn, _ pgx.TracePrepareStartData) context.Context {
+	t.prepares.Add(1)
+	return ctx
+}
+
+func (*statementCacheTracer) TracePrepareEnd(context.Context, *pgx.Conn, pgx.TracePrepareEndData) {}
+
+// stats snapshots the counters. The hit ratio is only given for the caching
+// modes; the others never reuse a statement.
+func (t *statementCacheTracer) stats() StatementCacheStats {
+	s := StatementCacheStats{
+		Mode:     t.mode,
+		Capacity: t.capacity,
+		Lookups:  t.lookups.Load(),
+		Prepares: t.prepares.Load(),
+	}
+	if (t.mode == "cache_statement" || t.mode == "cache_describe") && s.Lookups > 0 {
+		ratio := float64(max(s.Lookups-s.Prepares, 0)) / float64(s.Lookups)
+		s.HitRatio = &ratio
+	}
+	return s
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func TestStatementCacheStats(t *testing.T) {
+	tracer := &statementCacheTracer{mode: "cache_statement", capacity: defaultStatementCacheSize}
+	ctx := context.Background()
+	for range 4 {
+		tracer.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{SQL: "SELECT $1", Args: []any{1}})
+	}
+	tracer.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{SQL: "SELECT 1"})
+	tracer.TracePrepareStart(ctx, nil, pgx.TracePrepareStartData{SQL: "SELECT $1"})
+
+	s := tracer.stats()
+	if s.Lookups != 4 || s.Prepares != 1 {
+		t.Fatalf("lookups %d, prepares %d; want 4 and 1, not counting the query without arguments", s.Lookups, s.Prepares)
+	}
+	if s.HitRatio == nil || *s.HitRatio != 0.75 {
+		t.Errorf("hit ratio %v, want 0.75", s.HitRatio)
+	}
+
+	uncached := &statementCacheTracer{mode: "simple_protocol"}
+	uncached.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{SQL: "SELECT $1", Args: []any{1}})
+	if s := uncached.stats(); s.HitRatio != nil {
+		t.Errorf("hit ratio %v for simple_protocol, want none", *s.HitRatio)
+	}
+}
+
+// BenchmarkUserQuery runs the GetUsers query in the default cache_statement
+// mode and in simple_protocol, which is what running behind a transaction
+// pooler costs.
+func BenchmarkUserQuery(b *testing.B) {
+	ctx := testDB(b)
+	org := testOrg(b, ctx)
+	for range 50 {
+		testUser(b, ctx, org, RoleStudent, "")
+	}
+	query := "SELECT " + userColumns + " FROM up_users WHERE org_id = $1 AND deleted_at IS NULL ORDER BY id LIMIT $2"
+
+	for _, mode := range []string{"cache_statement", "simple_protocol"} {
+		b.Run(mode, func(b *testing.B) {
+			config := dbPool.Config()
+			config.ConnConfig.DefaultQueryExecMode = queryExecModes[mode]
+			config.ConnConfig.Tracer = nil
+			pool, err := pgxpool.NewWithConfig(ctx, config)
+			if err != nil {
+				b.Fatalf("open %s pool: %v", mode, err)
+			}
+			defer pool.Close()
+
+			for b.Loop() {
+				rows, err := pool.Query(ctx, query, org, 20)
+				if err != nil {
+					b.Fatalf("query: %v", err)
+				}
+				n := 0
+				for rows.Next() {
+					var user User
+					if err := scanUser(rows, &user); err != nil {
+						b.Fatalf("scan: %v", err)
+					}
+					n++
+				}
+				if err := rows.Err(); err != nil {
+					b.Fatalf("rows: %v", err)
+				}
+				if n != 20 {
+					b.Fatalf("got %d users, want 20", n)
+				}
+			}
+		})
+	}
+}
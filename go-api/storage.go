@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+const (
+	storageBackendLocal = "local"
+	storageBackendS3    = "s3"
+
+	// s3PartSize is the multipart chunk buffered per upload when the object
+	// size isn't known up front (the minimum S3 allows).
+	s3PartSize = 5 << 20
+)
+
+// blobStore persists uploaded files. Put streams r to key and returns the URL
+// clients should use to fetch it; if r fails midway nothing is left behind.
+// Ping reports whether the backend can currently take uploads.
+type blobStore interface {
+	Put(ctx context.Context, key, contentType string, r io.Reader) (string, error)
+	Delete(ctx context.Context, key string) error
+	Ping(ctx context.Context) error
+}
+
+// NewBlobStore builds the backend selected by ATTACHMENT_STORAGE.
+func NewBlobStore(cfg *Config) (blobStore, error) {
+	switch cfg.AttachmentStorage {
+	case storageBackendLocal:
+		if err := os.MkdirAll(cfg.AttachmentDir, 0o755); err != nil {
+			return nil, fmt.Errorf("create attachment directory: %w", err)
+		}
+		return &localStore{dir: cfg.AttachmentDir, baseURL: strings.TrimRight(cfg.AttachmentBaseURL, "/")}, nil
+	case storageBackendS3:
+		client, err := minio.New(cfg.S3Endpoint, &minio.Options{
+			Creds:  credentials.NewStaticV4(cfg.S3AccessKey, cfg.S3SecretKey, ""),
+			Secure: cfg.S3UseSSL,
+			Region: cfg.S3Region,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("create S3 client: %w", err)
+		}
+		publicURL := cfg.S3PublicURL
+		if publicURL == "" {
+			scheme := "http"
+			if cfg.S3UseSSL {
+				scheme = "https"
+			}
+			publicURL = scheme + "://" + cfg.S3Endpoint + "/" + cfg.S3Bucket
+		}
+		return &s3Store{client: client, bucket: cfg.S3Bucket, publicURL: strings.TrimRight(publicURL, "/")}, nil
+	default:
+		return nil, fmt.Errorf("unknown attachment storage %q", cfg.AttachmentStorage)
+	}
+}
+
+// localStore writes files under dir, which the router serves at baseURL.
+type localStore struct {
+	dir     string
+	baseURL string
+}
+
+func (s *localStore) Put(ctx context.Context, key, contentType string, r io.Reader) (string, error) {
+	dest := filepath.Join(s.dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return "", err
+	}
+
+	// Write to a temp file and rename so readers never see a partial file.
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".upload-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		return "", err
+	}
+	return s.baseURL + "/" + (&url.URL{Path: key}).EscapedPath(), nil
+}
+
+func (s *localStore) Delete(ctx context.Context, key string) error {
+	err := os.Remove(filepath.Join(s.dir, filepath.FromSlash(key)))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// Ping creates and removes a scratch file, since a directory that exists but
+// has gone read-only would fail every upload.
+func (s *localStore) Ping(ctx context.Context) error {
+	f, err := os.CreateTemp(s.dir, ".ping-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+type s3Store struct {
+	client    *minio.Client
+	bucket    string
+	publicURL string
+}
+
+func (s *s3Store) Put(ctx context.Context, key, contentType string, r io.Reader) (string, error) {
+	// Size -1 makes minio stream a multipart upload, aborting it if r fails.
+	_, err := s.client.PutObject(ctx, s.bucket, key, r, -1, minio.PutObjectOptions{
+		ContentType: contentType,
+		PartSize:    s3PartSize,
+	})
+	if err != nil {
+		return "", err
+	}
+	return s.publicURL + "/" + path.Clean(key), nil
+}
+
+func (s *s3Store) Delete(ctx context.Context, key string) error {
+	return s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{})
+}
+
+func (s *s3Store) Ping(ctx context.Context) error {
+	ok, err := s.client.BucketExists(ctx, s.bucket)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("bucket %q does not exist", s.bucket)
+	}
+	return nil
+}
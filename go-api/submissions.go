@@ -0,0 +1,359 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// submitGrace is how long after a timed session's deadline a manual submit is
+// still accepted, to absorb network latency on the final request.
+const submitGrace = 5 * time.Second
+
+// CreateSubmission godoc
+// @Summary      Submit answers for an exam
+// @Description  Records every answer in one transaction and grades the objective questions. multiple_choice and true_false answers are an option id; multi_select answers are an array of option ids; short_answer answers are a string of at most 10000 characters, as described by the JSON Schemas in answer_schemas/. A malformed answer gets 400 VALIDATION_FAILED with details naming it, e.g. answers[2].answer[1]. A second submission is rejected unless the exam allows retakes, one past the exam's max_attempts gets ATTEMPTS_EXHAUSTED, and a late submission is rejected for a timed session that has already run out. With an Idempotency-Key header, a repeat of a successful request within the key's lifetime returns the original submission with Idempotent-Replayed: true; reusing the key for different answers gets 422 IDEMPOTENCY_KEY_REUSED. Failed requests aren't remembered, so they can be retried with the same key.
+// @Tags         submissions
+// @Accept       json
+// @Produce      json
+// @Param        id               path      int                      true   "Exam ID"
+// @Param        Idempotency-Key  header    string                   false  "Client-chosen key; a retry with the same key returns the first response instead of submitting again"
+// @Param        submission       body      CreateSubmissionRequest  true   "Answers"
+// @Success      201              {object}  Submission
+// @Failure      400              {object}  ErrorResponse
+// @Failure      401              {object}  ErrorResponse
+// @Failure      404              {object}  ErrorResponse
+// @Failure      409              {object}  ErrorResponse
+// @Failure      422              {object}  ErrorResponse
+// @Failure      413              {object}  ErrorResponse
+// @Failure      500              {object}  ErrorResponse
+// @Failure      503              {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/exams/{id}/submissions [post]
+func CreateSubmission(idempotencyTTL time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if dbPool == nil {
+			RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+			return
+		}
+
+		ctx, cancel := queryContext(c)
+		defer cancel()
+
+		examID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid exam id")
+			return
+		}
+
+		claims, ok := currentClaims(c)
+		if !ok {
+			RespondError(c, http.StatusUnauthorized, ErrCodeUnauthorized, "Authentication required")
+			return
+		}
+
+		key := c.GetHeader(idempotencyKeyHeader)
+		if len(key) > maxIdempotencyKeyLength {
+			RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest,
+				idempotencyKeyHeader+" must be at most "+strconv.Itoa(maxIdempotencyKeyLength)+" characters")
+			return
+		}
+
+		var req CreateSubmissionRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondBindingError(c, err)
+			return
+		}
+
+		var fingerprint string
+		if key != "" {
+			if fingerprint, err = submissionFingerprint(examID, req.Answers); err != nil {
+				requestLogger(c).Error("Failed to fingerprint submission", "error", err)
+				RespondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to save submission")
+				return
+			}
+		}
+
+		var submission *Submission
+		replayed := false
+		err = WithTx(ctx, func(tx pgx.Tx) error {
+			if key != "" {
+				prior, err := claimIdempotencyKey(ctx, tx, claims.UserID, key, examID, fingerprint, idempotencyTTL)
+				if err != nil {
+					return err
+				}
+				if prior != 0 {
+					replayed = true
+					submission, err = loadSubmission(ctx, tx, prior)
+					return err
+				}
+			}
+
+			var err error
+			submission, err = submitExam(ctx, tx, claims.OrgID, examID, claims.UserID, req.Answers)
+			if err != nil || key == "" {
+				return err
+			}
+			return bindIdempotencyKey(ctx, tx, claims.UserID, key, submission.ID)
+		})
+		if respondClientError(c, err) {
+			return
+		}
+		if err != nil {
+			requestLogger(c).Error("Failed to create submission", "exam_id", examID, "user_id", claims.UserID, "error", err)
+			respondQueryError(c, err, "Failed to save submission")
+			return
+		}
+
+		if replayed {
+			c.Header(idempotencyReplayedHeader, "true")
+		}
+		c.JSON(http.StatusCreated, submission)
+	}
+}
+
+// submitExam runs the checks a candidate's own submission must pass and then
+// records it. The exam must exist, the candidate must have an attempt left,
+// and a timed session, if one is open, must not have run out.
+func submitExam(ctx context.Context, tx pgx.Tx, org, examID, userID int, answers []SubmissionAnswerInput) (*Submission, error) {
+	if err := lockSubmitter(ctx, tx, examID, userID); err != nil {
+		return nil, err
+	}
+
+	var allowRetakes bool
+	var maxAttempts *int
+	err := tx.QueryRow(ctx, "SELECT allow_retakes, max_attempts FROM exams WHERE id = $1 AND org_id = $2 FOR SHARE", examID, org).
+		Scan(&allowRetakes, &maxAttempts)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, newClientError(http.StatusNotFound, ErrCodeNotFound, "Exam not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkAttempts(ctx, tx, examID, userID, allowRetakes, maxAttempts); err != nil {
+		return nil, err
+	}
+
+	var expired bool
+	err = tx.QueryRow(ctx, `
+		SELECT s.started_at + make_interval(mins => e.duration_minutes) + make_interval(secs => $3) < now()
+		FROM exam_sessions s JOIN exams e ON e.id = s.exam_id
+		WHERE s.exam_id = $1 AND s.user_id = $2 AND s.closed_at IS NULL`,
+		examID, userID, submitGrace.Seconds()).Scan(&expired)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+	if expired {
+		return nil, newClientError(http.StatusConflict, ErrCodeTimeExpired, "Time is up for this exam session")
+	}
+
+	if err := validateAnswers(ctx, tx, examID, answers); err != nil {
+		return nil, err
+	}
+	return insertSubmission(ctx, tx, org, examID, userID, answers)
+}
+
+// attemptsAllowed is how many submissions an exam accepts from each
+// candidate, or 0 for no limit. Without retakes that's always one.
+func attemptsAllowed(allowRetakes bool, maxAttempts *int) int {
+	if !allowRetakes {
+		return 1
+	}
+	if maxAttempts == nil {
+		return 0
+	}
+	return *maxAttempts
+}
+
+// countAttempts is how many times userID has submitted the exam. Only
+// submissions count; a live session abandoned before submitting doesn't.
+func countAttempts(ctx context.Context, q querier, examID, userID int) (int, error) {
+	var n int
+	err := q.QueryRow(ctx,
+		"SELECT COUNT(*) FROM submissions WHERE exam_id = $1 AND user_id = $2", examID, userID).Scan(&n)
+	return n, err
+}
+
+// checkAttempts refuses another attempt once userID has used up the exam's
+// allowance. Exams without retakes keep reporting ALREADY_SUBMITTED, which
+// clients already handle.
+func checkAttempts(ctx context.Context, q querier, examID, userID int, allowRetakes bool, maxAttempts *int) error {
+	limit := attemptsAllowed(allowRetakes, maxAttempts)
+	if limit == 0 {
+		return nil
+	}
+	used, err := countAttempts(ctx, q, examID, userID)
+	if err != nil {
+		return err
+	}
+	if used < limit {
+		return nil
+	}
+	if !allowRetakes {
+		return newClientError(http.StatusConflict, ErrCodeAlreadySubmitted, "Exam already submitted")
+	}
+	return newClientError(http.StatusConflict, ErrCodeAttemptsExhausted, "No attempts left for this exam")
+}
+
+// lockSubmitter serializes submissions per (exam, user) for the rest of the
+// transaction so two concurrent requests can't both pass the retake check.
+func lockSubmitter(ctx context.Context, tx pgx.Tx, examID, userID int) error {
+	_, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1, $2)", examID, userID)
+	return err
+}
+
+// insertSubmission stores already validated answers, scores them, and closes
+// the user's open exam session if there is one.
+func insertSubmission(ctx context.Context, tx pgx.Tx, org, examID, userID int, answers []SubmissionAnswerInput) (*Submission, error) {
+	submission := &Submission{ExamID: examID, UserID: userID}
+	err := tx.QueryRow(ctx,
+		"INSERT INTO submissions (org_id, exam_id, user_id) VALUES ($1, $2, $3) RETURNING id, submitted_at",
+		org, examID, userID).Scan(&submission.ID, &submission.SubmittedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	submission.Answers = make([]SubmissionAnswer, 0, len(answers))
+	for _, a := range answers {
+		_, err := tx.Exec(ctx,
+			"INSERT INTO submission_answers (submission_id, question_id, answer) VALUES ($1, $2, $3)",
+			submission.ID, a.QuestionID, []byte(a.Answer))
+		if err != nil {
+			return nil, err
+		}
+		submission.Answers = append(submission.Answers, SubmissionAnswer(a))
+	}
+
+	score, err := scoreSubmission(ctx, tx, submission.ID)
+	if err != nil {
+		return nil, err
+	}
+	submission.Score, submission.MaxScore = &score.Score, &score.MaxScore
+
+	err = writeAudit(ctx, tx, &userID, auditSubmissionCreate, auditTarget{"submission", submission.ID},
+		map[string]any{"exam_id": examID, "score": score.Score, "max_score": score.MaxScore})
+	if err != nil {
+		return nil, err
+	}
+
+	err = enqueueWebhook(ctx, tx, org, webhookSubmissionScored, gin.H{
+		"submission_id": submission.ID,
+		"exam_id":       examID,
+		"user_id":       userID,
+		"submitted_at":  submission.SubmittedAt,
+		"score":         score.Score,
+		"max_score":     score.MaxScore,
+		"pending":       score.Pending,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(ctx,
+		"UPDATE exam_sessions SET submission_id = $1, closed_at = now() WHERE exam_id = $2 AND user_id = $3 AND closed_at IS NULL",
+		submission.ID, examID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return submission, nil
+}
+
+// validateAnswers checks each answer against the exam's questions: the
+// question must belong to the exam and appear once, its value must match the
+// answer schema for the question's type, and any ids in it must be the
+// question's options. All problems are reported together.
+func validateAnswers(ctx context.Context, q querier, examID int, answers []SubmissionAnswerInput) error {
+	rows, err := q.Query(ctx, "SELECT id, type FROM exam_questions WHERE exam_id = $1", examID)
+	if err != nil {
+		return err
+	}
+	types := map[int]string{}
+	var ids []int
+	for rows.Next() {
+		var id int
+		var questionType string
+		if err := rows.Scan(&id, &questionType); err != nil {
+			rows.Close()
+			return err
+		}
+		types[id] = questionType
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	options, err := loadOptions(ctx, q, ids, false)
+	if err != nil {
+		return err
+	}
+
+	var problems []FieldError
+	seen := map[int]bool{}
+	for i, a := range answers {
+		field := fmt.Sprintf("answers[%d]", i)
+		questionType, ok := types[a.QuestionID]
+		switch {
+		case !ok:
+			problems = append(problems, FieldError{Field: field + ".question_id", Message: "is not a question of this exam"})
+			continue
+		case seen[a.QuestionID]:
+			problems = append(problems, FieldError{Field: field + ".question_id", Message: "is answered more than once"})
+			continue
+		}
+		seen[a.QuestionID] = true
+
+		// The schema settles the shape; what's left is whether the ids
+		// picked are this question's options.
+		if shape := answerShapeErrors(questionType, a.Answer, field+".answer"); len(shape) > 0 {
+			problems = append(problems, shape...)
+			continue
+		}
+
+		switch questionType {
+		case QuestionTypeShortAnswer:
+		case QuestionTypeMultiSelect:
+			var optionIDs []int
+			json.Unmarshal(a.Answer, &optionIDs)
+			for j, id := range optionIDs {
+				if !hasOption(options[a.QuestionID], id) {
+					problems = append(problems, FieldError{Field: fmt.Sprintf("%s.answer[%d]", field, j), Message: "must be the id of one of the question's options"})
+				}
+			}
+		default:
+			var optionID int
+			json.Unmarshal(a.Answer, &optionID)
+			if !hasOption(options[a.QuestionID], optionID) {
+				problems = append(problems, FieldError{Field: field + ".answer", Message: "must be the id of one of the question's options"})
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return &clientError{
+			status:  http.StatusBadRequest,
+			code:    ErrCodeValidationFailed,
+			msg:     "Invalid answers",
+			details: problems,
+		}
+	}
+	return nil
+}
+
+func hasOption(options []QuestionOption, id int) bool {
+	for _, o := range options {
+		if o.ID == id {
+			return true
+		}
+	}
+	return false
+}
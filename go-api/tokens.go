@@ -0,0 +1,44 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/url"
+)
+
+// opaqueTokenBytes is the entropy of emailed tokens (verification links,
+// password resets).
+const opaqueTokenBytes = 32
+
+// newOpaqueToken returns a random URL-safe token and the hash to store for
+// it. Only the hash is persisted, so a database leak doesn't leak live links.
+func newOpaqueToken() (token, hash string, err error) {
+	b := make([]byte, opaqueTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, hashOpaqueToken(token), nil
+}
+
+// hashOpaqueToken is the lookup key for a token. The tokens are random, so a
+// plain SHA-256 is enough; there is nothing to brute-force.
+func hashOpaqueToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// tokenLink appends token to base as the token query parameter, keeping any
+// query base already has.
+func tokenLink(base, token string) string {
+	u, err := url.Parse(base)
+	if err != nil {
+		return base
+	}
+	q := u.Query()
+	q.Set("token", token)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
@@ -0,0 +1,9 @@
+//go:build tools
+
+// Package main pins code generators used via go:generate so their versions
+// are tracked in go.mod.
+package main
+
+import (
+	_ "github.com/swaggo/swag/cmd/swag"
+)
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// sparseUserFields are what ?fields= may ask for on the user reads. Each is
+// also the column it's read from.
+var sparseUserFields = []string{"id", "username", "email", "role"}
+
+// userFields reads ?fields=, a comma-separated subset of sparseUserFields. It
+// returns nil when the param is absent, which means the full User.
+func userFields(c *gin.Context) ([]string, error) {
+	v, ok := c.GetQuery("fields")
+	if !ok {
+		return nil, nil
+	}
+	fields := []string{}
+	for _, f := range strings.Split(v, ",") {
+		f = strings.TrimSpace(f)
+		if !slices.Contains(sparseUserFields, f) {
+			return nil, fmt.Errorf("unknown field %q; fields may be %s", f, strings.Join(sparseUserFields, ", "))
+		}
+		if !slices.Contains(fields, f) {
+			fields = append(fields, f)
+		}
+	}
+	return fields, nil
+}
+
+// userSelectColumns is the SELECT list for fields. id is read even when it
+// isn't asked for, because cursor pages are keyed on it.
+func userSelectColumns(fields []string) string {
+	if fields == nil {
+		return userColumns
+	}
+	cols := []string{"id"}
+	for _, f := range fields {
+		if f != "id" {
+			cols = append(cols, f)
+		}
+	}
+	return strings.Join(cols, ", ")
+}
+
+// scanUserFields scans a row selected with userSelectColumns(fields).
+func scanUserFields(row pgx.Row, user *User, fields []string) error {
+	if fields == nil {
+		return scanUser(row, user)
+	}
+	dest := []any{&user.ID}
+	for _, f := range fields {
+		switch f {
+		case "username":
+			dest = append(dest, &user.Username)
+		case "email":
+			dest = append(dest, &user.Email)
+		case "role":
+			dest = append(dest, &user.Role)
+		}
+	}
+	return row.Scan(dest...)
+}
+
+// sparseUser renders u with only fields, leaving the others out of the JSON
+// altogether rather than sending them empty.
+func sparseUser(u User, fields []string) map[string]any {
+	out := make(map[string]any, len(fields))
+	for _, f := range fields {
+		switch f {
+		case "id":
+			out[f] = u.ID
+		case "username":
+			out[f] = u.Username
+		case "email":
+			out[f] = u.Email
+		case "role":
+			out[f] = u.Role
+		}
+	}
+	return out
+}
+
+// sparseUsers is sparseUser over a page, or the page unchanged when fields is
+// nil.
+func sparseUsers(users []User, fields []string) any {
+	if fields == nil {
+		return users
+	}
+	out := make([]map[string]any, len(users))
+	for i, u := range users {
+		out[i] = sparseUser(u, fields)
+	}
+	return out
+}
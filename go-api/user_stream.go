@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// streamFetchRows is how many users each FETCH pulls from a streaming cursor.
+// Every batch is written and flushed before the next is fetched, so a stream
+// holds one batch in memory however many users it covers.
+const streamFetchRows = 1000
+
+// streamWriteError is a failed write to the client, as opposed to a failed
+// read from the database.
+type streamWriteError struct{ err error }
+
+func (e streamWriteError) Error() string { return "write stream: " + e.err.Error() }
+
+// streamUsers runs query, which must select userColumns, through a cursor in
+// a read-only transaction, so the whole stream reads one snapshot a batch at a
+// time. begin runs once the first batch is in, before any user is passed to
+// emit, so a query that fails outright can still get an error response; flush
+// runs after every batch. It reports how many users were read.
+func streamUsers(ctx context.Context, query string, args []any, begin func(), emit func(User) error, flush func() error) (int, error) {
+	read := 0
+	err := WithTx(ctx, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, "SET TRANSACTION READ ONLY"); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, "DECLARE user_stream NO SCROLL CURSOR FOR "+query, args...); err != nil {
+			return err
+		}
+
+		fetch := "FETCH " + strconv.Itoa(streamFetchRows) + " FROM user_stream"
+		for started := false; ; {
+			rows, err := tx.Query(ctx, fetch)
+			if err != nil {
+				return err
+			}
+			if !started {
+				begin()
+				started = true
+			}
+
+			n, err := emitUsers(rows, emit)
+			read += n
+			if err != nil {
+				return err
+			}
+			if err := flush(); err != nil {
+				return streamWriteError{err}
+			}
+			if n < streamFetchRows {
+				return nil
+			}
+		}
+	})
+	return read, err
+}
+
+// emitUsers passes one fetched batch to emit and closes rows. It reports how
+// many rows it read.
+func emitUsers(rows pgx.Rows, emit func(User) error) (int, error) {
+	defer rows.Close()
+
+	n := 0
+	for rows.Next() {
+		n++
+		var user User
+		if err := scanUser(rows, &user); err != nil {
+			return n, err
+		}
+		if err := emit(user); err != nil {
+			return n, streamWriteError{err}
+		}
+	}
+	return n, rows.Err()
+}
+
+// finishUserStream logs how a streamUsers call ended, and writes the error
+// response if it failed before the response began.
+func finishUserStream(c *gin.Context, err error, started bool, written int, name string) {
+	var werr streamWriteError
+	switch {
+	case err == nil:
+	case errors.As(err, &werr):
+		// The client most likely went away; headers are already sent.
+		requestLogger(c).Warn("Aborted "+name, "rows_written", written, "error", werr.err)
+	case !started:
+		requestLogger(c).Error("Failed to start "+name, "error", err)
+		respondQueryError(c, err, "Failed to stream users")
+	default:
+		// Too late for an error response; the truncated output is the signal.
+		requestLogger(c).Error("Truncated "+name, "rows_written", written, "error", err)
+	}
+}
+
+// StreamUsers godoc
+// @Summary      Stream users as NDJSON
+// @Description  Every user matching the GetUsers filters, one JSON object per line, flushed as they are read so consumers can start before the end. The order is sort/order, id by default. A truncated stream (no final newline) means it failed part way. Admin only.
+// @Tags         users
+// @Produce      application/x-ndjson
+// @Param        search          query  string  false  "Case-insensitive match on username or email"
+// @Param        sort            query  string  false  "Sort column"  Enums(id, username, email, role)
+// @Param        order           query  string  false  "Sort direction"  Enums(asc, desc)
+// @Param        includeDeleted  query  bool    false  "Include soft-deleted users"
+// @Param        inactiveSince   query  string  false  "Only users who haven't logged in since this RFC 3339 time"
+// @Success      200  {object}  User  "One per line"
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/users/stream [get]
+func StreamUsers(c *gin.Context) {
+	if dbPool == nil {
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+		return
+	}
+
+	orderBy, err := parseSort(c, userSortColumns, "id", "id")
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, err.Error())
+		return
+	}
+
+	withDeleted, ok := includeDeleted(c)
+	if !ok {
+		return
+	}
+
+	inactive, ok := inactiveSince(c)
+	if !ok {
+		return
+	}
+
+	// The request context is canceled when the client disconnects, which
+	// cancels the cursor's next FETCH.
+	ctx, cancel := queryContext(c)
+	defer cancel()
+	where, args := userFilter(c, withDeleted, inactive)
+
+	started := false
+	enc := json.NewEncoder(c.Writer)
+	written, err := streamUsers(ctx, "SELECT "+userColumns+" FROM up_users"+where+orderBy, args,
+		func() {
+			c.Header("Content-Type", "application/x-ndjson")
+			c.Status(http.StatusOK)
+			started = true
+		},
+		func(user User) error { return enc.Encode(user) },
+		func() error {
+			c.Writer.Flush()
+			return nil
+		})
+	finishUserStream(c, err, started, written, "user stream")
+}
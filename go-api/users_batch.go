@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5"
+)
+
+// defaultUserBatchMaxSize bounds POST /users/batch and /users/bulk-delete
+// unless USER_BATCH_MAX_SIZE says otherwise.
+const defaultUserBatchMaxSize = 100
+
+// CreateUsersBatch godoc
+// @Summary      Create users in bulk
+// @Description  Inserts every user in one transaction and returns them in input order. Any conflicting email or username fails the whole batch with a 409 naming it. Admin only.
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        users  body      []CreateUserRequest  true  "Users to create"
+// @Success      201    {array}   User
+// @Failure      400    {object}  ErrorResponse
+// @Failure      401    {object}  ErrorResponse
+// @Failure      409    {object}  ErrorResponse
+// @Failure      413    {object}  ErrorResponse
+// @Failure      500    {object}  ErrorResponse
+// @Failure      503    {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/users/batch [post]
+func CreateUsersBatch(maxSize int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if dbPool == nil {
+			RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+			return
+		}
+
+		// Decoded by hand: Gin's slice validation drops element indexes, and
+		// clients need them to find the bad record.
+		var reqs []CreateUserRequest
+		if err := json.NewDecoder(c.Request.Body).Decode(&reqs); err != nil {
+			RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Request body must be a JSON array of users")
+			return
+		}
+		if len(reqs) == 0 {
+			RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Batch must contain at least one user")
+			return
+		}
+		if len(reqs) > maxSize {
+			RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest,
+				"Batch may contain at most "+strconv.Itoa(maxSize)+" users")
+			return
+		}
+
+		var fields []FieldError
+		for i := range reqs {
+			err := binding.Validator.ValidateStruct(&reqs[i])
+			var verrs validator.ValidationErrors
+			if errors.As(err, &verrs) {
+				for _, fe := range fieldErrors(verrs) {
+					fe.Field = fmt.Sprintf("[%d].%s", i, fe.Field)
+					fields = append(fields, fe)
+				}
+			} else if err != nil {
+				RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid request body")
+				return
+			}
+		}
+		if len(fields) > 0 {
+			RespondErrorDetails(c, http.StatusBadRequest, ErrCodeValidationFailed, "Validation failed", fields)
+			return
+		}
+
+		hashes := make([]*string, len(reqs))
+		for i, req := range reqs {
+			if req.Password == "" {
+				continue
+			}
+			hash, err := hashPassword(req.Password)
+			if err != nil {
+				requestLogger(c).Error("Failed to hash password", "error", err)
+				RespondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to create users")
+				return
+			}
+			hashes[i] = &hash
+		}
+
+		ctx, cancel := queryContext(c)
+		defer cancel()
+
+		users := make([]User, len(reqs))
+		org := orgID(c)
+		err := WithTx(ctx, func(tx pgx.Tx) error {
+			batch := &pgx.Batch{}
+			for i, req := range reqs {
+				batch.Queue(`INSERT INTO up_users (username, email, password, org_id, provider, created_at, updated_at)
+					VALUES ($1, $2, $3, $4, 'local', now(), now()) RETURNING id, role, email_verified`,
+					req.Username, req.Email, hashes[i], org)
+			}
+
+			br := tx.SendBatch(ctx, batch)
+			defer br.Close()
+			for i, req := range reqs {
+				users[i] = User{Username: req.Username, Email: req.Email}
+				err := br.QueryRow().Scan(&users[i].ID, &users[i].Role, &users[i].EmailVerified)
+				if ce := userConflict(err, req.Username, req.Email); ce != nil {
+					if details, ok := ce.details.(gin.H); ok {
+						details["index"] = i
+					}
+					return ce
+				}
+				if err != nil {
+					return err
+				}
+			}
+			return br.Close()
+		})
+		if respondClientError(c, err) {
+			return
+		}
+		if err != nil {
+			requestLogger(c).Error("Failed to insert user batch", "size", len(reqs), "error", err)
+			respondQueryError(c, err, "Failed to create users")
+			return
+		}
+
+		userCache.invalidate(c)
+		ids := make([]int, len(users))
+		for i, u := range users {
+			ids[i] = u.ID
+		}
+		recordAudit(c, actorID(c), auditUserBatchCreate, auditTarget{Type: "user"}, gin.H{"count": len(users), "user_ids": ids})
+		c.JSON(http.StatusCreated, users)
+	}
+}
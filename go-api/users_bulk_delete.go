@@ -0,0 +1,104 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// BulkDeleteUsers godoc
+// @Summary      Delete users in bulk
+// @Description  Soft-deletes every listed user in one UPDATE and returns the ids deleted. The body must set confirm to true. Ids matching no active user fail the whole request with a 404 listing them, unless skipMissing is set, in which case they are reported and the rest are deleted. With dryRun nothing is deleted; the response shows what would have been. Admin only.
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        request      body      BulkDeleteUsersRequest  true   "Users to delete"
+// @Param        skipMissing  query     bool                    false  "Delete the users that exist instead of failing on missing ids"
+// @Param        dryRun       query     bool                    false  "Report what would be deleted without deleting anything"
+// @Success      200          {object}  BulkDeleteUsersResult
+// @Failure      400          {object}  ErrorResponse
+// @Failure      401          {object}  ErrorResponse
+// @Failure      403          {object}  ErrorResponse
+// @Failure      404          {object}  ErrorResponse
+// @Failure      500          {object}  ErrorResponse
+// @Failure      503          {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/users/bulk-delete [post]
+func BulkDeleteUsers(maxSize int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if dbPool == nil {
+			RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+			return
+		}
+
+		var req BulkDeleteUsersRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondBindingError(c, err)
+			return
+		}
+		if !req.Confirm {
+			RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Set confirm to true to delete these users")
+			return
+		}
+		slices.Sort(req.IDs)
+		ids := slices.Compact(req.IDs)
+		if len(ids) > maxSize {
+			RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest,
+				"Batch may contain at most "+strconv.Itoa(maxSize)+" users")
+			return
+		}
+		skipMissing := c.Query("skipMissing") == "true"
+		dryRun := dryRunRequested(c)
+
+		ctx, cancel := queryContext(c)
+		defer cancel()
+
+		result := BulkDeleteUsersResult{DryRun: dryRun, UserIDs: []int{}, MissingIDs: []int{}}
+		err := WithDryRunTx(ctx, dryRun, func(tx pgx.Tx) error {
+			rows, err := tx.Query(ctx,
+				`UPDATE up_users SET deleted_at = now(), updated_at = now()
+				WHERE id = ANY($1) AND org_id = $2 AND deleted_at IS NULL RETURNING id`,
+				ids, orgID(c))
+			if err != nil {
+				return err
+			}
+			deleted, err := pgx.CollectRows(rows, pgx.RowTo[int])
+			if err != nil {
+				return err
+			}
+			slices.Sort(deleted)
+
+			result.UserIDs = deleted
+			for _, id := range ids {
+				if _, found := slices.BinarySearch(deleted, id); !found {
+					result.MissingIDs = append(result.MissingIDs, id)
+				}
+			}
+			if len(result.MissingIDs) > 0 && !skipMissing {
+				ce := newClientError(http.StatusNotFound, ErrCodeNotFound, "Some users were not found")
+				ce.details = gin.H{"missing_ids": result.MissingIDs}
+				return ce
+			}
+			return nil
+		})
+		if respondClientError(c, err) {
+			return
+		}
+		if err != nil {
+			requestLogger(c).Error("Failed to bulk delete users", "size", len(ids), "error", err)
+			respondQueryError(c, err, "Failed to delete users")
+			return
+		}
+		result.Deleted = len(result.UserIDs)
+
+		if !dryRun && result.Deleted > 0 {
+			userCache.invalidate(c)
+			recordAudit(c, actorID(c), auditUserBulkDelete, auditTarget{Type: "user"},
+				gin.H{"count": result.Deleted, "user_ids": result.UserIDs, "missing_ids": result.MissingIDs})
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5"
+)
+
+// defaultUserImportMaxBytes caps POST /users/import uploads unless
+// USER_IMPORT_MAX_BYTES says otherwise.
+const defaultUserImportMaxBytes = 5 << 20
+
+var userCSVHeader = []string{"id", "username", "email", "role", "deleted_at"}
+
+// ExportUsers godoc
+// @Summary      Export users as CSV
+// @Description  Streams every user matching the GetUsers filters as a CSV attachment with a header row. Admin only.
+// @Tags         users
+// @Produce      text/csv
+// @Param        search          query  string  false  "Case-insensitive match on username or email"
+// @Param        sort            query  string  false  "Sort column"  Enums(id, username, email, role)
+// @Param        order           query  string  false  "Sort direction"  Enums(asc, desc)
+// @Param        includeDeleted  query  bool    false  "Include soft-deleted users"
+// @Param        inactiveSince   query  string  false  "Only users who haven't logged in since this RFC 3339 time (admin only)"
+// @Success      200  {file}    file
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/users/export [get]
+func ExportUsers(c *gin.Context) {
+	if dbPool == nil {
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+		return
+	}
+
+	orderBy, err := parseSort(c, userSortColumns, "id", "id")
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, err.Error())
+		return
+	}
+
+	withDeleted, ok := includeDeleted(c)
+	if !ok {
+		return
+	}
+
+	inactive, ok := inactiveSince(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+	where, args := userFilter(c, withDeleted, inactive)
+
+	var w *csv.Writer
+	record := make([]string, len(userCSVHeader))
+	written, err := streamUsers(ctx, "SELECT "+userColumns+" FROM up_users"+where+orderBy, args,
+		func() {
+			filename := "users-" + time.Now().UTC().Format("20060102-150405") + ".csv"
+			c.Header("Content-Type", "text/csv; charset=utf-8")
+			c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+			c.Status(http.StatusOK)
+			w = csv.NewWriter(c.Writer)
+			w.Write(userCSVHeader)
+		},
+		func(user User) error {
+			deletedAt := ""
+			if user.DeletedAt != nil {
+				deletedAt = user.DeletedAt.UTC().Format(time.RFC3339)
+			}
+			record[0], record[1], record[2], record[3], record[4] =
+				strconv.Itoa(user.ID), csvSafe(user.Username), csvSafe(user.Email), user.Role, deletedAt
+			return w.Write(record)
+		},
+		func() error {
+			w.Flush()
+			if err := w.Error(); err != nil {
+				return err
+			}
+			c.Writer.Flush()
+			return nil
+		})
+	finishUserStream(c, err, w != nil, written, "user export")
+}
+
+// csvSafe neutralizes values a spreadsheet would evaluate as a formula by
+// prefixing them with a single quote.
+func csvSafe(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// ImportUsers godoc
+// @Summary      Import users from CSV
+// @Description  Creates users from an uploaded CSV whose header row names the columns: username and email are required, password and role optional. Every row is validated first and nothing is inserted if any row is invalid. Rows whose username or email already exists are skipped. With dryRun=true the import runs in full inside a transaction that is then rolled back, so the result shows what would happen without creating anyone. Admin only.
+// @Tags         users
+// @Accept       multipart/form-data
+// @Produce      json
+// @Param        file    formData  file  true   "CSV file"
+// @Param        dryRun  query     bool  false  "Report what would be imported without creating anyone"
+// @Success      200  {object}  ImportUsersResult
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      413  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/users/import [post]
+func ImportUsers(maxBytes int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if dbPool == nil {
+			RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+			return
+		}
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+		file, _, err := c.Request.FormFile("file")
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				RespondError(c, http.StatusRequestEntityTooLarge, ErrCodePayloadTooLarge,
+					fmt.Sprintf("Upload exceeds %d bytes", maxBytes))
+				return
+			}
+			RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "A CSV file is required in the file field")
+			return
+		}
+		defer file.Close()
+
+		rows, err := parseUserImport(file)
+		if respondClientError(c, err) {
+			return
+		}
+		if err != nil {
+			RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid CSV: "+err.Error())
+			return
+		}
+
+		ctx, cancel := queryContext(c)
+		defer cancel()
+
+		// Hash up front so the transaction only spends time on inserts.
+		hashes := make([]*string, len(rows))
+		for i, row := range rows {
+			if row.Password == "" {
+				continue
+			}
+			// Hashing is the slow part of a large import, so stop as soon as
+			// the route times out or the client leaves.
+			if err := ctx.Err(); err != nil {
+				requestLogger(c).Warn("Stopped user import while hashing passwords", "rows", len(rows), "row", i, "error", err)
+				if errors.Is(err, context.Canceled) {
+					c.AbortWithStatus(statusClientClosedRequest)
+				} else {
+					RespondError(c, http.StatusGatewayTimeout, ErrCodeTimeout, "Request timed out")
+				}
+				return
+			}
+			hash, err := hashPassword(row.Password)
+			if err != nil {
+				requestLogger(c).Error("Failed to hash password", "error", err)
+				RespondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to import users")
+				return
+			}
+			hashes[i] = &hash
+		}
+
+		dryRun := dryRunRequested(c)
+		result := ImportUsersResult{DryRun: dryRun, Rows: make([]ImportRowResult, len(rows))}
+		org := orgID(c)
+		err = WithDryRunTx(ctx, dryRun, func(tx pgx.Tx) error {
+			result.Created, result.Skipped = 0, 0
+			batch := &pgx.Batch{}
+			for i, row := range rows {
+				role := row.Role
+				if role == "" {
+					role = RoleStudent
+				}
+				batch.Queue(`INSERT INTO up_users (username, email, password, role, org_id, provider, created_at, updated_at)
+					VALUES ($1, $2, $3, $4, $5, 'local', now(), now()) ON CONFLICT DO NOTHING`,
+					row.Username, row.Email, hashes[i], role, org)
+			}
+
+			br := tx.SendBatch(ctx, batch)
+			for i, row := range rows {
+				tag, err := br.Exec()
+				if err != nil {
+					br.Close()
+					return err
+				}
+				status := importRowCreated
+				if tag.RowsAffected() == 0 {
+					status = importRowSkipped
+					result.Skipped++
+				} else {
+					result.Created++
+				}
+				result.Rows[i] = ImportRowResult{Line: row.Line, Username: row.Username, Email: row.Email, Status: status}
+			}
+			return br.Close()
+		})
+		if err != nil {
+			requestLogger(c).Error("Failed to import users", "rows", len(rows), "error", err)
+			respondQueryError(c, err, "Failed to import users")
+			return
+		}
+
+		if dryRun {
+			requestLogger(c).Info("Previewed user import", "created", result.Created, "skipped", result.Skipped)
+			c.JSON(http.StatusOK, result)
+			return
+		}
+
+		requestLogger(c).Info("Imported users", "created", result.Created, "skipped", result.Skipped)
+		recordAudit(c, actorID(c), auditUserImport, auditTarget{Type: "user"}, gin.H{"created": result.Created, "skipped": result.Skipped})
+		if result.Created > 0 {
+			userCache.invalidate(c)
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// parseUserImport reads and validates every row of an import CSV. Rows that
+// fail validation are collected into a single *clientError listing each bad
+// line; malformed CSV is returned as a plain error.
+func parseUserImport(r io.Reader) ([]ImportUserRow, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, newClientError(http.StatusBadRequest, ErrCodeInvalidRequest, "CSV file is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch name {
+		case "username", "email", "password", "role":
+			columns[name] = i
+		default:
+			return nil, newClientError(http.StatusBadRequest, ErrCodeInvalidRequest,
+				fmt.Sprintf("Unknown CSV column %q: expected username, email, password, role", name))
+		}
+	}
+	if _, ok := columns["username"]; !ok {
+		return nil, newClientError(http.StatusBadRequest, ErrCodeInvalidRequest, "CSV header must include username")
+	}
+	if _, ok := columns["email"]; !ok {
+		return nil, newClientError(http.StatusBadRequest, ErrCodeInvalidRequest, "CSV header must include email")
+	}
+
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var rows []ImportUserRow
+	var invalid []ImportRowError
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := reader.FieldPos(0)
+		row := ImportUserRow{
+			Line:     line,
+			Username: field(record, "username"),
+			Email:    field(record, "email"),
+			Password: field(record, "password"),
+			Role:     strings.ToLower(field(record, "role")),
+		}
+		if err := binding.Validator.ValidateStruct(&row); err != nil {
+			var verrs validator.ValidationErrors
+			if !errors.As(err, &verrs) {
+				return nil, err
+			}
+			invalid = append(invalid, ImportRowError{Line: line, Fields: fieldErrors(verrs)})
+			continue
+		}
+		rows = append(rows, row)
+	}
+
+	if len(invalid) > 0 {
+		return nil, &clientError{
+			status:  http.StatusBadRequest,
+			code:    ErrCodeValidationFailed,
+			msg:     fmt.Sprintf("%d invalid rows; nothing was imported", len(invalid)),
+			details: invalid,
+		}
+	}
+	if len(rows) == 0 {
+		return nil, newClientError(http.StatusBadRequest, ErrCodeInvalidRequest, "CSV file has no data rows")
+	}
+	return rows, nil
+}
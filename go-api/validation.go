@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+const minPasswordLength = 8
+
+type FieldError struct {
+	Field   string `json:"field" example:"email"`
+	Message string `json:"message" example:"must be a valid email address"`
+}
+
+// registerValidators hooks our custom rules into Gin's validator and makes
+// field errors report JSON names rather than Go struct field names.
+func registerValidators() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+
+	if err := v.RegisterValidation("password", validatePassword); err != nil {
+		fatal("Failed to register password validator", "error", err)
+	}
+}
+
+// validatePassword requires minPasswordLength characters including at least
+// one letter and one digit.
+func validatePassword(fl validator.FieldLevel) bool {
+	password := fl.Field().String()
+	if len(password) < minPasswordLength {
+		return false
+	}
+
+	var hasLetter, hasDigit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		}
+	}
+	return hasLetter && hasDigit
+}
+
+// parseTimestamp reads a timestamp filter as RFC 3339, in UTC. The zone
+// offset is required, so a bare local time is refused rather than guessed at.
+func parseTimestamp(v string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, v)
+	return t.UTC(), err
+}
+
+// respondBindingError turns a ShouldBindJSON error into a 400, listing each
+// offending field when the failure came from validation.
+func respondBindingError(c *gin.Context, err error) {
+	// A body cut off at the size limit would otherwise look like bad JSON.
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		respondTooLarge(c, tooLarge.Limit)
+		return
+	}
+
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid request body")
+		return
+	}
+
+	RespondErrorDetails(c, http.StatusBadRequest, ErrCodeValidationFailed, "Validation failed", fieldErrors(verrs))
+}
+
+func fieldErrors(verrs validator.ValidationErrors) []FieldError {
+	fields := make([]FieldError, 0, len(verrs))
+	for _, fe := range verrs {
+		fields = append(fields, FieldError{Field: fe.Field(), Message: fieldErrorMessage(fe)})
+	}
+	return fields
+}
+
+func fieldErrorMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "min", "max":
+		bound := "at least "
+		if fe.Tag() == "max" {
+			bound = "at most "
+		}
+		switch fe.Kind() {
+		case reflect.Slice, reflect.Array, reflect.Map:
+			return "must contain " + bound + fe.Param() + " items"
+		case reflect.String:
+			return "must be " + bound + fe.Param() + " characters"
+		default:
+			return "must be " + bound + fe.Param()
+		}
+	case "oneof":
+		return "must be one of: " + strings.ReplaceAll(fe.Param(), " ", ", ")
+	case "password":
+		return "must be at least " + strconv.Itoa(minPasswordLength) + " characters and contain a letter and a digit"
+	default:
+		return "is invalid"
+	}
+}
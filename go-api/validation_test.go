@@ -0,0 +1,25 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	want := time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC)
+	for _, v := range []string{"2026-03-01T09:30:00Z", "2026-03-01T16:30:00+07:00", "2026-03-01T04:30:00-05:00"} {
+		got, err := parseTimestamp(v)
+		if err != nil {
+			t.Errorf("parseTimestamp(%q): %v", v, err)
+			continue
+		}
+		if !got.Equal(want) || got.Location() != time.UTC {
+			t.Errorf("parseTimestamp(%q) = %v, want %v", v, got, want)
+		}
+	}
+	for _, v := range []string{"2026-03-01T09:30:00", "2026-03-01 09:30:00", "2026-03-01", ""} {
+		if _, err := parseTimestamp(v); err == nil {
+			t.Errorf("parseTimestamp(%q) accepted a time without a zone", v)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	defaultEmailVerificationTTL = 48 * time.Hour
+	verificationResendInterval  = 1 * time.Minute
+	invalidVerificationToken    = "Verification link is invalid or has expired"
+)
+
+var verificationEmailTemplate = template.Must(template.New("verification").Parse(`Hi {{.Username}},
+
+Welcome to Quick Quiz! Please confirm your email address by opening this link:
+
+{{.Link}}
+
+The link expires on {{.ExpiresAt.Format "2 Jan 2006 15:04 MST"}}. If you didn't create an account, you can ignore this email.
+`))
+
+// sendVerification issues a fresh verification token for user and emails
+// the link. The token only verifies the address it was sent to.
+func (m *accountMailer) sendVerification(ctx context.Context, user User) error {
+	token, hash, err := newOpaqueToken()
+	if err != nil {
+		return err
+	}
+
+	var expiresAt time.Time
+	err = dbPool.QueryRow(ctx, `
+		INSERT INTO email_verification_tokens (user_id, token_hash, expires_at, email)
+		VALUES ($1, $2, now() + make_interval(secs => $3), $4) RETURNING expires_at`,
+		user.ID, hash, m.verificationTTL.Seconds(), user.Email).Scan(&expiresAt)
+	if err != nil {
+		return err
+	}
+
+	link := tokenLink(strings.TrimRight(m.publicURL, "/")+"/v1/auth/verify", token)
+	return m.send(ctx, user, "Confirm your Quick Quiz email address", verificationEmailTemplate, link, expiresAt)
+}
+
+// VerifyEmail godoc
+// @Summary      Verify an email address
+// @Description  Marks the account's email as verified using the token from the verification email. Tokens expire, stop working if the account's email changes after they were sent, and once one works the account's other outstanding tokens stop working.
+// @Tags         auth
+// @Produce      json
+// @Param        token  query     string  true  "Token from the verification email"
+// @Success      200    {object}  VerifyEmailResponse
+// @Failure      400    {object}  ErrorResponse
+// @Failure      500    {object}  ErrorResponse
+// @Failure      503    {object}  ErrorResponse
+// @Router       /v1/auth/verify [get]
+func VerifyEmail(c *gin.Context) {
+	if dbPool == nil {
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+		return
+	}
+
+	token := c.Query("token")
+	if token == "" {
+		RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "token is required")
+		return
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	var userID int
+	err := WithTx(ctx, func(tx pgx.Tx) error {
+		// A token mailed to an address the account no longer has proves
+		// nothing about the current one. Locking the user too keeps the email
+		// from changing before it is marked verified.
+		var usable bool
+		err := tx.QueryRow(ctx, `
+			SELECT t.user_id, t.used_at IS NULL AND t.expires_at > now() AND u.email = t.email
+			FROM email_verification_tokens t
+			JOIN up_users u ON u.id = t.user_id
+			WHERE t.token_hash = $1
+			FOR UPDATE`, hashOpaqueToken(token)).Scan(&userID, &usable)
+		if errors.Is(err, pgx.ErrNoRows) || (err == nil && !usable) {
+			return newClientError(http.StatusBadRequest, ErrCodeInvalidToken, invalidVerificationToken)
+		}
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(ctx,
+			"UPDATE up_users SET email_verified = true, updated_at = now() WHERE id = $1", userID); err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx,
+			"UPDATE email_verification_tokens SET used_at = now() WHERE user_id = $1 AND used_at IS NULL", userID)
+		return err
+	})
+	if respondClientError(c, err) {
+		return
+	}
+	if err != nil {
+		requestLogger(c).Error("Failed to verify email", "error", err)
+		respondQueryError(c, err, "Failed to verify email")
+		return
+	}
+
+	userCache.invalidate(c)
+	c.JSON(http.StatusOK, VerifyEmailResponse{Message: "Email verified", UserID: userID})
+}
+
+// ResendVerification godoc
+// @Summary      Resend the verification email
+// @Description  Emails the current user a new verification link. Earlier links keep working until they expire. Limited to one email per minute.
+// @Tags         auth
+// @Produce      json
+// @Success      202  {object}  MessageResponse
+// @Success      200  {object}  MessageResponse  "Already verified; nothing sent"
+// @Failure      401  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      429  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/auth/resend-verification [post]
+func ResendVerification(mailer *accountMailer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if dbPool == nil {
+			RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+			return
+		}
+
+		claims, ok := currentClaims(c)
+		if !ok {
+			RespondError(c, http.StatusUnauthorized, ErrCodeUnauthorized, "Authentication required")
+			return
+		}
+
+		ctx, cancel := queryContext(c)
+		defer cancel()
+
+		var (
+			user       User
+			recentSent bool
+		)
+		err := dbPool.QueryRow(ctx, `
+			SELECT u.id, u.username, u.email, u.email_verified,
+				EXISTS (SELECT 1 FROM email_verification_tokens t
+					WHERE t.user_id = u.id AND t.created_at > now() - make_interval(secs => $2))
+			FROM up_users u
+			WHERE u.id = $1 AND u.deleted_at IS NULL`, claims.UserID, verificationResendInterval.Seconds()).
+			Scan(&user.ID, &user.Username, &user.Email, &user.EmailVerified, &recentSent)
+		if errors.Is(err, pgx.ErrNoRows) {
+			RespondError(c, http.StatusNotFound, ErrCodeNotFound, "User not found")
+			return
+		}
+		if err != nil {
+			requestLogger(c).Error("Failed to query user", "user_id", claims.UserID, "error", err)
+			respondQueryError(c, err, "Failed to resend verification email")
+			return
+		}
+
+		if user.EmailVerified {
+			c.JSON(http.StatusOK, MessageResponse{Message: "Email is already verified"})
+			return
+		}
+		if recentSent {
+			c.Header("Retry-After", strconv.Itoa(int(verificationResendInterval.Seconds())))
+			RespondError(c, http.StatusTooManyRequests, ErrCodeRateLimited, "A verification email was sent recently; try again shortly")
+			return
+		}
+
+		sendInBackground(requestLogger(c).With("user_id", user.ID), func(ctx context.Context) error {
+			return mailer.sendVerification(ctx, user)
+		})
+		c.JSON(http.StatusAccepted, MessageResponse{Message: "Verification email sent"})
+	}
+}
+
+// RequireVerified lets the request through only for users whose email is
+// verified. The flag is read from the database rather than the token, so
+// verifying takes effect without logging in again. It must run after
+// AuthRequired.
+func RequireVerified() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if dbPool == nil {
+			RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+			return
+		}
+
+		claims, ok := currentClaims(c)
+		if !ok {
+			RespondError(c, http.StatusUnauthorized, ErrCodeUnauthorized, "Authentication required")
+			return
+		}
+
+		// Not deferred: the live route keeps the handler running for the
+		// whole exam.
+		ctx, cancel := queryContext(c)
+		var verified bool
+		err := dbPool.QueryRow(ctx,
+			"SELECT email_verified FROM up_users WHERE id = $1 AND deleted_at IS NULL", claims.UserID).Scan(&verified)
+		cancel()
+		if errors.Is(err, pgx.ErrNoRows) {
+			RespondError(c, http.StatusUnauthorized, ErrCodeUnauthorized, "Account no longer exists")
+			return
+		}
+		if err != nil {
+			requestLogger(c).Error("Failed to check email verification", "user_id", claims.UserID, "error", err)
+			respondQueryError(c, err, "Failed to check email verification")
+			return
+		}
+		if !verified {
+			RespondError(c, http.StatusForbidden, ErrCodeEmailUnverified, "Verify your email address before taking exams")
+			return
+		}
+		c.Next()
+	}
+}
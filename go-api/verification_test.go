@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// capturedEmails is an emailSender that keeps what it is asked to send.
+type capturedEmails []emailMessage
+
+func (e *capturedEmails) Send(_ context.Context, msg emailMessage) error {
+	*e = append(*e, msg)
+	return nil
+}
+
+var verifyLinkPattern = regexp.MustCompile(`http\S+/v1/auth/verify\S*`)
+
+// mailVerificationToken sends user a verification email and returns the
+// token from its link.
+func mailVerificationToken(t *testing.T, ctx context.Context, user User) string {
+	t.Helper()
+	var sent capturedEmails
+	mailer := &accountMailer{sender: &sent, publicURL: "http://api.test", verificationTTL: time.Hour}
+	if err := mailer.sendVerification(ctx, user); err != nil {
+		t.Fatalf("sendVerification: %v", err)
+	}
+	if len(sent) != 1 || sent[0].To != user.Email {
+		t.Fatalf("sent %+v, want one email to %s", sent, user.Email)
+	}
+	link, err := url.Parse(verifyLinkPattern.FindString(sent[0].Body))
+	if err != nil || link.Query().Get("token") == "" {
+		t.Fatalf("no verification link in %q", sent[0].Body)
+	}
+	return link.Query().Get("token")
+}
+
+func verifyEmail(t *testing.T, token string) *httptest.ResponseRecorder {
+	t.Helper()
+	return serveRoute(t, http.MethodGet, "/verify", "/verify?token="+url.QueryEscape(token), VerifyEmail, nil, nil)
+}
+
+func emailVerified(t *testing.T, ctx context.Context, userID int) bool {
+	t.Helper()
+	var verified bool
+	if err := dbPool.QueryRow(ctx, "SELECT email_verified FROM up_users WHERE id = $1", userID).Scan(&verified); err != nil {
+		t.Fatalf("read email_verified: %v", err)
+	}
+	return verified
+}
+
+func TestVerifyEmail(t *testing.T) {
+	ctx := testDB(t)
+	org := testOrg(t, ctx)
+	user := testUser(t, ctx, org, RoleStudent, "")
+	if _, err := dbPool.Exec(ctx, "UPDATE up_users SET email_verified = false WHERE id = $1", user.ID); err != nil {
+		t.Fatalf("unverify: %v", err)
+	}
+
+	if w := verifyEmail(t, mailVerificationToken(t, ctx, user)); w.Code != http.StatusOK {
+		t.Fatalf("verify: %d %s, want 200", w.Code, w.Body)
+	}
+	if !emailVerified(t, ctx, user.ID) {
+		t.Error("email not verified")
+	}
+}
+
+func TestVerifyEmailRefusesTokenForOldAddress(t *testing.T) {
+	ctx := testDB(t)
+	org := testOrg(t, ctx)
+	user := testUser(t, ctx, org, RoleStudent, "")
+	token := mailVerificationToken(t, ctx, user)
+
+	newEmail := uniqueName("moved") + "@example.com"
+	w := serveRoute(t, http.MethodPatch, "/users/:id", "/users/"+strconv.Itoa(user.ID), UpdateUser,
+		gin.H{"email": newEmail}, &Claims{UserID: user.ID, Role: user.Role, OrgID: org})
+	if w.Code != http.StatusOK {
+		t.Fatalf("change email: %d %s, want 200", w.Code, w.Body)
+	}
+	if emailVerified(t, ctx, user.ID) {
+		t.Fatal("new email counts as verified before any link was used")
+	}
+
+	if w := verifyEmail(t, token); w.Code != http.StatusBadRequest || errorCode(t, w) != ErrCodeInvalidToken {
+		t.Fatalf("token for the old address: %d %s, want 400 %s", w.Code, w.Body, ErrCodeInvalidToken)
+	}
+	if emailVerified(t, ctx, user.ID) {
+		t.Error("a token mailed to the old address verified the new one")
+	}
+
+	// A link sent to the new address still works.
+	user.Email = newEmail
+	if w := verifyEmail(t, mailVerificationToken(t, ctx, user)); w.Code != http.StatusOK {
+		t.Fatalf("token for the new address: %d %s, want 200", w.Code, w.Body)
+	}
+	if !emailVerified(t, ctx, user.ID) {
+		t.Error("new address not verified")
+	}
+}
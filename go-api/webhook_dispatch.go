@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Webhook events. Subscriptions name these.
+const (
+	webhookSubmissionScored = "submission.scored"
+)
+
+const (
+	webhookPollInterval   = 5 * time.Second
+	webhookBatchSize      = 20
+	webhookRequestTimeout = 10 * time.Second
+	// webhookLease is how long a claimed delivery stays hidden from other
+	// dispatchers. It outlasts a request, so only a crashed instance lets a
+	// delivery be sent twice.
+	webhookLease       = 1 * time.Minute
+	webhookMaxAttempts = 8
+	webhookBaseBackoff = 30 * time.Second
+	webhookMaxBackoff  = 6 * time.Hour
+)
+
+// webhookClient sends deliveries. Redirects aren't followed: a receiver that
+// moved should be re-registered, not silently chased. It only connects to
+// public addresses, and never through a proxy, since the proxy would be the
+// address checked.
+var webhookClient = &http.Client{
+	Timeout:   webhookRequestTimeout,
+	Transport: webhookTransport(),
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+func webhookTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = nil
+	t.DialContext = (&net.Dialer{Timeout: webhookRequestTimeout, Control: dialPublicOnly}).DialContext
+	return t
+}
+
+// errWebhookDestination is why a delivery to an address inside the network
+// fails.
+var errWebhookDestination = errors.New("webhook destination is not a public address")
+
+// dialPublicOnly refuses connections to addresses publicAddr rejects. It runs
+// on the resolved address, so a name that resolves, or later rebinds, to an
+// internal one is refused too.
+func dialPublicOnly(_, address string, _ syscall.RawConn) error {
+	addr, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !publicAddr(addr.Addr()) {
+		return fmt.Errorf("%w: %s", errWebhookDestination, addr.Addr())
+	}
+	return nil
+}
+
+// publicAddr reports whether a webhook may be delivered to ip: tenants
+// mustn't reach the API's own network through it, such as the database, Redis
+// or a cloud metadata endpoint.
+func publicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsValid() && !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() &&
+		!ip.IsUnspecified() && !ip.IsMulticast()
+}
+
+// webhookPayload is the body of every delivery.
+type webhookPayload struct {
+	Event      string    `json:"event"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Data       any       `json:"data"`
+}
+
+// enqueueWebhook queues event for every webhook of org subscribed to it. It
+// runs on the caller's transaction, so a rolled-back change never notifies
+// anyone and a committed one always does.
+func enqueueWebhook(ctx context.Context, q querier, org int, event string, data any) error {
+	payload, err := json.Marshal(webhookPayload{Event: event, OccurredAt: time.Now().UTC(), Data: data})
+	if err != nil {
+		return err
+	}
+	_, err = q.Exec(ctx, `
+		INSERT INTO webhook_deliveries (webhook_id, event, payload)
+		SELECT id, $1, $2 FROM webhooks WHERE $1 = ANY(events) AND org_id = $3`, event, payload, org)
+	return err
+}
+
+// StartWebhookDispatcher sends queued deliveries until ctx is cancelled. The
+// returned function waits for the delivery in progress to finish.
+func StartWebhookDispatcher(ctx context.Context) (wait func()) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(webhookPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			// Keep going while there's a backlog instead of waiting a tick
+			// per batch.
+			for ctx.Err() == nil {
+				n, err := dispatchWebhooks(ctx)
+				if err != nil {
+					if ctx.Err() == nil {
+						logger.Error("Failed to dispatch webhooks", "error", err)
+					}
+					break
+				}
+				if n < webhookBatchSize {
+					break
+				}
+			}
+		}
+	}()
+	return wg.Wait
+}
+
+type webhookDelivery struct {
+	id       int64
+	event    string
+	payload  []byte
+	attempts int
+	url      string
+	secret   string
+}
+
+// dispatchWebhooks claims a batch of due deliveries and sends them,
+// returning how many it claimed.
+func dispatchWebhooks(ctx context.Context) (int, error) {
+	if dbPool == nil {
+		return 0, nil
+	}
+	rows, err := dbPool.Query(ctx, `
+		UPDATE webhook_deliveries d
+		SET next_attempt_at = now() + make_interval(secs => $2)
+		FROM webhooks w
+		WHERE w.id = d.webhook_id AND d.id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= now()
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED)
+		RETURNING d.id, d.event, d.payload, d.attempts, w.url, w.secret`, webhookBatchSize, webhookLease.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	var batch []webhookDelivery
+	for rows.Next() {
+		var d webhookDelivery
+		if err := rows.Scan(&d.id, &d.event, &d.payload, &d.attempts, &d.url, &d.secret); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		batch = append(batch, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, d := range batch {
+		if ctx.Err() != nil {
+			// Unsent claims come back when their lease runs out.
+			break
+		}
+		status, sendErr := sendWebhook(ctx, d)
+		if err := recordDelivery(ctx, d, status, sendErr); err != nil {
+			logger.Error("Failed to record webhook delivery", "delivery_id", d.id, "error", err)
+		}
+	}
+	return len(batch), nil
+}
+
+// sendWebhook posts the payload, signed with the webhook's secret. The
+// receiver checks X-Webhook-Signature, which is "t=<unix time>,v1=<hex>"
+// where the hex is HMAC-SHA256 of "<unix time>.<body>"; the timestamp lets
+// it reject replays.
+func sendWebhook(ctx context.Context, d webhookDelivery) (int, error) {
+	reqCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), webhookRequestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodPost, d.url, bytes.NewReader(d.payload))
+	if err != nil {
+		return 0, err
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "quick-quiz-webhooks")
+	req.Header.Set("X-Webhook-Event", d.event)
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatInt(d.id, 10))
+	req.Header.Set("X-Webhook-Signature", "t="+ts+",v1="+signWebhook(d.secret, ts, d.payload))
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// A little of the body helps whoever debugs a failing receiver.
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver returned %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	return resp.StatusCode, nil
+}
+
+func signWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// recordDelivery stores the outcome of an attempt and schedules the next
+// one, backing off exponentially, until webhookMaxAttempts is reached.
+func recordDelivery(ctx context.Context, d webhookDelivery, status int, sendErr error) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), queryTimeout)
+	defer cancel()
+
+	var code *int
+	if status != 0 {
+		code = &status
+	}
+	attempts := d.attempts + 1
+	if sendErr == nil {
+		_, err := dbPool.Exec(ctx, `
+			UPDATE webhook_deliveries
+			SET status = 'delivered', attempts = $2, last_status_code = $3, last_error = NULL, delivered_at = now()
+			WHERE id = $1`, d.id, attempts, code)
+		return err
+	}
+
+	state := "pending"
+	if attempts >= webhookMaxAttempts {
+		state = "failed"
+	}
+	logger.Warn("Webhook delivery failed", "delivery_id", d.id, "attempt", attempts, "error", sendErr)
+	_, err := dbPool.Exec(ctx, `
+		UPDATE webhook_deliveries
+		SET status = $2, attempts = $3, last_status_code = $4, last_error = $5,
+			next_attempt_at = now() + make_interval(secs => $6)
+		WHERE id = $1`, d.id, state, attempts, code, truncate(sendErr.Error(), 1000), webhookBackoff(attempts).Seconds())
+	return err
+}
+
+// webhookBackoff is the wait after the given number of failed attempts:
+// 30s, 1m, 2m, ... up to webhookMaxBackoff.
+func webhookBackoff(attempts int) time.Duration {
+	d := time.Duration(float64(webhookBaseBackoff) * math.Pow(2, float64(attempts-1)))
+	if d <= 0 || d > webhookMaxBackoff {
+		return webhookMaxBackoff
+	}
+	return d
+}
+
+// truncate cuts s to at most n bytes without splitting a UTF-8 sequence,
+// which Postgres would reject.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return strings.ToValidUTF8(s[:n], "")
+}
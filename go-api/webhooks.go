@@ -0,0 +1,280 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+const webhookColumns = "id, url, events, created_by, created_at"
+
+func scanWebhook(row pgx.Row, w *Webhook) error {
+	return row.Scan(&w.ID, &w.URL, &w.Events, &w.CreatedBy, &w.CreatedAt)
+}
+
+// GetWebhooks godoc
+// @Summary      List webhooks
+// @Description  Secrets are not included. Admin only.
+// @Tags         webhooks
+// @Produce      json
+// @Success      200  {array}   Webhook
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/webhooks [get]
+func GetWebhooks(c *gin.Context) {
+	if dbPool == nil {
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+		return
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	rows, err := dbPool.Query(ctx, "SELECT "+webhookColumns+" FROM webhooks WHERE org_id = $1 ORDER BY id", orgID(c))
+	if err != nil {
+		requestLogger(c).Error("Failed to query webhooks", "error", err)
+		respondQueryError(c, err, "Failed to fetch webhooks")
+		return
+	}
+	defer rows.Close()
+
+	webhooks := []Webhook{}
+	for rows.Next() {
+		var w Webhook
+		if err := scanWebhook(rows, &w); err != nil {
+			requestLogger(c).Error("Failed to scan webhook row", "error", err)
+			respondQueryError(c, err, "Error reading webhooks")
+			return
+		}
+		webhooks = append(webhooks, w)
+	}
+	if err := rows.Err(); err != nil {
+		requestLogger(c).Error("Failed to iterate webhook rows", "error", err)
+		respondQueryError(c, err, "Error reading webhooks")
+		return
+	}
+
+	c.JSON(http.StatusOK, webhooks)
+}
+
+// CreateWebhook godoc
+// @Summary      Register a webhook
+// @Description  Subscribes url to the given events. Each delivery is a JSON POST signed with the secret: X-Webhook-Signature is "t=<unix time>,v1=<hex HMAC-SHA256 of "<unix time>.<body>">". Without a secret one is generated. The secret is only ever returned here. url must reach a public address: loopback, private, link-local and multicast ones are refused here when given as an IP and at delivery otherwise. Admin only.
+// @Tags         webhooks
+// @Accept       json
+// @Produce      json
+// @Param        webhook  body      CreateWebhookRequest  true  "Webhook to register"
+// @Success      201      {object}  Webhook
+// @Failure      400      {object}  ErrorResponse
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      500      {object}  ErrorResponse
+// @Failure      503      {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/webhooks [post]
+func CreateWebhook(c *gin.Context) {
+	if dbPool == nil {
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+		return
+	}
+
+	var req CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindingError(c, err)
+		return
+	}
+	if msg := checkWebhookURL(req.URL); msg != "" {
+		RespondErrorDetails(c, http.StatusBadRequest, ErrCodeValidationFailed, "Validation failed",
+			[]FieldError{{Field: "url", Message: msg}})
+		return
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		var err error
+		if secret, _, err = newOpaqueToken(); err != nil {
+			requestLogger(c).Error("Failed to generate webhook secret", "error", err)
+			RespondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to create webhook")
+			return
+		}
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	var w Webhook
+	err := scanWebhook(dbPool.QueryRow(ctx, `
+		INSERT INTO webhooks (url, secret, events, created_by, org_id)
+		VALUES ($1, $2, $3, $4, $5) RETURNING `+webhookColumns,
+		req.URL, secret, slices.Compact(slices.Sorted(slices.Values(req.Events))), actorID(c), orgID(c)), &w)
+	if err != nil {
+		requestLogger(c).Error("Failed to insert webhook", "error", err)
+		respondQueryError(c, err, "Failed to create webhook")
+		return
+	}
+
+	recordAudit(c, actorID(c), auditWebhookCreate, auditTarget{"webhook", w.ID}, gin.H{"url": w.URL, "events": w.Events})
+	w.Secret = secret
+	c.JSON(http.StatusCreated, w)
+}
+
+// checkWebhookURL returns why raw can't be a webhook URL, or "" if it can.
+// Hosts given as names are checked when a delivery connects; see
+// dialPublicOnly.
+func checkWebhookURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "must be an absolute http or https URL"
+	}
+	host := u.Hostname()
+	if ip, err := netip.ParseAddr(host); (err == nil && !publicAddr(ip)) || strings.EqualFold(host, "localhost") {
+		return "must not point at a loopback, private, link-local or multicast address"
+	}
+	return ""
+}
+
+// DeleteWebhook godoc
+// @Summary      Unregister a webhook
+// @Description  Deliveries still queued for it are dropped. Admin only.
+// @Tags         webhooks
+// @Param        id   path  int  true  "Webhook ID"
+// @Success      204
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/webhooks/{id} [delete]
+func DeleteWebhook(c *gin.Context) {
+	if dbPool == nil {
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+		return
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid webhook id")
+		return
+	}
+
+	var webhookURL string
+	err = dbPool.QueryRow(ctx,
+		"DELETE FROM webhooks WHERE id = $1 AND org_id = $2 RETURNING url", id, orgID(c)).Scan(&webhookURL)
+	if errors.Is(err, pgx.ErrNoRows) {
+		RespondError(c, http.StatusNotFound, ErrCodeNotFound, "Webhook not found")
+		return
+	}
+	if err != nil {
+		requestLogger(c).Error("Failed to delete webhook", "webhook_id", id, "error", err)
+		respondQueryError(c, err, "Failed to delete webhook")
+		return
+	}
+
+	recordAudit(c, actorID(c), auditWebhookDelete, auditTarget{"webhook", id}, gin.H{"url": webhookURL})
+	c.Status(http.StatusNoContent)
+}
+
+// GetWebhookDeliveries godoc
+// @Summary      List a webhook's deliveries
+// @Description  Newest first, with the outcome of the latest attempt. Pending deliveries are retried with exponential backoff; failed ones have given up. Admin only.
+// @Tags         webhooks
+// @Produce      json
+// @Param        id      path      int     true   "Webhook ID"
+// @Param        status  query     string  false  "Only deliveries in this state"  Enums(pending, delivered, failed)
+// @Param        limit   query     int     false  "Page size (default 20, max 100)"
+// @Param        offset  query     int     false  "Rows to skip"
+// @Success      200     {object}  PaginatedWebhookDeliveries
+// @Failure      400     {object}  ErrorResponse
+// @Failure      401     {object}  ErrorResponse
+// @Failure      403     {object}  ErrorResponse
+// @Failure      404     {object}  ErrorResponse
+// @Failure      500     {object}  ErrorResponse
+// @Failure      503     {object}  ErrorResponse
+// @Security     BearerAuth
+// @Router       /v1/webhooks/{id}/deliveries [get]
+func GetWebhookDeliveries(c *gin.Context) {
+	if dbPool == nil {
+		RespondError(c, http.StatusServiceUnavailable, ErrCodeDBUnavailable, "Database connection not established")
+		return
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid webhook id")
+		return
+	}
+
+	limit, offset, err := parsePagination(c)
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, err.Error())
+		return
+	}
+
+	// An empty status matches every state.
+	status := c.Query("status")
+
+	var total int
+	err = dbPool.QueryRow(ctx, `
+		SELECT (SELECT COUNT(*) FROM webhook_deliveries WHERE webhook_id = w.id AND ($2 = '' OR status = $2))
+		FROM webhooks w WHERE w.id = $1 AND w.org_id = $3`, id, status, orgID(c)).Scan(&total)
+	if errors.Is(err, pgx.ErrNoRows) {
+		RespondError(c, http.StatusNotFound, ErrCodeNotFound, "Webhook not found")
+		return
+	}
+	if err != nil {
+		requestLogger(c).Error("Failed to count webhook deliveries", "webhook_id", id, "error", err)
+		respondQueryError(c, err, "Failed to fetch deliveries")
+		return
+	}
+
+	rows, err := dbPool.Query(ctx, `
+		SELECT id, event, status, attempts, last_status_code, last_error,
+			CASE WHEN status = 'pending' THEN next_attempt_at END, delivered_at, created_at
+		FROM webhook_deliveries
+		WHERE webhook_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY id DESC
+		LIMIT $3 OFFSET $4`, id, status, limit, offset)
+	if err != nil {
+		requestLogger(c).Error("Failed to query webhook deliveries", "webhook_id", id, "error", err)
+		respondQueryError(c, err, "Failed to fetch deliveries")
+		return
+	}
+	defer rows.Close()
+
+	deliveries := []WebhookDelivery{}
+	for rows.Next() {
+		d := WebhookDelivery{WebhookID: id}
+		if err := rows.Scan(&d.ID, &d.Event, &d.Status, &d.Attempts, &d.LastStatusCode, &d.LastError,
+			&d.NextAttemptAt, &d.DeliveredAt, &d.CreatedAt); err != nil {
+			requestLogger(c).Error("Failed to scan webhook delivery row", "error", err)
+			respondQueryError(c, err, "Error reading deliveries")
+			return
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		requestLogger(c).Error("Failed to iterate webhook delivery rows", "error", err)
+		respondQueryError(c, err, "Error reading deliveries")
+		return
+	}
+
+	c.JSON(http.StatusOK, PaginatedWebhookDeliveries{Data: deliveries, Total: total, Limit: limit, Offset: offset})
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCheckWebhookURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		allowed bool
+	}{
+		{"https://hooks.example.com/quiz", true},
+		{"http://93.184.215.14:8080/hook", true},
+		{"https://[2606:4700::1111]/hook", true},
+		{"ftp://hooks.example.com/", false},
+		{"/relative", false},
+		{"http://127.0.0.1:6379/", false},
+		{"http://localhost:5432/", false},
+		{"http://LOCALHOST/", false},
+		{"http://[::1]/", false},
+		{"http://169.254.169.254/latest/meta-data/", false},
+		{"http://10.0.0.5/", false},
+		{"http://172.16.3.4/", false},
+		{"http://192.168.1.1/", false},
+		{"http://[fd00::1]/", false},
+		{"http://0.0.0.0/", false},
+		{"http://224.0.0.1/", false},
+		{"http://[::ffff:127.0.0.1]/", false},
+	}
+	for _, tt := range tests {
+		if msg := checkWebhookURL(tt.url); (msg == "") != tt.allowed {
+			t.Errorf("checkWebhookURL(%q) = %q, want allowed %v", tt.url, msg, tt.allowed)
+		}
+	}
+}
+
+func TestWebhookClientRefusesLoopback(t *testing.T) {
+	var reached atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { reached.Store(true) }))
+	defer srv.Close()
+
+	// The check runs on the dialled address, so it holds for names too.
+	for _, target := range []string{srv.URL, strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)} {
+		_, err := webhookClient.Post(target, "application/json", strings.NewReader("{}"))
+		if !errors.Is(err, errWebhookDestination) {
+			t.Errorf("POST %s: %v, want %v", target, err, errWebhookDestination)
+		}
+	}
+	if reached.Load() {
+		t.Error("a delivery reached the loopback receiver")
+	}
+}
+
+func TestCreateWebhookRefusesLoopback(t *testing.T) {
+	ctx := testDB(t)
+	org := testOrg(t, ctx)
+	admin := testUser(t, ctx, org, RoleAdmin, "")
+	w := postJSON(t, CreateWebhook,
+		gin.H{"url": "http://127.0.0.1:8080/hook", "events": []string{webhookSubmissionScored}},
+		&Claims{UserID: admin.ID, Role: RoleAdmin, OrgID: org})
+	if w.Code != http.StatusBadRequest || errorCode(t, w) != ErrCodeValidationFailed {
+		t.Errorf("got %d %s, want 400 %s", w.Code, w.Body, ErrCodeValidationFailed)
+	}
+}